@@ -1,11 +1,17 @@
 package main
 
 import (
+	"context"
 	"flag"
 
+	"github.com/noahjalex/epoch/internal/auth"
+	"github.com/noahjalex/epoch/internal/config"
 	"github.com/noahjalex/epoch/internal/database"
 	"github.com/noahjalex/epoch/internal/handlers"
 	"github.com/noahjalex/epoch/internal/logging"
+	"github.com/noahjalex/epoch/internal/middleware"
+	"github.com/noahjalex/epoch/internal/utils"
+	"github.com/noahjalex/epoch/internal/workers"
 )
 
 func main() {
@@ -14,6 +20,11 @@ func main() {
 		port      = flag.String("port", "8080", "port to use")
 		logLevel  = flag.String("log-level", "", "log level (debug, info, warn, error)")
 		logFormat = flag.String("log-format", "", "log format (text, json)")
+
+		migrationsDir = flag.String("migrations", "migrations", "directory of .sql migrations applied on startup")
+		migrateOnly   = flag.Bool("migrate-only", false, "apply pending migrations and exit")
+		rollback      = flag.Int("rollback", 0, "reverse the last N applied migrations and exit")
+		seedFile      = flag.String("seed", "", "development only: run this SQL file after migrations, e.g. seeds/dev.sql")
 	)
 	flag.Parse()
 
@@ -29,23 +40,79 @@ func main() {
 	// Initialize logging with configuration
 	log := logging.Init(logConfig)
 
-	db, repo := database.SetupDB(log)
-	defer db.Close()
+	cfg := config.LoadConfig()
+	db, repo := database.SetupDB(log, database.PoolConfig{
+		MaxOpenConns:    cfg.DBMaxOpenConns,
+		MaxIdleConns:    cfg.DBMaxIdleConns,
+		ConnMaxLifetime: cfg.DBConnMaxLifetime,
+		ConnMaxIdleTime: cfg.DBConnMaxIdleTime,
+	})
+
+	if *rollback > 0 {
+		if err := db.Rollback(log, *migrationsDir, *rollback); err != nil {
+			log.WithError(err).Fatal("Failed to roll back migrations")
+		}
+		db.Close()
+		return
+	}
+
+	if err := db.RunMigrations(log, *migrationsDir); err != nil {
+		log.WithError(err).Fatal("Failed to run migrations")
+	}
+	if *seedFile != "" {
+		if err := db.Seed(log, *seedFile); err != nil {
+			log.WithError(err).Fatal("Failed to seed database")
+		}
+	}
+	if *migrateOnly {
+		db.Close()
+		return
+	}
 
 	// Normalize port
 	if (*port)[:1] != ":" {
 		*port = ":" + *port
 	}
 
+	auth.SetBcryptCost(cfg.BcryptCost)
+	utils.SetMaxBodyBytes(cfg.MaxBodyBytes)
+	if err := middleware.SetSessionCookieConfig(middleware.SessionCookieConfig{
+		Domain:   cfg.CookieDomain,
+		Secure:   cfg.SecureCookies,
+		SameSite: middleware.ParseSameSite(cfg.CookieSameSite),
+	}); err != nil {
+		log.WithError(err).Fatal("Invalid session cookie settings; set EPOCH_SECURE_COOKIES=true or change EPOCH_COOKIE_SAMESITE")
+	}
+	repo.SetMetGrace(cfg.MetGrace)
+	repo.EnableSessionCache(cfg.SessionCacheTTL, cfg.SessionCacheSize)
+
 	// Run Server
-	server, err := handlers.NewServer(repo, log, logConfig)
+	server, err := handlers.NewServer(repo, log, logConfig, cfg)
 	if err != nil {
 		log.WithError(err).Fatal("Failed to create server")
 	}
 
 	log.WithField("port", *port).Info("Starting server")
 
-	if err := server.Run(*port); err != nil {
-		log.WithError(err).Fatal("Server failed")
+	server.SetDatabaseSettings(db.Settings)
+	server.OnClose(db.Close)
+
+	// Background workers stop before the DB closes (cleanups run in reverse)
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	server.OnClose(func() error {
+		stopWorkers()
+		return nil
+	})
+	workers.StartSessionReaper(workerCtx, repo, cfg.SessionReapInterval, log)
+	workers.StartLogPurger(workerCtx, repo, cfg.LogPurgeInterval, cfg.LogRetention, log)
+	workers.StartHabitPurger(workerCtx, repo, cfg.LogPurgeInterval, cfg.HabitRetention, log)
+
+	runErr := server.Run(*port)
+	if err := server.Close(); err != nil {
+		log.WithError(err).Error("Failed to shut down cleanly")
+	}
+	if runErr != nil {
+		log.WithError(runErr).Fatal("Server failed")
 	}
+	log.Info("Server stopped")
 }
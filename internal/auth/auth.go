@@ -12,13 +12,40 @@ import (
 const (
 	// Session token length in bytes (32 bytes = 64 hex chars)
 	SessionTokenLength = 32
-	// Default session duration
+	// Default session duration, used for "remember me" logins
 	DefaultSessionDuration = 30 * 24 * time.Hour // 30 days
+	// ShortSessionDuration is the server-side lifetime of a login without
+	// "remember me"; its cookie also ends when the browser closes
+	ShortSessionDuration = 24 * time.Hour
+	// Minimum length of a new password
+	MinPasswordLength = 8
 )
 
-// HashPassword hashes a password using bcrypt
+// BcryptCost is the cost HashPassword uses; set it with SetBcryptCost
+var BcryptCost = bcrypt.DefaultCost
+
+// SetBcryptCost sets the default hashing cost, falling back to
+// bcrypt.DefaultCost when cost is outside bcrypt's allowed range
+func SetBcryptCost(cost int) {
+	BcryptCost = validCost(cost)
+}
+
+func validCost(cost int) int {
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		return bcrypt.DefaultCost
+	}
+	return cost
+}
+
+// HashPassword hashes a password using bcrypt at BcryptCost
 func HashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	return HashPasswordWithCost(password, BcryptCost)
+}
+
+// HashPasswordWithCost hashes a password using bcrypt at the given cost,
+// or bcrypt.DefaultCost when cost is out of range
+func HashPasswordWithCost(password string, cost int) (string, error) {
+	bytes, err := bcrypt.GenerateFromPassword([]byte(password), validCost(cost))
 	return string(bytes), err
 }
 
@@ -44,5 +71,18 @@ func IsSessionExpired(expiresAt time.Time) bool {
 
 // GetSessionExpiry returns the expiry time for a new session
 func GetSessionExpiry() time.Time {
-	return time.Now().Add(DefaultSessionDuration)
+	return GetSessionExpiryFor(true)
+}
+
+// SessionDuration is the lifetime of a remembered or short session
+func SessionDuration(remember bool) time.Duration {
+	if remember {
+		return DefaultSessionDuration
+	}
+	return ShortSessionDuration
+}
+
+// GetSessionExpiryFor returns the expiry time for a new remembered or short session
+func GetSessionExpiryFor(remember bool) time.Time {
+	return time.Now().Add(SessionDuration(remember))
 }
@@ -0,0 +1,47 @@
+package auth
+
+import (
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestHashPasswordWithCost(t *testing.T) {
+	for _, tc := range []struct {
+		cost, want int
+	}{
+		{bcrypt.MinCost, bcrypt.MinCost},
+		{bcrypt.MinCost + 1, bcrypt.MinCost + 1},
+		{bcrypt.MinCost - 1, bcrypt.DefaultCost},
+		{bcrypt.MaxCost + 1, bcrypt.DefaultCost},
+	} {
+		hash, err := HashPasswordWithCost("hunter22", tc.cost)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := bcrypt.Cost([]byte(hash)); got != tc.want {
+			t.Errorf("cost %d: hashed at %d, want %d", tc.cost, got, tc.want)
+		}
+		if !CheckPassword("hunter22", hash) {
+			t.Errorf("cost %d: password does not match its hash", tc.cost)
+		}
+	}
+}
+
+func TestSetBcryptCost(t *testing.T) {
+	defer SetBcryptCost(BcryptCost)
+
+	SetBcryptCost(bcrypt.MinCost)
+	hash, err := HashPassword("hunter22")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := bcrypt.Cost([]byte(hash)); got != bcrypt.MinCost {
+		t.Errorf("HashPassword used cost %d, want %d", got, bcrypt.MinCost)
+	}
+
+	SetBcryptCost(99)
+	if BcryptCost != bcrypt.DefaultCost {
+		t.Errorf("out-of-range cost set BcryptCost = %d, want %d", BcryptCost, bcrypt.DefaultCost)
+	}
+}
@@ -0,0 +1,17 @@
+package auth
+
+import "context"
+
+// CaptchaVerifier checks a captcha token submitted with a form
+type CaptchaVerifier interface {
+	// Verify returns an error when the token is missing or rejected
+	Verify(ctx context.Context, token, remoteAddr string) error
+}
+
+// NoopCaptchaVerifier accepts every token; it is the default when no
+// captcha provider is configured
+type NoopCaptchaVerifier struct{}
+
+func (NoopCaptchaVerifier) Verify(ctx context.Context, token, remoteAddr string) error {
+	return nil
+}
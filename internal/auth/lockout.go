@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// LoginLockout tracks failed logins per username in memory and locks a
+// username for a while after too many failures in a window. Unknown usernames
+// are tracked the same way so lockouts don't reveal which accounts exist.
+type LoginLockout struct {
+	mu       sync.Mutex
+	max      int
+	window   time.Duration
+	duration time.Duration
+	entries  map[string]*lockoutEntry
+}
+
+type lockoutEntry struct {
+	first       time.Time // start of the current failure window
+	failures    int
+	lockedUntil time.Time
+}
+
+// NewLoginLockout locks a username for duration after max failures within
+// window. A non-positive max disables the lockout.
+func NewLoginLockout(max int, window, duration time.Duration) *LoginLockout {
+	return &LoginLockout{
+		max:      max,
+		window:   window,
+		duration: duration,
+		entries:  make(map[string]*lockoutEntry),
+	}
+}
+
+// Locked reports whether username is currently locked out
+func (l *LoginLockout) Locked(username string) bool {
+	if l.max <= 0 {
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e, ok := l.entries[lockoutKey(username)]
+	return ok && time.Now().Before(e.lockedUntil)
+}
+
+// RecordFailure counts a failed login for username, locking it once the
+// failures in the current window reach the limit
+func (l *LoginLockout) RecordFailure(username string) {
+	if l.max <= 0 {
+		return
+	}
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sweep(now)
+
+	key := lockoutKey(username)
+	e, ok := l.entries[key]
+	if !ok || now.Sub(e.first) >= l.window {
+		e = &lockoutEntry{first: now}
+		l.entries[key] = e
+	}
+	e.failures++
+	if e.failures >= l.max {
+		e.lockedUntil = now.Add(l.duration)
+		e.failures = 0
+		e.first = now
+	}
+}
+
+// Clear forgets username's failures after a successful login
+func (l *LoginLockout) Clear(username string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.entries, lockoutKey(username))
+}
+
+// sweep drops entries that are neither locked nor inside their window
+func (l *LoginLockout) sweep(now time.Time) {
+	for k, e := range l.entries {
+		if now.Sub(e.first) >= l.window && !now.Before(e.lockedUntil) {
+			delete(l.entries, k)
+		}
+	}
+}
+
+func lockoutKey(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
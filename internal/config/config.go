@@ -0,0 +1,291 @@
+package config
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/noahjalex/epoch/internal/models"
+	"github.com/shopspring/decimal"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Config holds server behavior configuration
+type Config struct {
+	// PublicLanding serves the landing page at "/" to logged-out visitors
+	// instead of redirecting them to /login
+	PublicLanding bool
+
+	// ExposeVersion sets the X-Epoch-Version response header
+	ExposeVersion bool
+
+	// APIHead answers HEAD on /api/ routes with the GET headers, including
+	// an accurate Content-Length, and no body
+	APIHead bool
+
+	// HabitDeleteLogs is the default handling of a hard-deleted habit's logs
+	// (cascade, archive or block); DELETE /api/habits/{id}?hard=true&logs=
+	// overrides it
+	HabitDeleteLogs string
+
+	// LogSnap is where new logs are placed within their habit period (none,
+	// start or mid); POST /api/logs accepts "snap" to override it
+	LogSnap string
+
+	// LogFutureSkew is how far past now a created or edited log may be dated;
+	// zero disables the check. Past dates are never restricted.
+	LogFutureSkew time.Duration
+
+	// LogRetention is how long a deleted log can be restored before the purger,
+	// which runs every LogPurgeInterval, removes it for good
+	LogRetention     time.Duration
+	LogPurgeInterval time.Duration
+
+	// HabitRetention is how long an archived habit is kept before it and its
+	// logs are deleted for good, checked every LogPurgeInterval; zero keeps
+	// archived habits forever
+	HabitRetention time.Duration
+
+	// HabitStatsTTL is how long a habit's stats snapshot is served before a
+	// read recomputes it; zero recomputes on every read
+	HabitStatsTTL time.Duration
+
+	// ImportConcurrency caps how many log imports one user may run at once;
+	// zero or less removes the cap
+	ImportConcurrency int
+
+	// AnalyticsMaxDays caps the date range, in days, of the analytics endpoints
+	// (buckets, completion, by-dow and by-hour); zero or less removes the cap
+	AnalyticsMaxDays int
+
+	// PageDefaultLimit is the page size list endpoints use when no limit is given
+	PageDefaultLimit int
+	// PageMaxLimit is the largest limit a client may request
+	PageMaxLimit int
+
+	// RotateSessionOnPrivilegeChange issues a fresh session token after
+	// sensitive account operations to prevent session fixation
+	RotateSessionOnPrivilegeChange bool
+
+	// LogoutRequireSession answers POST /logout with 401 when there is no
+	// valid session instead of 204; the cookie is cleared either way
+	LogoutRequireSession bool
+
+	// SessionRefreshBelow extends a session to a full duration once less than
+	// this much time remains on it; 0 disables sliding expiration
+	SessionRefreshBelow time.Duration
+
+	// SessionCacheTTL caches session lookups in memory for this long; 0 disables
+	// the cache. SessionCacheSize bounds how many sessions it holds.
+	SessionCacheTTL  time.Duration
+	SessionCacheSize int
+
+	// SessionReapInterval is how often expired sessions are deleted; 0 disables
+	SessionReapInterval time.Duration
+
+	// BcryptCost is the password hashing cost; out-of-range values fall back
+	// to bcrypt's default
+	BcryptCost int
+
+	// CSRFProtection requires a CSRF token on unsafe requests
+	CSRFProtection bool
+
+	// TrailingSlash normalizes paths ending in "/": redirect, rewrite or off
+	TrailingSlash string
+
+	// OptionsAllow answers OPTIONS on any route with 204 and an Allow header
+	OptionsAllow bool
+
+	// MetGrace is how far below target a quantity aggregate may fall and
+	// still count as met, absorbing decimal rounding near the boundary
+	MetGrace decimal.Decimal
+
+	// RateLimit allows bursts of this many requests from each client IP,
+	// refilled over RateWindow; it runs before auth so a flood is turned away
+	// without a session lookup. UserRateLimit per UserRateWindow is the same
+	// limit keyed by the signed-in user, so an account spread over many
+	// addresses still has one allowance. AuthRateLimit is the stricter cap on
+	// POST /login and POST /signup per AuthRateWindow. Zero disables a limit.
+	RateLimit      int
+	RateWindow     time.Duration
+	UserRateLimit  int
+	UserRateWindow time.Duration
+	AuthRateLimit  int
+	AuthRateWindow time.Duration
+
+	// RateLimitIPHeader names a header, such as X-Forwarded-For, that a
+	// trusted reverse proxy sets to the client IP. Empty keys the rate limits
+	// by the connection's remote address, the only safe choice without a proxy.
+	RateLimitIPHeader string
+
+	// LoginMaxFailures failed logins for one username within
+	// LoginFailureWindow lock it for LoginLockout; zero disables the lockout
+	LoginMaxFailures   int
+	LoginFailureWindow time.Duration
+	LoginLockout       time.Duration
+
+	// SecureCookies marks cookies Secure (HTTPS only); enable it in production.
+	// CookieSameSite is lax, strict or none, and CookieDomain is optional.
+	SecureCookies  bool
+	CookieSameSite string
+	CookieDomain   string
+
+	// AdminUsers lists the usernames allowed to read /api/admin endpoints
+	AdminUsers []string
+
+	// MaxBodyBytes caps request bodies read by the form parser and the JSON
+	// decoders
+	MaxBodyBytes int64
+
+	// DBMaxOpenConns, DBMaxIdleConns, DBConnMaxLifetime and DBConnMaxIdleTime
+	// size the database connection pool; zero open conns or lifetimes mean
+	// unlimited, as in database/sql. The defaults stay well under Postgres'
+	// usual max_connections of 100 and recycle connections so ones broken by a
+	// failover or proxy timeout are replaced.
+	DBMaxOpenConns    int
+	DBMaxIdleConns    int
+	DBConnMaxLifetime time.Duration
+	DBConnMaxIdleTime time.Duration
+
+	// RequestTimeout is the deadline on each request's context, bounding the
+	// database calls made with it; zero disables it. Log exports and imports are
+	// exempt
+	RequestTimeout time.Duration
+
+	// ReadOnly starts the server refusing API writes with 503; admins can
+	// toggle it at runtime through /api/admin/read-only
+	ReadOnly bool
+
+	// ShutdownTimeout bounds how long shutdown waits for in-flight requests
+	ShutdownTimeout time.Duration
+}
+
+// LoadConfig loads server configuration from environment variables
+func LoadConfig() *Config {
+	return &Config{
+		PublicLanding: getEnvBool("EPOCH_PUBLIC_LANDING", false),
+		ExposeVersion: getEnvBool("EPOCH_EXPOSE_VERSION", true),
+		APIHead:       getEnvBool("EPOCH_API_HEAD", true),
+
+		HabitDeleteLogs: getEnv("EPOCH_HABIT_DELETE_LOGS", "cascade"),
+		LogSnap:         getEnv("EPOCH_LOG_SNAP", "none"),
+
+		LogFutureSkew: getEnvDuration("EPOCH_LOG_FUTURE_SKEW", 24*time.Hour),
+
+		LogRetention:     getEnvDuration("EPOCH_LOG_RETENTION", 30*24*time.Hour),
+		LogPurgeInterval: getEnvDuration("EPOCH_LOG_PURGE_INTERVAL", time.Hour),
+		HabitRetention:   getEnvDuration("EPOCH_HABIT_RETENTION", 0),
+
+		HabitStatsTTL: getEnvDuration("EPOCH_HABIT_STATS_TTL", 15*time.Minute),
+
+		ImportConcurrency: getEnvInt("EPOCH_IMPORT_CONCURRENCY", 1),
+
+		AnalyticsMaxDays: getEnvInt("EPOCH_ANALYTICS_MAX_DAYS", 731),
+
+		PageDefaultLimit: getEnvInt("EPOCH_PAGE_DEFAULT_LIMIT", 100),
+		PageMaxLimit:     getEnvInt("EPOCH_PAGE_MAX_LIMIT", 1000),
+
+		RotateSessionOnPrivilegeChange: getEnvBool("EPOCH_ROTATE_SESSION", true),
+		LogoutRequireSession:           getEnvBool("EPOCH_LOGOUT_REQUIRE_SESSION", false),
+		SessionRefreshBelow:            getEnvDuration("EPOCH_SESSION_REFRESH_BELOW", 7*24*time.Hour),
+		SessionCacheTTL:                getEnvDuration("EPOCH_SESSION_CACHE_TTL", 0),
+		SessionCacheSize:               getEnvInt("EPOCH_SESSION_CACHE_SIZE", 10000),
+		SessionReapInterval:            getEnvDuration("EPOCH_SESSION_REAP_INTERVAL", time.Hour),
+		BcryptCost:                     getEnvInt("EPOCH_BCRYPT_COST", bcrypt.DefaultCost),
+		CSRFProtection:                 getEnvBool("EPOCH_CSRF", true),
+
+		TrailingSlash: getEnv("EPOCH_TRAILING_SLASH", "redirect"),
+		OptionsAllow:  getEnvBool("EPOCH_OPTIONS_ALLOW", true),
+
+		MetGrace: getEnvDecimal("EPOCH_MET_GRACE", models.DefaultMetGrace),
+
+		RateLimit:      getEnvInt("EPOCH_RATE_LIMIT", 600),
+		RateWindow:     getEnvDuration("EPOCH_RATE_WINDOW", time.Minute),
+		UserRateLimit:  getEnvInt("EPOCH_USER_RATE_LIMIT", 600),
+		UserRateWindow: getEnvDuration("EPOCH_USER_RATE_WINDOW", time.Minute),
+		AuthRateLimit:  getEnvInt("EPOCH_AUTH_RATE_LIMIT", 10),
+		AuthRateWindow: getEnvDuration("EPOCH_AUTH_RATE_WINDOW", time.Minute),
+
+		RateLimitIPHeader: getEnv("EPOCH_RATE_LIMIT_IP_HEADER", ""),
+
+		LoginMaxFailures:   getEnvInt("EPOCH_LOGIN_MAX_FAILURES", 5),
+		LoginFailureWindow: getEnvDuration("EPOCH_LOGIN_FAILURE_WINDOW", 15*time.Minute),
+		LoginLockout:       getEnvDuration("EPOCH_LOGIN_LOCKOUT", 15*time.Minute),
+
+		SecureCookies:  getEnvBool("EPOCH_SECURE_COOKIES", false),
+		CookieSameSite: getEnv("EPOCH_COOKIE_SAMESITE", "lax"),
+		CookieDomain:   getEnv("EPOCH_COOKIE_DOMAIN", ""),
+
+		AdminUsers: getEnvList("EPOCH_ADMIN_USERS", nil),
+
+		MaxBodyBytes:    int64(getEnvInt("EPOCH_MAX_BODY_BYTES", 1<<20)),
+		RequestTimeout:  getEnvDuration("EPOCH_REQUEST_TIMEOUT", 15*time.Second),
+		ShutdownTimeout: getEnvDuration("EPOCH_SHUTDOWN_TIMEOUT", 15*time.Second),
+		ReadOnly:        getEnvBool("EPOCH_READ_ONLY", false),
+
+		DBMaxOpenConns:    getEnvInt("DB_MAX_OPEN_CONNS", 25),
+		DBMaxIdleConns:    getEnvInt("DB_MAX_IDLE_CONNS", 5),
+		DBConnMaxLifetime: getEnvDuration("DB_CONN_MAX_LIFETIME", 30*time.Minute),
+		DBConnMaxIdleTime: getEnvDuration("DB_CONN_MAX_IDLE_TIME", 5*time.Minute),
+	}
+}
+
+// getEnv gets an environment variable with a default value
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// getEnvInt gets an integer environment variable with a default value
+func getEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+// getEnvDuration gets a duration environment variable (e.g. "168h") with a default value
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+// getEnvDecimal gets a decimal environment variable with a default value
+func getEnvDecimal(key string, defaultValue decimal.Decimal) decimal.Decimal {
+	value, err := decimal.NewFromString(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+// getEnvBool gets a boolean environment variable with a default value
+func getEnvBool(key string, defaultValue bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	return strings.ToLower(value) == "true" || value == "1"
+}
+
+// getEnvList gets a comma-separated list environment variable with a default value
+func getEnvList(key string, defaultValue []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	var out []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,30 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoadConfigDatabasePool(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		cfg := LoadConfig()
+		if cfg.DBMaxOpenConns != 25 || cfg.DBMaxIdleConns != 5 ||
+			cfg.DBConnMaxLifetime != 30*time.Minute || cfg.DBConnMaxIdleTime != 5*time.Minute {
+			t.Errorf("pool = %d/%d/%v/%v, want 25/5/30m/5m",
+				cfg.DBMaxOpenConns, cfg.DBMaxIdleConns, cfg.DBConnMaxLifetime, cfg.DBConnMaxIdleTime)
+		}
+	})
+
+	t.Run("environment", func(t *testing.T) {
+		t.Setenv("DB_MAX_OPEN_CONNS", "50")
+		t.Setenv("DB_MAX_IDLE_CONNS", "0")
+		t.Setenv("DB_CONN_MAX_LIFETIME", "1h")
+		t.Setenv("DB_CONN_MAX_IDLE_TIME", "not a duration")
+		cfg := LoadConfig()
+		if cfg.DBMaxOpenConns != 50 || cfg.DBMaxIdleConns != 0 ||
+			cfg.DBConnMaxLifetime != time.Hour || cfg.DBConnMaxIdleTime != 5*time.Minute {
+			t.Errorf("pool = %d/%d/%v/%v, want 50/0/1h/5m",
+				cfg.DBMaxOpenConns, cfg.DBMaxIdleConns, cfg.DBConnMaxLifetime, cfg.DBConnMaxIdleTime)
+		}
+	})
+}
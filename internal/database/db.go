@@ -1,10 +1,9 @@
 package database
 
 import (
-	// "database/sql"
 	"fmt"
 	"os"
-	"path/filepath"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
@@ -14,36 +13,75 @@ import (
 
 type DB struct {
 	*sqlx.DB
+
+	// Settings is the configuration the connection was opened with
+	Settings Settings
+}
+
+// Settings holds the database connection configuration. Password is a
+// secret; never log or serve it.
+type Settings struct {
+	Host     string
+	Port     string
+	User     string
+	Password string
+	Name     string
+	Pool     PoolConfig
 }
 
-func SetupDB(log *logrus.Logger) (*DB, *models.Repo) {
+// loadSettings reads the connection settings from the environment; the pool
+// is sized by the caller
+func loadSettings(pool PoolConfig) Settings {
+	return Settings{
+		Host:     getEnv("DB_HOST", "localhost"),
+		Port:     getEnv("DB_PORT", "5432"),
+		User:     getEnv("DB_USER", "epoch"),
+		Password: getEnv("DB_PASSWORD", "devpass"),
+		Name:     getEnv("DB_NAME", "epoch"),
+		Pool:     pool,
+	}
+}
+
+// SetupDB connects to the database with a pool sized by pool, exiting on failure
+func SetupDB(log *logrus.Logger, pool PoolConfig) (*DB, *models.Repo) {
 	// Database configuration
-	dbHost := getEnv("DB_HOST", "localhost")
-	dbPort := getEnv("DB_PORT", "5432")
-	dbUser := getEnv("DB_USER", "epoch")
-	dbPassword := getEnv("DB_PASSWORD", "devpass")
-	dbName := getEnv("DB_NAME", "epoch")
+	settings := loadSettings(pool)
 
 	log.WithFields(logrus.Fields{
-		"host": dbHost,
-		"port": dbPort,
-		"user": dbUser,
-		"name": dbName,
+		"host": settings.Host,
+		"port": settings.Port,
+		"user": settings.User,
+		"name": settings.Name,
 	}).Info("Connecting to database")
 
 	// Connect to database
-	db, err := new(dbHost, dbPort, dbUser, dbPassword, dbName)
+	db, err := new(settings.Host, settings.Port, settings.User, settings.Password, settings.Name, pool)
 	if err != nil {
 		log.WithError(err).Fatal("Failed to connect to database")
 	}
+	db.Settings = settings
 
-	log.Info("Database connection established")
+	log.WithFields(logrus.Fields{
+		"max_open_conns":     pool.MaxOpenConns,
+		"max_idle_conns":     pool.MaxIdleConns,
+		"conn_max_lifetime":  pool.ConnMaxLifetime.String(),
+		"conn_max_idle_time": pool.ConnMaxIdleTime.String(),
+	}).Info("Database connection established")
 
 	repo := models.NewRepository(db.DB)
 	return db, repo
 }
 
-func new(host, port, user, password, dbname string) (*DB, error) {
+// PoolConfig sizes the connection pool. Zero MaxOpenConns or lifetimes mean
+// unlimited, as in database/sql.
+type PoolConfig struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+func new(host, port, user, password, dbname string, pool PoolConfig) (*DB, error) {
 	psqlInfo := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
 		host, port, user, password, dbname)
 
@@ -52,31 +90,16 @@ func new(host, port, user, password, dbname string) (*DB, error) {
 		return nil, err
 	}
 
+	db.SetMaxOpenConns(pool.MaxOpenConns)
+	db.SetMaxIdleConns(pool.MaxIdleConns)
+	db.SetConnMaxLifetime(pool.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(pool.ConnMaxIdleTime)
+
 	if err = db.Ping(); err != nil {
 		return nil, err
 	}
 
-	return &DB{db}, nil
-}
-
-func (db *DB) RunMigrations(migrationsPath string) error {
-	files, err := filepath.Glob(filepath.Join(migrationsPath, "*.sql"))
-	if err != nil {
-		return err
-	}
-
-	for _, file := range files {
-		content, err := os.ReadFile(file)
-		if err != nil {
-			return err
-		}
-
-		if _, err := db.Exec(string(content)); err != nil {
-			return fmt.Errorf("error running migration %s: %v", file, err)
-		}
-
-	}
-	return nil
+	return &DB{DB: db}, nil
 }
 
 func getEnv(name string, def string) string {
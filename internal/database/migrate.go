@@ -0,0 +1,330 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// schemaResetMigration drops and recreates every table. A database created
+// by hand before migrations were tracked records it as applied instead of
+// running it, and the runner refuses to run it against a database that
+// already has tables.
+const schemaResetMigration = "001_schema_setup.sql"
+
+// migrationLockKey is the pg_advisory_lock key held while migrating, so
+// instances starting together apply each file once
+const migrationLockKey = 7_204_391_561
+
+const createMigrationsTable = `
+CREATE TABLE IF NOT EXISTS public.schema_migrations (
+  filename   TEXT PRIMARY KEY,
+  checksum   TEXT NOT NULL,
+  applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+)`
+
+// downSuffix marks the file that reverses a migration. NNN_name.sql and
+// NNN_name.up.sql are both reversed by NNN_name.down.sql.
+const downSuffix = ".down.sql"
+
+// downFilename names the down file paired with the up file filename
+func downFilename(filename string) string {
+	base := strings.TrimSuffix(filename, ".sql")
+	base = strings.TrimSuffix(base, ".up")
+	return base + downSuffix
+}
+
+// migration is one up .sql file in the migrations directory
+type migration struct {
+	Filename string
+	SQL      string
+	Checksum string
+}
+
+// RunMigrations applies the up .sql files in migrationsPath that are not yet in
+// schema_migrations, in lexical order, each in its own transaction together
+// with its tracking row. Files are written for psql, so meta-commands such as
+// \set and \echo and the files' own BEGIN/COMMIT are stripped before running.
+func (db *DB) RunMigrations(log *logrus.Logger, migrationsPath string) error {
+	unlock, err := db.lockMigrations(log)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, err := db.Exec(createMigrationsTable); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	files, err := loadMigrations(migrationsPath)
+	if err != nil {
+		return err
+	}
+
+	applied := map[string]string{}
+	rows, err := db.Query(`SELECT filename, checksum FROM public.schema_migrations`)
+	if err != nil {
+		return fmt.Errorf("read schema_migrations: %w", err)
+	}
+	for rows.Next() {
+		var name, sum string
+		if err := rows.Scan(&name, &sum); err != nil {
+			rows.Close()
+			return err
+		}
+		applied[name] = sum
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if len(applied) == 0 {
+		if err := db.baseline(log, files, applied); err != nil {
+			return err
+		}
+	}
+
+	ran := 0
+	for _, m := range files {
+		if sum, ok := applied[m.Filename]; ok {
+			if sum != m.Checksum {
+				log.WithField("migration", m.Filename).Warn("Applied migration has changed on disk; not running it again")
+			}
+			continue
+		}
+		if m.Filename == schemaResetMigration {
+			if err := db.requireEmpty(); err != nil {
+				return err
+			}
+		}
+
+		tx, err := db.Beginx()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(m.SQL); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("error running migration %s: %w", m.Filename, err)
+		}
+		if _, err := tx.Exec(
+			`INSERT INTO public.schema_migrations (filename, checksum) VALUES ($1, $2)`,
+			m.Filename, m.Checksum,
+		); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("record migration %s: %w", m.Filename, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("commit migration %s: %w", m.Filename, err)
+		}
+		log.WithField("migration", m.Filename).Info("Applied migration")
+		ran++
+	}
+
+	log.WithFields(logrus.Fields{
+		"applied": ran,
+		"total":   len(files),
+	}).Info("Migrations up to date")
+	return nil
+}
+
+// Rollback reverses the last n applied migrations, newest first, using the
+// down file paired with each. Every down file is checked before any runs;
+// each one runs in a transaction together with removing its tracking row.
+func (db *DB) Rollback(log *logrus.Logger, migrationsPath string, n int) error {
+	if n <= 0 {
+		return nil
+	}
+	unlock, err := db.lockMigrations(log)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, err := db.Exec(createMigrationsTable); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	var names []string
+	if err := db.Select(&names, `
+		SELECT filename FROM public.schema_migrations
+		ORDER BY applied_at DESC, filename DESC
+		LIMIT $1
+	`, n); err != nil {
+		return fmt.Errorf("read schema_migrations: %w", err)
+	}
+	if len(names) < n {
+		return fmt.Errorf("cannot roll back %d migrations: only %d applied", n, len(names))
+	}
+
+	downs := make([]string, len(names))
+	for i, name := range names {
+		content, err := os.ReadFile(filepath.Join(migrationsPath, downFilename(name)))
+		if err != nil {
+			return fmt.Errorf("migration %s has no usable down file: %w", name, err)
+		}
+		downs[i] = stripPsql(string(content))
+	}
+
+	for i, name := range names {
+		tx, err := db.Beginx()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(downs[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("error rolling back migration %s: %w", name, err)
+		}
+		if _, err := tx.Exec(`DELETE FROM public.schema_migrations WHERE filename = $1`, name); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("unrecord migration %s: %w", name, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("commit rollback of %s: %w", name, err)
+		}
+		log.WithField("migration", name).Info("Rolled back migration")
+	}
+	return nil
+}
+
+// lockMigrations takes the migration advisory lock on a dedicated connection,
+// waiting for any other instance that holds it, and returns its release
+func (db *DB) lockMigrations(log *logrus.Logger) (func(), error) {
+	ctx := context.Background()
+	conn, err := db.Connx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLockKey); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("lock migrations: %w", err)
+	}
+	return func() {
+		if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, migrationLockKey); err != nil {
+			log.WithError(err).Warn("Failed to release migration lock")
+		}
+		conn.Close()
+	}, nil
+}
+
+// hasSchema reports whether the application tables already exist
+func (db *DB) hasSchema() (bool, error) {
+	var exists bool
+	err := db.Get(&exists, `SELECT to_regclass('public.app_user') IS NOT NULL`)
+	return exists, err
+}
+
+// requireEmpty refuses to run the schema reset against a database that has
+// tables, since it would drop them
+func (db *DB) requireEmpty() error {
+	exists, err := db.hasSchema()
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("refusing to run %s: it drops every table and the database already has a schema", schemaResetMigration)
+	}
+	return nil
+}
+
+// baseline records the migrations up to schemaResetMigration as applied when
+// the schema already exists but nothing is tracked yet
+func (db *DB) baseline(log *logrus.Logger, files []migration, applied map[string]string) error {
+	exists, err := db.hasSchema()
+	if err != nil || !exists {
+		return err
+	}
+
+	for _, m := range files {
+		if m.Filename > schemaResetMigration {
+			break
+		}
+		if _, err := db.Exec(
+			`INSERT INTO public.schema_migrations (filename, checksum) VALUES ($1, $2)`,
+			m.Filename, m.Checksum,
+		); err != nil {
+			return fmt.Errorf("baseline migration %s: %w", m.Filename, err)
+		}
+		applied[m.Filename] = m.Checksum
+		log.WithField("migration", m.Filename).Warn("Existing schema found; marked migration as applied without running it")
+	}
+	return nil
+}
+
+// Seed runs the SQL file at path in one transaction. It is for development
+// databases only; seed files are kept outside the migrations directory so
+// they never reach a deployment through RunMigrations.
+func (db *DB) Seed(log *logrus.Logger, path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(stripPsql(string(content))); err != nil {
+		return fmt.Errorf("error running seed %s: %w", path, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	log.WithField("seed", path).Info("Applied seed data")
+	return nil
+}
+
+// loadMigrations reads the up .sql files in dir sorted by filename
+func loadMigrations(dir string) ([]migration, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.sql"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	out := make([]migration, 0, len(paths))
+	for _, p := range paths {
+		if strings.HasSuffix(p, downSuffix) {
+			continue
+		}
+		content, err := os.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(content)
+		out = append(out, migration{
+			Filename: filepath.Base(p),
+			SQL:      stripPsql(string(content)),
+			Checksum: hex.EncodeToString(sum[:]),
+		})
+	}
+	return out, nil
+}
+
+// stripPsql drops psql meta-command lines and top-level BEGIN;/COMMIT; so the
+// file can run inside the runner's own transaction. PL/pgSQL block BEGINs
+// have no semicolon and are left alone.
+func stripPsql(sql string) string {
+	lines := strings.Split(sql, "\n")
+	kept := lines[:0]
+	for _, line := range lines {
+		t := strings.TrimSpace(line)
+		if strings.HasPrefix(t, `\`) {
+			continue
+		}
+		switch strings.ToUpper(t) {
+		case "BEGIN;", "COMMIT;":
+			continue
+		}
+		kept = append(kept, line)
+	}
+	return strings.Join(kept, "\n")
+}
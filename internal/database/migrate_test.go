@@ -0,0 +1,229 @@
+package database
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/sirupsen/logrus"
+)
+
+// openTestDB connects to EPOCH_TEST_DATABASE_URL, skipping the test when it
+// is unset
+func openTestDB(t *testing.T) *DB {
+	t.Helper()
+	url := os.Getenv("EPOCH_TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("EPOCH_TEST_DATABASE_URL not set")
+	}
+	db, err := sqlx.Open("postgres", url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return &DB{DB: db}
+}
+
+func quietLogger() *logrus.Logger {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	return log
+}
+
+func TestLoadMigrationsSortsAndSkipsDownFiles(t *testing.T) {
+	dir := t.TempDir()
+	for name, body := range map[string]string{
+		"002_b.sql":      "SELECT 2;",
+		"001_a.sql":      "SELECT 1;",
+		"001_a.down.sql": "SELECT -1;",
+		"notes.txt":      "ignored",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, err := loadMigrations(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, m := range files {
+		names = append(names, m.Filename)
+	}
+	if got := strings.Join(names, ","); got != "001_a.sql,002_b.sql" {
+		t.Fatalf("migrations = %s, want 001_a.sql,002_b.sql", got)
+	}
+}
+
+func TestStripPsql(t *testing.T) {
+	in := "\\set ON_ERROR_STOP on\n\\echo 'hi'\nBEGIN;\nDO $$\nBEGIN\n  PERFORM 1;\nEND$$;\ncommit;\n"
+	got := stripPsql(in)
+	for _, gone := range []string{`\set`, `\echo`, "BEGIN;", "commit;"} {
+		if strings.Contains(got, gone) {
+			t.Errorf("stripPsql kept %q:\n%s", gone, got)
+		}
+	}
+	if !strings.Contains(got, "DO $$\nBEGIN\n") {
+		t.Errorf("stripPsql dropped the PL/pgSQL BEGIN:\n%s", got)
+	}
+}
+
+func TestDownFilename(t *testing.T) {
+	for in, want := range map[string]string{
+		"003_policy.sql":    "003_policy.down.sql",
+		"003_policy.up.sql": "003_policy.down.sql",
+	} {
+		if got := downFilename(in); got != want {
+			t.Errorf("downFilename(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+// A migration without a down file must say why, since Rollback stops there
+func TestMigrationsHaveDownFiles(t *testing.T) {
+	dir := filepath.Join("..", "..", "migrations")
+	files, err := loadMigrations(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range files {
+		if m.Filename == schemaResetMigration {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(dir, downFilename(m.Filename))); err == nil {
+			continue
+		}
+		if !strings.Contains(m.SQL, "cannot be rolled back") {
+			t.Errorf("%s has no down file and does not say it cannot be rolled back", m.Filename)
+		}
+	}
+}
+
+// Seed data must never reach a deployment through the startup runner
+func TestMigrationsHoldNoSeedData(t *testing.T) {
+	files, err := loadMigrations(filepath.Join("..", "..", "migrations"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no migrations found")
+	}
+	for _, m := range files {
+		if strings.Contains(m.SQL, "INSERT INTO public.app_user") {
+			t.Errorf("%s inserts users; seed data belongs in seeds/", m.Filename)
+		}
+	}
+}
+
+func TestRunMigrationsConcurrentStartsApplyOnce(t *testing.T) {
+	db := openTestDB(t)
+	dir := filepath.Join("..", "..", "migrations")
+
+	var wg sync.WaitGroup
+	errs := make([]error, 3)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = db.RunMigrations(quietLogger(), dir)
+		}(i)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("run %d: %v", i, err)
+		}
+	}
+
+	files, err := loadMigrations(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range files {
+		var n int
+		if err := db.Get(&n, `SELECT COUNT(*) FROM public.schema_migrations WHERE filename = $1`, m.Filename); err != nil {
+			t.Fatal(err)
+		}
+		if n != 1 {
+			t.Errorf("%s recorded %d times, want 1", m.Filename, n)
+		}
+	}
+}
+
+func TestSchemaResetRefusesExistingSchema(t *testing.T) {
+	db := openTestDB(t)
+	if err := db.RunMigrations(quietLogger(), filepath.Join("..", "..", "migrations")); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.requireEmpty(); err == nil {
+		t.Fatal("requireEmpty succeeded on a migrated database")
+	}
+}
+
+func TestRollback(t *testing.T) {
+	db := openTestDB(t)
+	if err := db.RunMigrations(quietLogger(), filepath.Join("..", "..", "migrations")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Two tracked migrations newer than any real one: the newest without a
+	// down file, the one before it with a down file that drops a probe table
+	dir := t.TempDir()
+	down := "\\set ON_ERROR_STOP on\nBEGIN;\nDROP TABLE public.rollback_probe;\nCOMMIT;\n"
+	if err := os.WriteFile(filepath.Join(dir, "zz_probe.down.sql"), []byte(down), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Exec(`DELETE FROM public.schema_migrations WHERE filename IN ('zz_probe.sql', 'zz_nodown.sql')`)
+		db.Exec(`DROP TABLE IF EXISTS public.rollback_probe`)
+	})
+	for _, q := range []string{
+		`CREATE TABLE public.rollback_probe (id INT)`,
+		`INSERT INTO public.schema_migrations (filename, checksum, applied_at) VALUES ('zz_probe.sql', 'x', NOW() + INTERVAL '1 hour')`,
+		`INSERT INTO public.schema_migrations (filename, checksum, applied_at) VALUES ('zz_nodown.sql', 'x', NOW() + INTERVAL '2 hours')`,
+	} {
+		if _, err := db.Exec(q); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tracked := func(name string) bool {
+		var n int
+		if err := db.Get(&n, `SELECT COUNT(*) FROM public.schema_migrations WHERE filename = $1`, name); err != nil {
+			t.Fatal(err)
+		}
+		return n == 1
+	}
+	probeExists := func() bool {
+		var ok bool
+		if err := db.Get(&ok, `SELECT to_regclass('public.rollback_probe') IS NOT NULL`); err != nil {
+			t.Fatal(err)
+		}
+		return ok
+	}
+
+	// A missing down file anywhere in the range refuses before anything runs
+	err := db.Rollback(quietLogger(), dir, 2)
+	if err == nil || !strings.Contains(err.Error(), "zz_nodown.sql has no usable down file") {
+		t.Fatalf("err = %v, want the missing down file named", err)
+	}
+	if !tracked("zz_probe.sql") || !tracked("zz_nodown.sql") || !probeExists() {
+		t.Fatal("refused rollback changed the database")
+	}
+
+	// With it out of the way the down file runs and its tracking row goes
+	if _, err := db.Exec(`DELETE FROM public.schema_migrations WHERE filename = 'zz_nodown.sql'`); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Rollback(quietLogger(), dir, 1); err != nil {
+		t.Fatal(err)
+	}
+	if tracked("zz_probe.sql") || probeExists() {
+		t.Error("rolled back migration is still tracked or its table remains")
+	}
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/noahjalex/epoch/internal/config"
+	"github.com/noahjalex/epoch/internal/database"
+	"github.com/noahjalex/epoch/internal/models"
+)
+
+func TestAdminConfigRedactsSecrets(t *testing.T) {
+	app := newTestServer(t, func(c *config.Config) { c.AdminUsers = []string{"root"} })
+	admin := &models.AppUser{ID: 1, Username: "root"}
+
+	for _, tt := range []struct {
+		password string
+		want     string
+	}{
+		{"hunter2-s3cret", redactedValue},
+		{"", ""},
+	} {
+		app.SetDatabaseSettings(database.Settings{Host: "db.internal", User: "epoch", Password: tt.password})
+		w := serve(app.handleAdminConfigAPI, apiRequest("GET", "/api/admin/config", "", admin))
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+		}
+		if w.Header().Get("Cache-Control") != "no-store" {
+			t.Errorf("Cache-Control = %q, want no-store", w.Header().Get("Cache-Control"))
+		}
+		if tt.password != "" && strings.Contains(w.Body.String(), tt.password) {
+			t.Fatalf("response leaks the database password: %s", w.Body)
+		}
+
+		var got adminConfig
+		if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Database.Password != tt.want {
+			t.Errorf("password %q shown as %q, want %q", tt.password, got.Database.Password, tt.want)
+		}
+		if got.Database.Host != "db.internal" || got.Database.User != "epoch" {
+			t.Errorf("database = %+v, want the non-secret settings shown", got.Database)
+		}
+	}
+}
+
+func TestAdminConfigHiddenFromNonAdmins(t *testing.T) {
+	app := newTestServer(t, func(c *config.Config) { c.AdminUsers = []string{"root"} })
+	user := &models.AppUser{ID: 2, Username: "alice"}
+
+	w := serve(app.handleAdminConfigAPI, apiRequest("GET", "/api/admin/config", "", user))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", w.Code)
+	}
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/noahjalex/epoch/internal/config"
+)
+
+func TestAnalyticsRange(t *testing.T) {
+	today := time.Now().UTC()
+	today = time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		maxDays     int
+		query       string
+		defaultDays int
+		wantOK      bool
+		wantDays    int
+	}{
+		{"default range", 731, "", defaultBucketDays, true, defaultBucketDays},
+		{"longest allowed range", 731, "", 0, true, 731},
+		{"no cap with longest range", 0, "", 0, true, uncappedDefaultDays},
+		{"negative cap with longest range", -1, "", 0, true, uncappedDefaultDays},
+		{"no cap with default range", 0, "", defaultBucketDays, true, defaultBucketDays},
+		{"no cap allows long explicit range", 0, "?from=2000-01-01&to=2010-01-01", 0, true, 3654},
+		{"explicit range", 731, "?from=2024-01-01&to=2024-01-31", 0, true, 31},
+		{"range over cap", 10, "?from=2024-01-01&to=2024-01-31", 0, false, 0},
+		{"end before start", 731, "?from=2024-02-01&to=2024-01-31", 0, false, 0},
+		{"bad date", 731, "?from=yesterday", 0, false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestServer(t, func(c *config.Config) { c.AnalyticsMaxDays = tt.maxDays })
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", "/api/analytics"+tt.query, nil)
+			from, to, ok := app.analyticsRange(w, r, time.UTC, "from", "to", tt.defaultDays)
+			if ok != tt.wantOK {
+				t.Fatalf("ok = %v, want %v (status %d: %s)", ok, tt.wantOK, w.Code, w.Body)
+			}
+			if !ok {
+				if w.Code != http.StatusBadRequest {
+					t.Errorf("status = %d, want 400", w.Code)
+				}
+				return
+			}
+			if tt.query == "" && !to.Equal(today) {
+				t.Errorf("to = %v, want today %v", to, today)
+			}
+			if days := int(to.Sub(from)/(24*time.Hour)) + 1; days != tt.wantDays {
+				t.Errorf("range is %d days, want %d", days, tt.wantDays)
+			}
+		})
+	}
+}
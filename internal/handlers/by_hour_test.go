@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/noahjalex/epoch/internal/testdb"
+)
+
+func TestHabitByHourUsesLocalHours(t *testing.T) {
+	app, repo := newDBServer(t, nil)
+	user := testdb.NewUser(t, repo, "Asia/Kolkata")
+	habit := testdb.NewHabit(t, repo, user.ID, nil)
+	id := fmt.Sprint(habit.ID)
+
+	testdb.NewLog(t, repo, habit.ID, time.Date(2024, 3, 10, 3, 30, 0, 0, time.UTC), 2) // 09:00 in Kolkata
+	testdb.NewLog(t, repo, habit.ID, time.Date(2024, 3, 11, 3, 45, 0, 0, time.UTC), 1) // 09:15
+	testdb.NewLog(t, repo, habit.ID, time.Date(2024, 3, 10, 20, 0, 0, 0, time.UTC), 4) // 01:30 the next day
+	testdb.NewLog(t, repo, habit.ID, time.Date(2024, 3, 12, 20, 0, 0, 0, time.UTC), 8) // after the range locally
+
+	w := serve(app.handleHabitByHourAPI, apiRequest("GET", "/api/habits/"+id+"/by-hour?from=2024-03-10&to=2024-03-12", "", user), "id", id)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+	var got []hourTotal
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 24 {
+		t.Fatalf("%d hours, want 24", len(got))
+	}
+	want := map[int]float64{1: 4, 9: 3}
+	for _, h := range got {
+		if h.Total != want[h.Hour] {
+			t.Errorf("hour %d total = %v, want %v", h.Hour, h.Total, want[h.Hour])
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"context"
+	"html/template"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/noahjalex/epoch/internal/middleware"
+)
+
+func TestWithCSRF(t *testing.T) {
+	base := template.Must(template.New("page").Funcs(template.FuncMap{
+		"csrfToken": func() string { return "" },
+		"csrfField": func() template.HTML { return "" },
+	}).Parse(`{{csrfToken}}|{{csrfField}}`))
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r = r.WithContext(context.WithValue(r.Context(), middleware.CSRFContextKey, "tok123"))
+	tmpl, err := withCSRF(base, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var b strings.Builder
+	if err := tmpl.Execute(&b, nil); err != nil {
+		t.Fatal(err)
+	}
+	want := `tok123|<input type="hidden" name="csrf_token" value="tok123">`
+	if b.String() != want {
+		t.Errorf("rendered %q, want %q", b.String(), want)
+	}
+}
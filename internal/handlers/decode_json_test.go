@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/noahjalex/epoch/internal/models"
+	"github.com/noahjalex/epoch/internal/utils"
+)
+
+func TestDecodeOptionalJSON(t *testing.T) {
+	defer utils.SetMaxBodyBytes(utils.MaxBodyBytes)
+	utils.SetMaxBodyBytes(64)
+
+	tests := []struct {
+		name    string
+		body    string
+		hasBody bool
+		wantErr bool
+	}{
+		{"empty", "", false, false},
+		{"object", `{"delta":2}`, true, false},
+		{"malformed", `{"delta":`, false, true},
+		{"over the limit", `{"delta":2,"pad":"` + strings.Repeat("x", 100) + `"}`, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("PATCH", "/api/habits/1", strings.NewReader(tt.body))
+			var v incrementRequest
+			hasBody, err := decodeOptionalJSON(httptest.NewRecorder(), r, &v)
+			if (err != nil) != tt.wantErr || (err == nil && hasBody != tt.hasBody) {
+				t.Errorf("got (%v, %v), want hasBody %v and error %v", hasBody, err, tt.hasBody, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestJSONBodyLimit(t *testing.T) {
+	defer utils.SetMaxBodyBytes(utils.MaxBodyBytes)
+	utils.SetMaxBodyBytes(1 << 10)
+	app := newTestServer(t, nil)
+	user := &models.AppUser{ID: 1, Username: "u", TZ: "UTC"}
+
+	body := `{"name":"` + strings.Repeat("x", 2<<10) + `","goal":1}`
+	w := serve(app.handleHabitCreateAPI, apiRequest("POST", "/api/habits", body, user))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400 for a body over the limit", w.Code)
+	}
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/noahjalex/epoch/internal/models"
+	"github.com/noahjalex/epoch/internal/testdb"
+	"github.com/shopspring/decimal"
+)
+
+func TestLogsExportAnonymized(t *testing.T) {
+	app, repo := newDBServer(t, nil)
+	user := testdb.NewUser(t, repo, "America/Toronto")
+	habit := testdb.NewHabit(t, repo, user.ID, func(h *models.Habit) {
+		h.Name = "Therapy sessions"
+		h.UnitLabel = sql.NullString{String: "hours", Valid: true}
+	})
+	_, err := repo.InsertLog(context.Background(), &models.HabitLog{
+		HabitID:    habit.ID,
+		OccurredAt: time.Date(2024, 3, 10, 14, 0, 0, 0, time.UTC),
+		Quantity:   decimal.RequireFromString("1.5"),
+		Note:       sql.NullString{String: "talked about work", Valid: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pii := []string{user.Username, user.Email, "Therapy", "talked about work", "-04:00", "-05:00"}
+
+	t.Run("csv", func(t *testing.T) {
+		w := serve(app.handleLogsExportCSV, apiRequest("GET", "/api/logs/export.csv?anonymize=true", "", user))
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d", w.Code)
+		}
+		body := w.Body.String()
+		for _, s := range pii {
+			if strings.Contains(body, s) {
+				t.Errorf("anonymized CSV contains %q", s)
+			}
+		}
+		rows, err := csv.NewReader(strings.NewReader(body)).ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []string{"Habit 1", "2024-03-10T10:00:00", "1.5", "hours", ""}
+		if len(rows) != 2 || strings.Join(rows[1], "|") != strings.Join(want, "|") {
+			t.Errorf("rows = %q, want header and %q", rows, want)
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		w := serve(app.handleLogsExportJSON, apiRequest("GET", "/api/logs/export.json?anonymize=true", "", user))
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d", w.Code)
+		}
+		body := w.Body.String()
+		for _, s := range pii {
+			if strings.Contains(body, s) {
+				t.Errorf("anonymized JSON contains %q", s)
+			}
+		}
+		var logs []FrontendLog
+		if err := json.Unmarshal([]byte(body), &logs); err != nil {
+			t.Fatal(err)
+		}
+		want := FrontendLog{ID: "1", HabitID: "Habit 1", Date: "2024-03-10T10:00:00", Qty: 1.5}
+		if len(logs) != 1 || logs[0] != want {
+			t.Errorf("logs = %+v, want [%+v]", logs, want)
+		}
+	})
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/noahjalex/epoch/internal/models"
+	"github.com/noahjalex/epoch/internal/testdb"
+)
+
+func TestHabitCreateUsesPreferredWeekStart(t *testing.T) {
+	app, repo := newDBServer(t, nil)
+
+	tests := []struct {
+		name  string
+		prefs models.JSONB
+		body  string
+		want  int32
+	}{
+		{"no preference", nil, `{"name":"Read","goal":1}`, models.DefaultWeekStartDOW},
+		{"preferred sunday", models.JSONB{models.PrefWeekStartDOW: float64(0)}, `{"name":"Read","goal":1}`, 0},
+		{"preferred saturday", models.JSONB{models.PrefWeekStartDOW: float64(6)}, `{"name":"Read","goal":1}`, 6},
+		{"habit overrides preference", models.JSONB{models.PrefWeekStartDOW: float64(0)}, `{"name":"Read","goal":1,"weekStartDow":3}`, 3},
+		{"locale alone keeps the default", models.JSONB{models.PrefLocale: "en-US"}, `{"name":"Read","goal":1}`, models.DefaultWeekStartDOW},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			user := testdb.NewUser(t, repo, "")
+			if tt.prefs != nil {
+				if _, err := repo.UpdatePreferences(ctx, user.ID, tt.prefs); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			w := serve(app.handleHabitCreateAPI, apiRequest("POST", "/api/habits", tt.body, user))
+			if w.Code != http.StatusCreated {
+				t.Fatalf("status = %d, want 201: %s", w.Code, w.Body)
+			}
+			var got FrontendHabit
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+			if got.WeekStartDOW == nil || *got.WeekStartDOW != tt.want {
+				t.Errorf("response weekStartDow = %v, want %d", got.WeekStartDOW, tt.want)
+			}
+
+			id, err := strconv.ParseInt(got.ID, 10, 64)
+			if err != nil {
+				t.Fatal(err)
+			}
+			stored, err := repo.GetHabit(ctx, id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored.WeekStartDOW != tt.want {
+				t.Errorf("stored week start = %d, want %d", stored.WeekStartDOW, tt.want)
+			}
+		})
+	}
+}
+
+func TestHabitCreateWeeklyCount(t *testing.T) {
+	app, repo := newDBServer(t, nil)
+	user := testdb.NewUser(t, repo, "")
+
+	w := serve(app.handleHabitCreateAPI, apiRequest("POST", "/api/habits", `{"name":"Gym","goal":3,"period":"weekly","agg":"count"}`, user))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want 201: %s", w.Code, w.Body)
+	}
+	var got FrontendHabit
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Period != "weekly" || got.Agg != "count" {
+		t.Errorf("response period/agg = %q/%q, want weekly/count", got.Period, got.Agg)
+	}
+
+	id, err := strconv.ParseInt(got.ID, 10, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, err := repo.GetHabit(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Period != models.PeriodWeekly || stored.Agg != models.AggCount {
+		t.Errorf("stored period/agg = %s/%s, want weekly/count", stored.Period, stored.Agg)
+	}
+}
+
+func TestHabitColorAndIcon(t *testing.T) {
+	app, repo := newDBServer(t, nil)
+	ctx := context.Background()
+	user := testdb.NewUser(t, repo, "")
+
+	w := serve(app.handleHabitCreateAPI, apiRequest("POST", "/api/habits", `{"name":"Gym","goal":1,"color":"#3a7","icon":"dumbbell"}`, user))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create status = %d, want 201: %s", w.Code, w.Body)
+	}
+	var got FrontendHabit
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Color != "#3a7" || got.Icon != "dumbbell" {
+		t.Errorf("response color/icon = %q/%q, want #3a7/dumbbell", got.Color, got.Icon)
+	}
+
+	// An empty color clears it and leaves the icon alone
+	w = serve(app.handleHabitUpdateAPI, apiRequest("PATCH", "/api/habits/"+got.ID, `{"color":""}`, user), "id", got.ID)
+	if w.Code != http.StatusOK {
+		t.Fatalf("update status = %d, want 200: %s", w.Code, w.Body)
+	}
+	id, err := strconv.ParseInt(got.ID, 10, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, err := repo.GetHabit(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Color.Valid || stored.Icon.String != "dumbbell" {
+		t.Errorf("stored color/icon = %v/%v, want cleared/dumbbell", stored.Color, stored.Icon)
+	}
+
+	for _, color := range []string{"red", "#12345", "3a7", "#ggg"} {
+		body := `{"name":"Gym","goal":1,"color":"` + color + `"}`
+		if w := serve(app.handleHabitCreateAPI, apiRequest("POST", "/api/habits", body, user)); w.Code != http.StatusBadRequest {
+			t.Errorf("color %q: create status = %d, want 400", color, w.Code)
+		}
+		if w := serve(app.handleHabitUpdateAPI, apiRequest("PATCH", "/api/habits/"+got.ID, `{"color":"`+color+`"}`, user), "id", got.ID); w.Code != http.StatusBadRequest {
+			t.Errorf("color %q: update status = %d, want 400", color, w.Code)
+		}
+	}
+}
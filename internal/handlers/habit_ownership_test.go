@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/noahjalex/epoch/internal/middleware"
+	"github.com/noahjalex/epoch/internal/testdb"
+)
+
+func TestHabitUpdateDeleteRejectOtherUsers(t *testing.T) {
+	app, repo := newDBServer(t, nil)
+	owner := testdb.NewUser(t, repo, "")
+	other := testdb.NewUser(t, repo, "")
+	habit := testdb.NewHabit(t, repo, owner.ID, nil)
+	id := fmt.Sprint(habit.ID)
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		method  string
+		target  string
+		body    string
+	}{
+		{"read", app.handleHabitGetAPI, "GET", "/api/habits/" + id, ""},
+		{"update", app.handleHabitUpdateAPI, "PATCH", "/api/habits/" + id, `{"name":"Taken"}`},
+		{"archive", app.handleHabitDeleteAPI, "DELETE", "/api/habits/" + id, ""},
+		{"hard delete", app.handleHabitDeleteAPI, "DELETE", "/api/habits/" + id + "?hard=true&logs=cascade", ""},
+		{"restore", app.handleHabitRestoreAPI, "POST", "/api/habits/" + id + "/restore", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(tt.handler, apiRequest(tt.method, tt.target, tt.body, other), "id", id)
+			if w.Code != http.StatusNotFound {
+				t.Fatalf("status = %d, want 404: %s", w.Code, w.Body)
+			}
+		})
+	}
+
+	got, err := repo.GetHabit(context.Background(), habit.ID)
+	if err != nil {
+		t.Fatalf("owner's habit is gone: %v", err)
+	}
+	if got.Name != habit.Name || !got.IsActive {
+		t.Errorf("owner's habit changed: name %q active %v", got.Name, got.IsActive)
+	}
+}
+
+func TestHabitUpdateByOwner(t *testing.T) {
+	app, repo := newDBServer(t, nil)
+	owner := testdb.NewUser(t, repo, "")
+	habit := testdb.NewHabit(t, repo, owner.ID, nil)
+	id := fmt.Sprint(habit.ID)
+
+	w := serve(app.handleHabitUpdateAPI, apiRequest("PATCH", "/api/habits/"+id, `{"name":"Renamed"}`, owner), "id", id)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+	got, err := repo.GetHabit(context.Background(), habit.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "Renamed" {
+		t.Errorf("name = %q, want Renamed", got.Name)
+	}
+}
+
+func TestHabitArchiveRestoreAndHardDelete(t *testing.T) {
+	app, repo := newDBServer(t, nil)
+	ctx := context.Background()
+	user := testdb.NewUser(t, repo, "")
+	habit := testdb.NewHabit(t, repo, user.ID, nil)
+	id := fmt.Sprint(habit.ID)
+
+	activeIDs := func() map[int64]bool {
+		t.Helper()
+		hs, err := repo.ListHabitsByUser(ctx, user.ID, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := map[int64]bool{}
+		for _, h := range hs {
+			ids[h.ID] = true
+		}
+		return ids
+	}
+
+	if w := serve(app.handleHabitDeleteAPI, apiRequest("DELETE", "/api/habits/"+id, "", user), "id", id); w.Code != http.StatusNoContent {
+		t.Fatalf("archive status = %d, want 204: %s", w.Code, w.Body)
+	}
+	if h, err := repo.GetHabit(ctx, habit.ID); err != nil || h.IsActive {
+		t.Fatalf("archived habit = %+v, %v; want it kept and inactive", h, err)
+	}
+	if activeIDs()[habit.ID] {
+		t.Error("archived habit still listed as active")
+	}
+
+	if w := serve(app.handleHabitRestoreAPI, apiRequest("POST", "/api/habits/"+id+"/restore", "", user), "id", id); w.Code != http.StatusOK {
+		t.Fatalf("restore status = %d, want 200: %s", w.Code, w.Body)
+	}
+	if !activeIDs()[habit.ID] {
+		t.Error("restored habit not listed as active")
+	}
+
+	if w := serve(app.handleHabitDeleteAPI, apiRequest("DELETE", "/api/habits/"+id+"?hard=true&logs=cascade", "", user), "id", id); w.Code != http.StatusNoContent {
+		t.Fatalf("hard delete status = %d, want 204: %s", w.Code, w.Body)
+	}
+	if _, err := repo.GetHabit(ctx, habit.ID); err == nil {
+		t.Error("hard-deleted habit still found")
+	}
+}
+
+func TestHabitLookupTimeoutIsNotNotFound(t *testing.T) {
+	app, repo := newDBServer(t, nil)
+	owner := testdb.NewUser(t, repo, "")
+	habit := testdb.NewHabit(t, repo, owner.ID, nil)
+	l := testdb.NewLog(t, repo, habit.ID, time.Now().Add(-time.Hour), 1)
+	id, logID := fmt.Sprint(habit.ID), fmt.Sprint(l.ID)
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		method  string
+		target  string
+		body    string
+		path    string
+	}{
+		{"read", app.handleHabitGetAPI, "GET", "/api/habits/" + id, "", id},
+		{"update", app.handleHabitUpdateAPI, "PATCH", "/api/habits/" + id, `{"name":"Renamed"}`, id},
+		{"archive", app.handleHabitDeleteAPI, "DELETE", "/api/habits/" + id, "", id},
+		{"log update", app.handleLogUpdateAPI, "PATCH", "/api/logs/" + logID, `{"qty":2}`, logID},
+		{"log delete", app.handleLogDeleteAPI, "DELETE", "/api/logs/" + logID, "", logID},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The deadline has already passed, so the lookup fails with a
+			// cancelled query rather than a missing row
+			r := apiRequest(tt.method, tt.target, tt.body, owner)
+			ctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(-time.Second))
+			defer cancel()
+			h := middleware.Timeout(time.Minute)(tt.handler)
+			w := serve(h.ServeHTTP, r.WithContext(ctx), "id", tt.path)
+			if w.Code != http.StatusGatewayTimeout {
+				t.Errorf("status = %d, want 504: %s", w.Code, w.Body)
+			}
+		})
+	}
+}
@@ -1,20 +1,36 @@
 package handlers
 
 import (
+	"bufio"
+	"context"
 	"database/sql"
+	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"os"
 	"os/exec"
+	"os/signal"
+	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
+	"unicode/utf8"
 
 	"github.com/noahjalex/epoch/internal/auth"
+	"github.com/noahjalex/epoch/internal/config"
+	"github.com/noahjalex/epoch/internal/database"
 	"github.com/noahjalex/epoch/internal/logging"
 	"github.com/noahjalex/epoch/internal/middleware"
 	"github.com/noahjalex/epoch/internal/models"
+	"github.com/noahjalex/epoch/internal/pagination"
+	"github.com/noahjalex/epoch/internal/tzcache"
 	"github.com/noahjalex/epoch/internal/utils"
+	"github.com/noahjalex/epoch/internal/version"
 	"github.com/shopspring/decimal"
 	"github.com/sirupsen/logrus"
 )
@@ -24,17 +40,80 @@ type Server struct {
 	repo      *models.Repo
 	log       *logrus.Logger
 	logConfig *logging.Config
+	cfg       *config.Config
+	captcha   auth.CaptchaVerifier
+	imports   *inflight // per-user concurrent import guard
+	lockout   *auth.LoginLockout
+	dbConfig  database.Settings // shown redacted by /api/admin/config
+	readOnly  *middleware.ReadOnly
+
+	httpSrv   *http.Server   // set by Run
+	cleanups  []func() error // run by Close, last registered first
+	closeOnce sync.Once
 }
 
-func NewServer(repo *models.Repo, log *logrus.Logger, logConfig *logging.Config) (*Server, error) {
+func NewServer(repo *models.Repo, log *logrus.Logger, logConfig *logging.Config, cfg *config.Config) (*Server, error) {
 	rend, err := NewRendererWithLogger(log)
 	if err != nil {
 		return nil, err
 	}
 
-	return &Server{rend: rend, repo: repo, log: log, logConfig: logConfig}, nil
+	return &Server{
+		rend:      rend,
+		repo:      repo,
+		log:       log,
+		logConfig: logConfig,
+		cfg:       cfg,
+		captcha:   auth.NoopCaptchaVerifier{},
+		imports:   newInflight(cfg.ImportConcurrency),
+		lockout:   auth.NewLoginLockout(cfg.LoginMaxFailures, cfg.LoginFailureWindow, cfg.LoginLockout),
+		readOnly:  middleware.NewReadOnly(cfg.ReadOnly),
+	}, nil
 }
 
+// SetCaptchaVerifier installs the verifier used to check signup captcha tokens
+func (server *Server) SetCaptchaVerifier(v auth.CaptchaVerifier) {
+	if v == nil {
+		v = auth.NoopCaptchaVerifier{}
+	}
+	server.captcha = v
+}
+
+// SetDatabaseSettings records the database configuration for /api/admin/config
+func (server *Server) SetDatabaseSettings(s database.Settings) {
+	server.dbConfig = s
+}
+
+// OnClose registers fn to run when the server is closed, e.g. closing the DB
+func (server *Server) OnClose(fn func() error) {
+	server.cleanups = append(server.cleanups, fn)
+}
+
+// Close stops the HTTP server, waiting up to the shutdown timeout for
+// in-flight requests, then runs the registered cleanups. It is safe to call
+// more than once; only the first call does anything.
+func (server *Server) Close() error {
+	var errs []error
+	server.closeOnce.Do(func() {
+		if server.httpSrv != nil {
+			ctx, cancel := context.WithTimeout(context.Background(), server.cfg.ShutdownTimeout)
+			defer cancel()
+			if err := server.httpSrv.Shutdown(ctx); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		for i := len(server.cleanups) - 1; i >= 0; i-- {
+			if err := server.cleanups[i](); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	})
+	return errors.Join(errs...)
+}
+
+// Run serves on port until SIGINT or SIGTERM, then shuts down gracefully,
+// letting in-flight requests finish within the shutdown timeout. Callers
+// should Close the server afterwards to run cleanups.
 func (server *Server) Run(port string) error {
 	open := false
 
@@ -45,99 +124,248 @@ func (server *Server) Run(port string) error {
 	// Handle requests for "/static/" by stripping the prefix and serving files
 	mux.Handle("/static/", http.StripPrefix("/static/", fs))
 
+	// Health checks, public and outside the auth middleware
+	mux.HandleFunc("GET /healthz", server.handleHealthz)
+	mux.HandleFunc("GET /readyz", server.handleReadyz)
+
 	// Apply auth middleware to ALL routes (including auth pages)
 	// The middleware will handle the logic for auth vs protected pages
 	allRoutes := http.NewServeMux()
 
 	// Auth routes - these will be handled by middleware but allowed through
 	allRoutes.HandleFunc("GET /login", server.handleLoginPage)
-	allRoutes.HandleFunc("POST /login", server.handleLogin)
+	// Login and signup share a stricter per-IP limit against brute force
+	authLimit := middleware.RateLimit(server.cfg.AuthRateLimit, server.cfg.AuthRateWindow, server.cfg.RateLimitIPHeader)
+	allRoutes.Handle("POST /login", authLimit(http.HandlerFunc(server.handleLogin)))
 	allRoutes.HandleFunc("GET /signup", server.handleSignupPage)
-	allRoutes.HandleFunc("POST /signup", server.handleSignup)
+	allRoutes.Handle("POST /signup", authLimit(http.HandlerFunc(server.handleSignup)))
 	allRoutes.HandleFunc("POST /logout", server.handleLogout)
+	allRoutes.HandleFunc("POST /api/account/logout-others", server.handleLogoutOthersAPI)
+	allRoutes.HandleFunc("POST /api/account/password", server.handlePasswordChangeAPI)
+	allRoutes.HandleFunc("POST /api/account/timezone", server.handleTimezoneChangeAPI)
 
 	// Protected routes
 	allRoutes.HandleFunc("/", server.handleHome)
 
 	// API routes
+	allRoutes.HandleFunc("GET /api/bootstrap", server.handleBootstrapAPI)
+	allRoutes.HandleFunc("GET /api/account/preferences", server.handlePreferencesGetAPI)
+	allRoutes.HandleFunc("PATCH /api/account/preferences", server.handlePreferencesUpdateAPI)
+	allRoutes.HandleFunc("GET /api/today", server.handleTodayAPI)
 	allRoutes.HandleFunc("GET /api/habits", server.handleHabitsListAPI)
 	allRoutes.HandleFunc("POST /api/habits", server.handleHabitCreateAPI)
+	allRoutes.HandleFunc("GET /api/habits/{id}", server.handleHabitGetAPI)
 	allRoutes.HandleFunc("PATCH /api/habits/{id}", server.handleHabitUpdateAPI)
 	allRoutes.HandleFunc("DELETE /api/habits/{id}", server.handleHabitDeleteAPI)
+	allRoutes.HandleFunc("POST /api/habits/{id}/restore", server.handleHabitRestoreAPI)
+	allRoutes.HandleFunc("GET /api/habits/{id}/streak", server.handleHabitStreakAPI)
+	allRoutes.HandleFunc("GET /api/habits/{id}/stats", server.handleHabitStatsAPI)
+	allRoutes.HandleFunc("POST /api/habits/{id}/refresh-stats", server.handleHabitRefreshStatsAPI)
+	allRoutes.HandleFunc("POST /api/habits/{id}/increment", server.handleHabitIncrementAPI)
+	allRoutes.HandleFunc("POST /api/habits/{id}/merge", server.handleHabitMergeAPI)
+	allRoutes.HandleFunc("GET /api/habits/{id}/buckets", server.handleHabitBucketsAPI)
+	allRoutes.HandleFunc("GET /api/habits/{id}/completion", server.handleHabitCompletionAPI)
+	allRoutes.HandleFunc("GET /api/habits/{id}/by-dow", server.handleHabitByDOWAPI)
+	allRoutes.HandleFunc("GET /api/habits/{id}/by-hour", server.handleHabitByHourAPI)
 	allRoutes.HandleFunc("GET /api/logs", server.handleLogsListAPI)
+	allRoutes.HandleFunc("GET /api/logs/timeline", server.handleLogsTimelineAPI)
+	allRoutes.HandleFunc("GET /api/logs/export.csv", server.handleLogsExportCSV)
+	allRoutes.HandleFunc("GET /api/logs/export.json", server.handleLogsExportJSON)
 	allRoutes.HandleFunc("POST /api/logs", server.handleLogCreateAPI)
+	allRoutes.HandleFunc("POST /api/logs/quick-complete", server.handleLogQuickCompleteAPI)
+	allRoutes.HandleFunc("POST /api/logs/import", server.handleLogImportAPI)
 	allRoutes.HandleFunc("PATCH /api/logs/{id}", server.handleLogUpdateAPI)
 	allRoutes.HandleFunc("DELETE /api/logs/{id}", server.handleLogDeleteAPI)
+	allRoutes.HandleFunc("POST /api/logs/{id}/restore", server.handleLogRestoreAPI)
+	allRoutes.HandleFunc("GET /api/admin/stats", server.handleAdminStatsAPI)
+	allRoutes.HandleFunc("GET /api/admin/config", server.handleAdminConfigAPI)
+	allRoutes.HandleFunc("GET /api/admin/read-only", server.handleAdminReadOnlyAPI)
+	allRoutes.HandleFunc("POST /api/admin/read-only", server.handleAdminReadOnlyAPI)
 
-	// Apply middleware in order: Request ID -> HTTP Logging -> Auth
+	// Apply middleware in order: Request ID -> Trailing slash -> HTTP Logging -> OPTIONS -> CSRF -> Timeout -> Rate limit -> Read-only -> Auth -> User rate limit -> Logger -> HEAD
 	var handler http.Handler = allRoutes
 
-	// Apply auth middleware first (innermost)
-	handler = middleware.AuthMiddleware(server.repo, server.log)(handler)
+	// HEAD on API routes runs the GET handler without a body (innermost)
+	if server.cfg.APIHead {
+		handler = middleware.HeadMiddleware("/api/")(handler)
+	}
+
+	// Handlers log through a request-scoped entry; inside auth so it knows the user
+	handler = middleware.LoggerMiddleware(server.log)(handler)
+
+	// Per-user limit, inside auth since it keys on the session's user
+	handler = middleware.UserRateLimit(server.cfg.UserRateLimit, server.cfg.UserRateWindow)(handler)
+
+	// Apply auth middleware
+	// Logout runs without a session so it stays idempotent.
+	// The landing page at "/" is public only when configured
+	publicPaths := []string{"/logout"}
+	if server.cfg.PublicLanding {
+		publicPaths = append(publicPaths, "/")
+	}
+	handler = middleware.AuthMiddleware(server.repo, server.log, server.cfg.SessionRefreshBelow, publicPaths...)(handler)
+
+	// Refuse API writes in read-only mode before touching the session store;
+	// the toggle itself stays writable so it can be switched back off
+	handler = server.readOnly.Middleware("/api/admin/read-only")(handler)
+
+	// General per-IP limit, outside auth so a flood is turned away before it
+	// costs a session lookup
+	handler = middleware.RateLimit(server.cfg.RateLimit, server.cfg.RateWindow, server.cfg.RateLimitIPHeader)(handler)
+
+	// Bound the request, including auth's session lookup. Exports stream for
+	// as long as the data takes and imports can be large, so they run unbounded
+	handler = middleware.Timeout(server.cfg.RequestTimeout,
+		"/api/logs/export.csv", "/api/logs/export.json", "/api/logs/import")(handler)
+
+	// CSRF runs outside auth so the login and signup forms are covered too
+	if server.cfg.CSRFProtection {
+		handler = middleware.CSRFMiddleware()(handler)
+	}
+
+	// OPTIONS is answered from the route table, before auth can redirect it
+	if server.cfg.OptionsAllow {
+		handler = middleware.OptionsMiddleware(allRoutes)(handler)
+	}
 
 	// Apply HTTP logging middleware if enabled
 	if server.logConfig.HTTPLogging {
-		handler = LoggingMiddleware(server.log)(handler)
+		handler = LoggingMiddleware(server.log, server.logConfig.BodyLogExempt...)(handler)
 	}
 
+	// Normalize trailing slashes before anything routes on the path
+	handler = middleware.TrailingSlashMiddleware(server.cfg.TrailingSlash)(handler)
+
 	// Apply request ID middleware (outermost)
 	handler = middleware.RequestIDMiddleware()(handler)
 
 	mux.Handle("/", handler)
 
+	var root http.Handler = mux
+	if server.cfg.ExposeVersion {
+		root = middleware.VersionMiddleware(version.Version)(root)
+	}
+
 	if open {
 		openServer(port)
 	}
 
-	server.log.WithField("port", port).Info("HTTP server listening")
-	return http.ListenAndServe(port, mux)
+	server.httpSrv = &http.Server{Addr: port, Handler: root}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- server.httpSrv.ListenAndServe()
+	}()
+
+	server.log.WithFields(logrus.Fields{
+		"port":    port,
+		"version": version.Version,
+	}).Info("HTTP server listening")
+
+	select {
+	case err := <-errCh:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
+	case <-ctx.Done():
+	}
+
+	server.log.WithField("timeout", server.cfg.ShutdownTimeout).Info("Shutdown signal received, draining requests")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), server.cfg.ShutdownTimeout)
+	defer cancel()
+	return server.httpSrv.Shutdown(shutdownCtx)
+}
+
+// healthz is the /healthz body; the template fields are only filled with
+// ?verbose=true
+type healthz struct {
+	Status        string   `json:"status"`
+	Version       string   `json:"version"`
+	TemplateCount *int     `json:"templateCount,omitempty"`
+	Templates     []string `json:"templates,omitempty"`
+}
+
+func (app *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	body := healthz{Status: "ok", Version: version.Version}
+	if verbose, _ := strconv.ParseBool(getQuery(r, "verbose")); verbose {
+		body.Templates = app.rend.Templates()
+		n := len(body.Templates)
+		body.TemplateCount = &n
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(body)
+}
+
+// readyzTimeout bounds the database ping behind /readyz
+const readyzTimeout = 2 * time.Second
+
+// readiness is the /readyz body
+type readiness struct {
+	Status    string  `json:"status"`
+	DB        string  `json:"db"`
+	LatencyMS float64 `json:"dbLatencyMs"`
+}
+
+// handleReadyz reports whether the server can take traffic: 200 when the
+// database answers a ping, 503 otherwise
+func (app *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readyzTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := app.repo.Ping(ctx)
+	body := readiness{
+		Status:    "ok",
+		DB:        "ok",
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	status := http.StatusOK
+	if err != nil {
+		app.log.WithError(err).Warn("Readiness check failed")
+		body.Status = "unavailable"
+		body.DB = "unreachable"
+		status = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
 }
 
 func (app *Server) handleHome(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	requestID := middleware.GetRequestIDFromContext(ctx)
+	log := middleware.LoggerFromContext(ctx).WithFields(logrus.Fields{
+		"component": "handler",
+		"action":    "home",
+	})
 
 	user, ok := middleware.GetUserFromContext(ctx)
 	if !ok {
-		app.log.WithFields(logrus.Fields{
-			"component":  "handler",
-			"action":     "home",
-			"request_id": requestID,
-		}).Debug("No authenticated user found in context, redirecting to login")
+		if app.cfg.PublicLanding {
+			log.Debug("No authenticated user found in context, serving landing page")
+			app.rend.Render(w, r, "landing", struct{ IsAuthPage bool }{IsAuthPage: true})
+			return
+		}
+		log.Debug("No authenticated user found in context, redirecting to login")
 		http.Redirect(w, r, "/login", http.StatusSeeOther)
 		return
 	}
 
-	app.log.WithFields(logrus.Fields{
-		"component":  "handler",
-		"action":     "home",
-		"user_id":    user.ID,
-		"username":   user.Username,
-		"request_id": requestID,
-	}).Debug("Loading home page for authenticated user")
+	log.Debug("Loading home page for authenticated user")
 
 	habits, err := app.repo.ListHabitsByUser(ctx, user.ID, true)
 	if err != nil {
-		app.log.WithFields(logrus.Fields{
-			"component":  "handler",
-			"action":     "home",
-			"user_id":    user.ID,
-			"username":   user.Username,
-			"request_id": requestID,
-			"error":      err.Error(),
-		}).Error("Database query failed while fetching user habits with details")
+		log.WithError(err).Error("Database query failed while fetching user habits with details")
 		http.Error(w, "Failed to load your habits", http.StatusInternalServerError)
 		return
 	}
 
-	app.log.WithFields(logrus.Fields{
-		"component":   "handler",
-		"action":      "home",
-		"user_id":     user.ID,
-		"username":    user.Username,
-		"request_id":  requestID,
-		"habit_count": len(habits),
-	}).Info("Successfully loaded home page with user habits")
+	log.WithField("habit_count", len(habits)).Info("Successfully loaded home page with user habits")
 
 	data := struct {
 		Habits     []models.Habit
@@ -147,7 +375,7 @@ func (app *Server) handleHome(w http.ResponseWriter, r *http.Request) {
 		IsAuthPage: false,
 	}
 
-	app.rend.Render(w, "home", data)
+	app.rend.Render(w, r, "home", data)
 }
 
 // writeNoContent returns 204 StatusNoContent and no resource
@@ -155,6 +383,40 @@ func writeNoContent(w http.ResponseWriter) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// parsePage reads limit/offset using the configured page limits.
+// It writes a 400 and returns false when they are invalid.
+func (app *Server) parsePage(w http.ResponseWriter, r *http.Request) (pagination.Page, bool) {
+	page, err := pagination.Parse(utils.New(r), pagination.Limits{
+		Default: app.cfg.PageDefaultLimit,
+		Max:     app.cfg.PageMaxLimit,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return page, false
+	}
+	return page, true
+}
+
+// decodeJSON decodes the request body into v, reading at most
+// utils.MaxBodyBytes, the same cap the form parser applies
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any) error {
+	r.Body = http.MaxBytesReader(w, r.Body, utils.MaxBodyBytes)
+	return json.NewDecoder(r.Body).Decode(v)
+}
+
+// decodeOptionalJSON decodes the request body into v like decodeJSON. An empty
+// body is not an error: it reports false so PATCH handlers can treat it as a
+// no-op update.
+func decodeOptionalJSON(w http.ResponseWriter, r *http.Request, v any) (bool, error) {
+	if err := decodeJSON(w, r, v); err != nil {
+		if errors.Is(err, io.EOF) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
 // writeCreated returns 201 StatusCreated and a JSON resource
 func writeCreated(w http.ResponseWriter, resource any) {
 	w.Header().Set("Content-Type", "application/json")
@@ -190,20 +452,55 @@ func getQuery(r *http.Request, name string) string {
 	return val
 }
 
-// Frontend data models (simplified for demo compatibility)
+// Frontend data models (simplified for demo compatibility).
+// All API JSON uses camelCase field names.
 type FrontendHabit struct {
-	ID   string  `json:"id"`
-	Name string  `json:"name"`
-	Unit string  `json:"unit"`
-	Goal float64 `json:"goal"`
+	ID        string  `json:"id"`
+	Name      string  `json:"name"`
+	Unit      string  `json:"unit"`
+	Goal      float64 `json:"goal"`
+	Agg       string  `json:"agg,omitempty"`
+	LogPolicy string  `json:"logPolicy,omitempty"`
+	// OnDuplicate is what a log at an already-logged instant does: allow,
+	// reject or replace. UpsertLogs is the older boolean form of replace and is
+	// still accepted; onDuplicate wins when both are given.
+	OnDuplicate string `json:"onDuplicate,omitempty"`
+	UpsertLogs  bool   `json:"upsertLogs"`
+
+	// Display metadata; Color is a #rgb or #rrggbb hex code
+	Color string `json:"color,omitempty"`
+	Icon  string `json:"icon,omitempty"`
+
+	// Scheduling; pointers so 0 (Sunday) is distinguishable from absent
+	Period         string `json:"period,omitempty"`
+	WeekStartDOW   *int32 `json:"weekStartDow,omitempty"`
+	MonthAnchorDay *int32 `json:"monthAnchorDay,omitempty"`
+	RollingLenDays *int32 `json:"rollingLenDays,omitempty"`
+	AnchorDate     string `json:"anchorDate,omitempty"` // YYYY-MM-DD
+	TZ             string `json:"tz,omitempty"`         // overrides the user's timezone
+}
+
+// FrontendWeek labels a week; End is the last day, inclusive
+type FrontendWeek struct {
+	Start string `json:"start"` // YYYY-MM-DD
+	End   string `json:"end"`   // YYYY-MM-DD
+	Label string `json:"label"`
+}
+
+type FrontendUser struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	Email    string `json:"email"`
+	TZ       string `json:"tz"`
 }
 
 type FrontendLog struct {
 	ID          string  `json:"id"`
 	HabitID     string  `json:"habitId"`
 	Date        string  `json:"date"`
-	DateDisplay string  `json:"date_display"`
+	DateDisplay string  `json:"dateDisplay"`
 	Qty         float64 `json:"qty"`
+	Note        string  `json:"note"`
 }
 
 // Data transformation functions
@@ -215,15 +512,99 @@ func habitToFrontend(h *models.Habit) FrontendHabit {
 
 	goal, _ := h.TargetPerPeriod.Float64()
 
+	weekStart, monthAnchor := h.WeekStartDOW, h.MonthAnchorDay
+	var rollingLen *int32
+	if h.RollingLenDays.Valid {
+		n := h.RollingLenDays.Int32
+		rollingLen = &n
+	}
+
 	return FrontendHabit{
-		ID:   fmt.Sprintf("%d", h.ID),
-		Name: h.Name,
-		Unit: unit,
-		Goal: goal,
+		ID:          fmt.Sprintf("%d", h.ID),
+		Name:        h.Name,
+		Unit:        unit,
+		Goal:        goal,
+		Agg:         string(h.Agg),
+		LogPolicy:   string(h.LogPolicy),
+		OnDuplicate: string(h.OnDuplicate),
+		UpsertLogs:  h.OnDuplicate == models.OnDuplicateReplace,
+		Color:       h.Color.String,
+		Icon:        h.Icon.String,
+
+		Period:         string(h.Period),
+		WeekStartDOW:   &weekStart,
+		MonthAnchorDay: &monthAnchor,
+		RollingLenDays: rollingLen,
+		AnchorDate:     h.AnchorDate.Format(time.DateOnly),
+		TZ:             h.TZOverride.String,
+	}
+}
+
+// ownedHabit loads habitID for user. A missing habit, or another user's,
+// answers 404 with notFound; any other error, such as the request's deadline
+// passing mid-query, answers 500 so the timeout middleware can turn it into a
+// 504. It reports whether the handler should go on.
+func (app *Server) ownedHabit(w http.ResponseWriter, r *http.Request, habitID int64, user *models.AppUser, notFound string) (*models.Habit, bool) {
+	habit, err := app.repo.GetHabit(r.Context(), habitID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Failed to get habit")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+	if err != nil || habit.UserID != user.ID {
+		http.Error(w, notFound, http.StatusNotFound)
+		return nil, false
+	}
+	return habit, true
+}
+
+// ownedLog loads a live log of one of user's habits together with that
+// habit. Another user's log reads as missing; errors other than a missing row
+// answer 500 as in ownedHabit.
+func (app *Server) ownedLog(w http.ResponseWriter, r *http.Request, logID int64, user *models.AppUser) (*models.HabitLog, *models.Habit, bool) {
+	l, err := app.repo.GetLog(r.Context(), logID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Failed to get log")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, nil, false
+	}
+	if err != nil {
+		http.Error(w, "Log not found", http.StatusNotFound)
+		return nil, nil, false
+	}
+	habit, ok := app.ownedHabit(w, r, l.HabitID, user, "Log not found")
+	if !ok {
+		return nil, nil, false
+	}
+	return l, habit, true
+}
+
+// userLocation loads the user's timezone. A stored zone that fails to load
+// is logged and treated as UTC so every handler falls back the same way.
+func (app *Server) userLocation(u *models.AppUser) *time.Location {
+	loc, err := tzcache.Load(u.TZ)
+	if err != nil {
+		app.log.WithError(err).WithFields(logrus.Fields{
+			"user_id": u.ID,
+			"tz":      u.TZ,
+		}).Warn("Invalid stored timezone, falling back to UTC")
+		return time.UTC
+	}
+	return loc
+}
+
+// apiTimeLayout picks the layout for FrontendLog.Date: models.ToFrontEndFormat
+// by default, or RFC3339 with seconds and offset when the request asks for
+// ?timeFormat=rfc3339
+func apiTimeLayout(r *http.Request) string {
+	if strings.EqualFold(getQuery(r, "timeFormat"), "rfc3339") {
+		return time.RFC3339
 	}
+	return models.ToFrontEndFormat
 }
 
-func logToFrontend(l *models.HabitLog, userTZ *time.Location) FrontendLog {
+// logToFrontend formats Date with layout; DateDisplay is always human-readable
+func logToFrontend(l *models.HabitLog, userTZ *time.Location, layout string) FrontendLog {
 	qty, _ := l.Quantity.Float64()
 
 	occurredAtInUserTZ := l.OccurredAt.In(userTZ)
@@ -231,13 +612,35 @@ func logToFrontend(l *models.HabitLog, userTZ *time.Location) FrontendLog {
 	return FrontendLog{
 		ID:          fmt.Sprintf("%d", l.ID),
 		HabitID:     fmt.Sprintf("%d", l.HabitID),
-		Date:        occurredAtInUserTZ.Format(models.ToFrontEndFormat),
+		Date:        occurredAtInUserTZ.Format(layout),
 		DateDisplay: occurredAtInUserTZ.Format(models.HumanDateFormat),
 		Qty:         qty,
+		Note:        l.Note.String,
 	}
 }
 
-func (app *Server) handleHabitsListAPI(w http.ResponseWriter, r *http.Request) {
+// weekToFrontend labels the [start, end) week, e.g. "Week of Jan 5"
+func weekToFrontend(start, end time.Time) FrontendWeek {
+	return FrontendWeek{
+		Start: start.Format(time.DateOnly),
+		End:   end.AddDate(0, 0, -1).Format(time.DateOnly),
+		Label: "Week of " + start.Format("Jan 2"),
+	}
+}
+
+func userToFrontend(u *models.AppUser) FrontendUser {
+	return FrontendUser{
+		ID:       fmt.Sprintf("%d", u.ID),
+		Username: u.Username,
+		Email:    u.Email,
+		TZ:       u.TZ,
+	}
+}
+
+// bootstrapRecentLogs is how many recent logs the bootstrap payload includes
+const bootstrapRecentLogs = 50
+
+func (app *Server) handleBootstrapAPI(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	user, ok := middleware.GetUserFromContext(ctx)
 	if !ok {
@@ -246,184 +649,105 @@ func (app *Server) handleHabitsListAPI(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	habits, err := app.repo.ListHabitsByUser(ctx, user.ID, true)
+	boot, err := app.repo.GetBootstrap(ctx, user.ID, bootstrapRecentLogs)
 	if err != nil {
-		app.log.WithError(err).Error("Failed to get habits")
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Failed to load bootstrap data")
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	// Transform to frontend format
-	frontendHabits := make([]FrontendHabit, len(habits))
-	for i, h := range habits {
+	loc := app.userLocation(user)
+
+	frontendHabits := make([]FrontendHabit, len(boot.Habits))
+	for i, h := range boot.Habits {
 		frontendHabits[i] = habitToFrontend(&h)
 	}
+	layout := apiTimeLayout(r)
+	frontendLogs := make([]FrontendLog, len(boot.RecentLogs))
+	for i, l := range boot.RecentLogs {
+		frontendLogs[i] = logToFrontend(&l, loc, layout)
+	}
+
+	// "This week" follows the user's preferred first weekday
+	weekStart, weekEnd := models.WeekBounds(time.Now(), loc, boot.Preferences.WeekStartDOW())
+
+	resp := struct {
+		Habits     []FrontendHabit `json:"habits"`
+		RecentLogs []FrontendLog   `json:"recentLogs"`
+		User       FrontendUser    `json:"user"`
+		Week       FrontendWeek    `json:"week"`
+	}{
+		Habits:     frontendHabits,
+		RecentLogs: frontendLogs,
+		User:       userToFrontend(user),
+		Week:       weekToFrontend(weekStart, weekEnd),
+	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(frontendHabits)
+	json.NewEncoder(w).Encode(resp)
 }
 
-func (app *Server) handleHabitCreateAPI(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
-	requestID := middleware.GetRequestIDFromContext(ctx)
+// maxNoteLen is the longest log note accepted, in characters
+const maxNoteLen = 1000
+
+// noteToSQL maps an empty note to SQL NULL rather than an empty string
+func noteToSQL(note string) sql.NullString {
+	note = strings.TrimSpace(note)
+	return sql.NullString{String: note, Valid: note != ""}
+}
 
+func (app *Server) handlePreferencesGetAPI(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
 	user, ok := middleware.GetUserFromContext(ctx)
 	if !ok {
-		app.log.WithFields(logrus.Fields{
-			"component":  "api",
-			"action":     "habit_create",
-			"request_id": requestID,
-		}).Warn("Unauthenticated API request to create habit")
+		// Redirect to login
 		http.Redirect(w, r, "/login", http.StatusSeeOther)
 		return
 	}
 
-	var req FrontendHabit
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		app.log.WithFields(logrus.Fields{
-			"component":  "api",
-			"action":     "habit_create",
-			"user_id":    user.ID,
-			"username":   user.Username,
-			"request_id": requestID,
-			"error":      err.Error(),
-		}).Error("Failed to decode JSON request body for habit creation")
-		http.Error(w, "Invalid JSON request body", http.StatusBadRequest)
-		return
-	}
-
-	// Validate request
-	if req.Name == "" {
-		app.log.WithFields(logrus.Fields{
-			"component":  "api",
-			"action":     "habit_create",
-			"user_id":    user.ID,
-			"username":   user.Username,
-			"request_id": requestID,
-		}).Warn("Habit creation attempted with empty name")
-		http.Error(w, "Habit name is required", http.StatusBadRequest)
-		return
-	}
-
-	app.log.WithFields(logrus.Fields{
-		"component":  "api",
-		"action":     "habit_create",
-		"user_id":    user.ID,
-		"username":   user.Username,
-		"request_id": requestID,
-		"habit_name": req.Name,
-		"habit_unit": req.Unit,
-		"habit_goal": req.Goal,
-	}).Info("Creating new habit for user")
-
-	// Transform to backend format
-	habit := &models.Habit{
-		UserID:           user.ID,
-		Name:             req.Name,
-		UnitLabel:        sql.NullString{String: req.Unit, Valid: req.Unit != ""},
-		Agg:              models.AggSum,
-		TargetPerPeriod:  decimal.NewFromFloat(req.Goal),
-		PerLogDefaultQty: decimal.NewFromFloat(1),
-		Period:           models.PeriodDaily,
-		WeekStartDOW:     1, // Monday
-		MonthAnchorDay:   1,
-		AnchorDate:       time.Now(),
-		IsActive:         true,
-	}
-
-	createdHabit, err := app.repo.CreateHabit(ctx, habit)
+	prefs, err := app.repo.GetPreferences(ctx, user.ID)
 	if err != nil {
-		app.log.WithFields(logrus.Fields{
-			"component":  "api",
-			"action":     "habit_create",
-			"user_id":    user.ID,
-			"username":   user.Username,
-			"request_id": requestID,
-			"habit_name": req.Name,
-			"error":      err.Error(),
-		}).Error("Database error while creating habit")
-		http.Error(w, "Failed to create habit", http.StatusInternalServerError)
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Failed to get preferences")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	app.log.WithFields(logrus.Fields{
-		"component":  "api",
-		"action":     "habit_create",
-		"user_id":    user.ID,
-		"username":   user.Username,
-		"request_id": requestID,
-		"habit_id":   createdHabit.ID,
-		"habit_name": createdHabit.Name,
-	}).Info("Successfully created new habit")
-
-	frontendHabit := habitToFrontend(createdHabit)
-	writeCreated(w, frontendHabit)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(prefs)
 }
 
-func (app *Server) handleHabitUpdateAPI(w http.ResponseWriter, r *http.Request) {
-	habitIDStr := r.PathValue("id")
+func (app *Server) handlePreferencesUpdateAPI(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-
-	habitID, err := strconv.ParseInt(habitIDStr, 10, 64)
-	if err != nil {
-		app.log.WithError(err).Error("Invalid habit ID")
-		http.Error(w, "Invalid habit ID", http.StatusBadRequest)
+	user, ok := middleware.GetUserFromContext(ctx)
+	if !ok {
+		// Redirect to login
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
 		return
 	}
 
-	var req FrontendHabit
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		app.log.WithError(err).Error("Failed to decode request JSON")
+	var changes models.JSONB
+	if err := decodeJSON(w, r, &changes); err != nil {
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Failed to decode request JSON")
 		http.Error(w, "Invalid JSON", http.StatusBadRequest)
 		return
 	}
-
-	habit, err := app.repo.GetHabit(ctx, habitID)
-	if err != nil {
-		app.log.WithError(err).Error("Habit not found")
-		http.Error(w, "Habit not found", http.StatusNotFound)
+	if err := models.ValidatePreferences(changes); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	// Update fields
-	habit.Name = req.Name
-	habit.UnitLabel = sql.NullString{String: req.Unit, Valid: req.Unit != ""}
-	habit.TargetPerPeriod = decimal.NewFromFloat(req.Goal)
-
-	err = app.repo.UpdateHabit(ctx, habit)
+	prefs, err := app.repo.UpdatePreferences(ctx, user.ID, changes)
 	if err != nil {
-		app.log.WithError(err).Error("Failed to update habit")
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Failed to update preferences")
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	frontendHabit := habitToFrontend(habit)
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(frontendHabit)
-}
-
-func (app *Server) handleHabitDeleteAPI(w http.ResponseWriter, r *http.Request) {
-	habitIDStr := r.PathValue("id")
-	ctx := r.Context()
-
-	habitID, err := strconv.ParseInt(habitIDStr, 10, 64)
-	if err != nil {
-		app.log.WithError(err).Error("Invalid habit ID")
-		http.Error(w, "Invalid habit ID", http.StatusBadRequest)
-		return
-	}
-
-	err = app.repo.DeleteHabit(ctx, habitID)
-	if err != nil {
-		app.log.WithError(err).Error("Failed to delete habit")
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	writeNoContent(w)
+	json.NewEncoder(w).Encode(prefs)
 }
 
-func (app *Server) handleLogsListAPI(w http.ResponseWriter, r *http.Request) {
+func (app *Server) handleHabitsListAPI(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	user, ok := middleware.GetUserFromContext(ctx)
 	if !ok {
@@ -432,36 +756,30 @@ func (app *Server) handleLogsListAPI(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get all habits for this user to filter logs
-	habits, err := app.repo.ListHabitsByUser(ctx, user.ID, false)
-	if err != nil {
-		app.log.WithError(err).Error("Failed to get habits")
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	page, ok := app.parsePage(w, r)
+	if !ok {
 		return
 	}
 
-	var allLogs []models.HabitLog
-	for _, habit := range habits {
-		logs, err := app.repo.ListLogs(ctx, habit.ID)
-		if err != nil {
-			app.log.WithError(err).Error("Failed to get logs")
-			continue
-		}
-		allLogs = append(allLogs, logs...)
+	habits, err := app.repo.ListHabitsByUserPage(ctx, user.ID, true, page.Limit, page.Offset)
+	if err != nil {
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Failed to get habits")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
 
-	loc, _ := time.LoadLocation(user.TZ)
-
 	// Transform to frontend format
-	frontendLogs := make([]FrontendLog, len(allLogs))
-	for i, l := range allLogs {
-		frontendLogs[i] = logToFrontend(&l, loc)
+	frontendHabits := make([]FrontendHabit, len(habits))
+	for i, h := range habits {
+		frontendHabits[i] = habitToFrontend(&h)
 	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(frontendLogs)
+	json.NewEncoder(w).Encode(frontendHabits)
 }
 
-func (app *Server) handleLogCreateAPI(w http.ResponseWriter, r *http.Request) {
+func (app *Server) handleHabitGetAPI(w http.ResponseWriter, r *http.Request) {
+	habitIDStr := r.PathValue("id")
 	ctx := r.Context()
 	user, ok := middleware.GetUserFromContext(ctx)
 	if !ok {
@@ -470,142 +788,1853 @@ func (app *Server) handleLogCreateAPI(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var req FrontendLog
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		app.log.WithError(err).Error("Failed to decode request JSON")
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
-		return
-	}
-	habitID, err := strconv.ParseInt(req.HabitID, 10, 64)
+	habitID, err := strconv.ParseInt(habitIDStr, 10, 64)
 	if err != nil {
-		app.log.WithError(err).Error("Invalid habit ID")
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Invalid habit ID")
 		http.Error(w, "Invalid habit ID", http.StatusBadRequest)
 		return
 	}
 
-	loc, _ := time.LoadLocation(user.TZ)
-
-	occurredAt, err := time.ParseInLocation(models.ToFrontEndFormat, req.Date, loc)
-	if err != nil {
-		app.log.WithError(err).Error("Invalid date format")
-		http.Error(w, "Invalid date format", http.StatusBadRequest)
-		return
-	}
-	log := &models.HabitLog{
-		HabitID:    habitID,
-		OccurredAt: occurredAt.UTC(),
-		Quantity:   decimal.NewFromFloat(req.Qty),
-	}
-
-	createdLog, err := app.repo.InsertLog(ctx, log)
-	if err != nil {
-		app.log.WithError(err).Error("Failed to create log")
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	habit, ok := app.ownedHabit(w, r, habitID, user, "Habit not found")
+	if !ok {
 		return
 	}
 
-	frontendLog := logToFrontend(createdLog, loc)
-	writeCreated(w, frontendLog)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(habitToFrontend(habit))
 }
 
-func (app *Server) handleLogUpdateAPI(w http.ResponseWriter, r *http.Request) {
-	logIDStr := r.PathValue("id")
+func (app *Server) handleHabitCreateAPI(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
+	log := middleware.LoggerFromContext(ctx).WithFields(logrus.Fields{
+		"component": "api",
+		"action":    "habit_create",
+	})
+
 	user, ok := middleware.GetUserFromContext(ctx)
 	if !ok {
-		// Redirect to login
+		log.Warn("Unauthenticated API request to create habit")
 		http.Redirect(w, r, "/login", http.StatusSeeOther)
 		return
 	}
 
-	logID, err := strconv.ParseInt(logIDStr, 10, 64)
-	if err != nil {
-		app.log.WithError(err).Error("Invalid log ID")
-		http.Error(w, "Invalid log ID", http.StatusBadRequest)
+	var req FrontendHabit
+	if err := decodeJSON(w, r, &req); err != nil {
+		log.WithError(err).Error("Failed to decode JSON request body for habit creation")
+		http.Error(w, "Invalid JSON request body", http.StatusBadRequest)
 		return
 	}
 
-	var req FrontendLog
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		app.log.WithError(err).Error("Failed to decode request JSON")
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+	// Validate request
+	if req.Name == "" {
+		log.Warn("Habit creation attempted with empty name")
+		http.Error(w, "Habit name is required", http.StatusBadRequest)
 		return
 	}
 
-	habitID, err := strconv.ParseInt(req.HabitID, 10, 64)
-	if err != nil {
-		app.log.WithError(err).Error("Invalid habit ID")
-		http.Error(w, "Invalid habit ID", http.StatusBadRequest)
+	if err := models.CheckNumeric("goal", decimal.NewFromFloat(req.Goal)); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	loc, _ := time.LoadLocation(user.TZ)
-	occurredAt, err := time.ParseInLocation(models.ToFrontEndFormat, req.Date, loc)
-	if err != nil {
-		app.log.WithError(err).Error("Invalid date format")
-		http.Error(w, "Invalid date format", http.StatusBadRequest)
-		return
+	// New habits start their week on the user's preferred weekday
+	weekStart := models.DefaultWeekStartDOW
+	if prefs, err := app.repo.GetPreferences(ctx, user.ID); err != nil {
+		log.WithError(err).Warn("Failed to load preferences, using default week start")
+	} else {
+		weekStart = prefs.WeekStartDOW()
 	}
 
-	log := &models.HabitLog{
-		ID:         logID,
+	// Defaults match the habit table; the request overrides any it provides
+	habit := &models.Habit{
+		UserID:           user.ID,
+		Name:             req.Name,
+		UnitLabel:        sql.NullString{String: req.Unit, Valid: req.Unit != ""},
+		Agg:              models.AggSum,
+		TargetPerPeriod:  decimal.NewFromFloat(req.Goal),
+		PerLogDefaultQty: decimal.NewFromFloat(1),
+		Period:           models.PeriodDaily,
+		WeekStartDOW:     weekStart,
+		MonthAnchorDay:   1,
+		AnchorDate:       time.Now(),
+		IsActive:         true,
+		LogPolicy:        models.LogPolicyMultiple,
+		OnDuplicate:      models.OnDuplicateAllow,
+	}
+	if err := req.applyTo(habit); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	log.WithFields(logrus.Fields{
+		"habit_name": req.Name,
+		"habit_unit": req.Unit,
+		"habit_goal": req.Goal,
+	}).Info("Creating new habit for user")
+
+	createdHabit, err := app.repo.CreateHabit(ctx, habit)
+	if err != nil {
+		log.WithError(err).WithField("habit_name", req.Name).Error("Database error while creating habit")
+		http.Error(w, "Failed to create habit", http.StatusInternalServerError)
+		return
+	}
+
+	log.WithFields(logrus.Fields{
+		"habit_id":   createdHabit.ID,
+		"habit_name": createdHabit.Name,
+	}).Info("Successfully created new habit")
+
+	frontendHabit := habitToFrontend(createdHabit)
+	writeCreated(w, frontendHabit)
+}
+
+// colorPattern matches the #rgb and #rrggbb hex codes accepted as habit colors
+var colorPattern = regexp.MustCompile(`^#([0-9A-Fa-f]{3}|[0-9A-Fa-f]{6})$`)
+
+// maxIconLen is the longest habit icon accepted, in characters
+const maxIconLen = 64
+
+// habitPatch is a partial habit update; nil fields were absent from the body
+type habitPatch struct {
+	Name        *string  `json:"name"`
+	Unit        *string  `json:"unit"`
+	Goal        *float64 `json:"goal"`
+	Agg         *string  `json:"agg"`
+	LogPolicy   *string  `json:"logPolicy"`
+	OnDuplicate *string  `json:"onDuplicate"`
+	UpsertLogs  *bool    `json:"upsertLogs"`
+	Color       *string  `json:"color"`
+	Icon        *string  `json:"icon"`
+
+	Period         *string `json:"period"`
+	WeekStartDOW   *int32  `json:"weekStartDow"`
+	MonthAnchorDay *int32  `json:"monthAnchorDay"`
+	RollingLenDays *int32  `json:"rollingLenDays"`
+	AnchorDate     *string `json:"anchorDate"`
+	TZ             *string `json:"tz"`
+}
+
+// fields applies the provided patch fields to h and maps them to habit
+// columns. h should be a copy of the stored habit so the resulting schedule
+// can be validated as a whole.
+func (p habitPatch) fields(h *models.Habit) (map[string]any, error) {
+	fields := make(map[string]any)
+	if p.Name != nil {
+		if strings.TrimSpace(*p.Name) == "" {
+			return nil, errors.New("name must not be empty")
+		}
+		h.Name = *p.Name
+		fields["name"] = h.Name
+	}
+	if p.Unit != nil {
+		h.UnitLabel = sql.NullString{String: *p.Unit, Valid: *p.Unit != ""}
+		fields["unit_label"] = h.UnitLabel
+	}
+	if p.Goal != nil {
+		goal := decimal.NewFromFloat(*p.Goal)
+		if err := models.CheckNumeric("goal", goal); err != nil {
+			return nil, err
+		}
+		h.TargetPerPeriod = goal
+		fields["target_per_period"] = goal
+	}
+	if p.Agg != nil {
+		agg, err := models.ToAggKind(*p.Agg)
+		if err != nil {
+			return nil, err
+		}
+		h.Agg = agg
+		fields["agg"] = agg
+	}
+	if p.LogPolicy != nil {
+		lp, err := models.ToLogPolicy(*p.LogPolicy)
+		if err != nil {
+			return nil, err
+		}
+		h.LogPolicy = lp
+		fields["log_policy"] = lp
+	}
+	if p.OnDuplicate != nil {
+		od, err := models.ToOnDuplicate(*p.OnDuplicate)
+		if err != nil {
+			return nil, err
+		}
+		h.OnDuplicate = od
+		fields["on_duplicate"] = od
+	} else if p.UpsertLogs != nil {
+		h.OnDuplicate = models.OnDuplicateAllow
+		if *p.UpsertLogs {
+			h.OnDuplicate = models.OnDuplicateReplace
+		}
+		fields["on_duplicate"] = h.OnDuplicate
+	}
+	if p.Color != nil {
+		// An empty color or icon clears it
+		if *p.Color != "" && !colorPattern.MatchString(*p.Color) {
+			return nil, errors.New("color must be a hex code like #3a7 or #33aa77")
+		}
+		h.Color = sql.NullString{String: *p.Color, Valid: *p.Color != ""}
+		fields["color"] = h.Color
+	}
+	if p.Icon != nil {
+		icon := strings.TrimSpace(*p.Icon)
+		if utf8.RuneCountInString(icon) > maxIconLen {
+			return nil, fmt.Errorf("icon must be at most %d characters", maxIconLen)
+		}
+		h.Icon = sql.NullString{String: icon, Valid: icon != ""}
+		fields["icon"] = h.Icon
+	}
+	if p.Period != nil {
+		h.Period = models.PeriodType(*p.Period)
+		fields["period"] = h.Period
+	}
+	if p.WeekStartDOW != nil {
+		h.WeekStartDOW = *p.WeekStartDOW
+		fields["week_start_dow"] = h.WeekStartDOW
+	}
+	if p.MonthAnchorDay != nil {
+		h.MonthAnchorDay = *p.MonthAnchorDay
+		fields["month_anchor_day"] = h.MonthAnchorDay
+	}
+	if p.RollingLenDays != nil {
+		h.RollingLenDays = sql.NullInt32{Int32: *p.RollingLenDays, Valid: true}
+		fields["rolling_len_days"] = h.RollingLenDays
+	}
+	if p.AnchorDate != nil {
+		d, err := time.Parse(time.DateOnly, *p.AnchorDate)
+		if err != nil {
+			return nil, errors.New("anchorDate must be YYYY-MM-DD")
+		}
+		h.AnchorDate = d
+		fields["anchor_date"] = d
+	}
+	if p.TZ != nil {
+		// An empty tz clears the override
+		h.TZOverride = sql.NullString{String: *p.TZ, Valid: *p.TZ != ""}
+		fields["tz"] = h.TZOverride
+	}
+	if err := h.ValidateSchedule(); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// applyTo sets the habit fields a create request provides onto h, which holds
+// the defaults, and validates the result
+func (req FrontendHabit) applyTo(h *models.Habit) error {
+	p := habitPatch{WeekStartDOW: req.WeekStartDOW, MonthAnchorDay: req.MonthAnchorDay, RollingLenDays: req.RollingLenDays}
+	// upsertLogs is a plain bool, so only true can be told apart from absent
+	if req.UpsertLogs {
+		p.UpsertLogs = &req.UpsertLogs
+	}
+	if req.OnDuplicate != "" {
+		p.OnDuplicate = &req.OnDuplicate
+	}
+	if req.Agg != "" {
+		p.Agg = &req.Agg
+	}
+	if req.LogPolicy != "" {
+		p.LogPolicy = &req.LogPolicy
+	}
+	if req.Period != "" {
+		p.Period = &req.Period
+	}
+	if req.AnchorDate != "" {
+		p.AnchorDate = &req.AnchorDate
+	}
+	if req.TZ != "" {
+		p.TZ = &req.TZ
+	}
+	if req.Color != "" {
+		p.Color = &req.Color
+	}
+	if req.Icon != "" {
+		p.Icon = &req.Icon
+	}
+	_, err := p.fields(h)
+	return err
+}
+
+func (app *Server) handleHabitUpdateAPI(w http.ResponseWriter, r *http.Request) {
+	habitIDStr := r.PathValue("id")
+	ctx := r.Context()
+	user, ok := middleware.GetUserFromContext(ctx)
+	if !ok {
+		// Redirect to login
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	habitID, err := strconv.ParseInt(habitIDStr, 10, 64)
+	if err != nil {
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Invalid habit ID")
+		http.Error(w, "Invalid habit ID", http.StatusBadRequest)
+		return
+	}
+
+	var req habitPatch
+	hasBody, err := decodeOptionalJSON(w, r, &req)
+	if err != nil {
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Failed to decode request JSON")
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	// Another user's habit is reported as not found to avoid leaking its existence
+	habit, ok := app.ownedHabit(w, r, habitID, user, "Habit not found")
+	if !ok {
+		return
+	}
+
+	// An empty body is a no-op update; return the habit unchanged
+	if hasBody {
+		candidate := *habit
+		fields, err := req.fields(&candidate)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if len(fields) == 0 {
+			http.Error(w, "At least one field must be provided", http.StatusBadRequest)
+			return
+		}
+
+		habit, err = app.repo.UpdateHabitFields(ctx, habitID, user.ID, fields)
+		if err != nil {
+			middleware.LoggerFromContext(r.Context()).WithError(err).Error("Failed to update habit")
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	frontendHabit := habitToFrontend(habit)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(frontendHabit)
+}
+
+func (app *Server) handleHabitDeleteAPI(w http.ResponseWriter, r *http.Request) {
+	habitIDStr := r.PathValue("id")
+	ctx := r.Context()
+	user, ok := middleware.GetUserFromContext(ctx)
+	if !ok {
+		// Redirect to login
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	habitID, err := strconv.ParseInt(habitIDStr, 10, 64)
+	if err != nil {
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Invalid habit ID")
+		http.Error(w, "Invalid habit ID", http.StatusBadRequest)
+		return
+	}
+
+	// Without ?hard=true the habit is only archived and can be restored
+	if hard, _ := strconv.ParseBool(getQuery(r, "hard")); !hard {
+		if _, ok := app.ownedHabit(w, r, habitID, user, "Habit not found"); !ok {
+			return
+		}
+		if err := app.repo.DeactivateHabit(ctx, habitID); err != nil {
+			middleware.LoggerFromContext(r.Context()).WithError(err).Error("Failed to archive habit")
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeNoContent(w)
+		return
+	}
+
+	modeStr := getQuery(r, "logs")
+	if modeStr == "" {
+		modeStr = app.cfg.HabitDeleteLogs
+	}
+	mode, err := models.ToHabitDeleteMode(modeStr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// DeleteHabit is scoped to the user, so another user's habit is not found
+	err = app.repo.DeleteHabit(ctx, habitID, user.ID, mode)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Habit not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, models.ErrHabitHasLogs) {
+			http.Error(w, "Habit has logs, counting deleted ones that can still be restored; use ?logs=cascade or ?logs=archive", http.StatusConflict)
+			return
+		}
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Failed to delete habit")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeNoContent(w)
+}
+
+// handleHabitRestoreAPI reactivates a habit archived by DELETE /api/habits/{id}
+func (app *Server) handleHabitRestoreAPI(w http.ResponseWriter, r *http.Request) {
+	habitIDStr := r.PathValue("id")
+	ctx := r.Context()
+	user, ok := middleware.GetUserFromContext(ctx)
+	if !ok {
+		// Redirect to login
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	habitID, err := strconv.ParseInt(habitIDStr, 10, 64)
+	if err != nil {
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Invalid habit ID")
+		http.Error(w, "Invalid habit ID", http.StatusBadRequest)
+		return
+	}
+
+	habit, ok := app.ownedHabit(w, r, habitID, user, "Habit not found")
+	if !ok {
+		return
+	}
+	if err := app.repo.ReactivateHabit(ctx, habitID); err != nil {
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Failed to restore habit")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	habit.IsActive = true
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(habitToFrontend(habit))
+}
+
+// mergeRequest names the habit whose logs are folded into the path's habit
+type mergeRequest struct {
+	MergeID string `json:"mergeId"`
+}
+
+// mergeResult is the kept habit after a merge. Warnings flag differences that
+// may make the combined logs inconsistent, such as another unit or period.
+type mergeResult struct {
+	Habit    FrontendHabit `json:"habit"`
+	Warnings []string      `json:"warnings,omitempty"`
+}
+
+// mergeWarnings lists the settings in which merge differs from keep that
+// change how its logs read once combined
+func mergeWarnings(keep, merge *models.Habit) []string {
+	var warnings []string
+	if keep.UnitLabel.String != merge.UnitLabel.String {
+		warnings = append(warnings, fmt.Sprintf("unit differs: %q was merged into %q", merge.UnitLabel.String, keep.UnitLabel.String))
+	}
+	if keep.Period != merge.Period {
+		warnings = append(warnings, fmt.Sprintf("period differs: %s was merged into %s", merge.Period, keep.Period))
+	}
+	if keep.Agg != merge.Agg {
+		warnings = append(warnings, fmt.Sprintf("aggregation differs: %s was merged into %s", merge.Agg, keep.Agg))
+	}
+	return warnings
+}
+
+// handleHabitMergeAPI moves the logs of {mergeId} onto the path's habit and
+// deletes {mergeId}. Mismatched units, periods or aggregations do not block
+// the merge; they are returned as warnings.
+func (app *Server) handleHabitMergeAPI(w http.ResponseWriter, r *http.Request) {
+	habitIDStr := r.PathValue("id")
+	ctx := r.Context()
+	user, ok := middleware.GetUserFromContext(ctx)
+	if !ok {
+		// Redirect to login
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	keepID, err := strconv.ParseInt(habitIDStr, 10, 64)
+	if err != nil {
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Invalid habit ID")
+		http.Error(w, "Invalid habit ID", http.StatusBadRequest)
+		return
+	}
+
+	var req mergeRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Failed to decode request JSON")
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	mergeID, err := strconv.ParseInt(req.MergeID, 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid mergeId", http.StatusBadRequest)
+		return
+	}
+	if mergeID == keepID {
+		http.Error(w, "A habit cannot be merged into itself", http.StatusBadRequest)
+		return
+	}
+
+	// Another user's habit is reported as not found to avoid leaking its existence
+	keep, ok := app.ownedHabit(w, r, keepID, user, "Habit not found")
+	if !ok {
+		return
+	}
+	merge, ok := app.ownedHabit(w, r, mergeID, user, "Habit not found")
+	if !ok {
+		return
+	}
+
+	warnings := mergeWarnings(keep, merge)
+	if len(warnings) > 0 {
+		middleware.LoggerFromContext(ctx).WithFields(logrus.Fields{
+			"keep_id":  keepID,
+			"merge_id": mergeID,
+			"warnings": warnings,
+		}).Warn("Merging habits with different settings")
+	}
+
+	if err := app.repo.MergeHabits(ctx, keepID, mergeID, user.ID); err != nil {
+		if errors.Is(err, models.ErrHabitNotOwned) {
+			http.Error(w, "Habit not found", http.StatusNotFound)
+			return
+		}
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Failed to merge habits")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(mergeResult{Habit: habitToFrontend(keep), Warnings: warnings})
+}
+
+func (app *Server) handleHabitStreakAPI(w http.ResponseWriter, r *http.Request) {
+	habitIDStr := r.PathValue("id")
+	ctx := r.Context()
+	user, ok := middleware.GetUserFromContext(ctx)
+	if !ok {
+		// Redirect to login
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	habitID, err := strconv.ParseInt(habitIDStr, 10, 64)
+	if err != nil {
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Invalid habit ID")
+		http.Error(w, "Invalid habit ID", http.StatusBadRequest)
+		return
+	}
+
+	if _, ok := app.ownedHabit(w, r, habitID, user, "Habit not found"); !ok {
+		return
+	}
+
+	streak, err := app.repo.HabitStreak(ctx, habitID)
+	if err != nil {
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Failed to compute habit streak")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(streak)
+}
+
+// handleTodayAPI serves the current period's value, target and met flag for
+// each active habit, so the home page can show checkmarks without aggregating
+// logs itself
+func (app *Server) handleTodayAPI(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user, ok := middleware.GetUserFromContext(ctx)
+	if !ok {
+		// Redirect to login
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	progress, err := app.repo.TodayProgress(ctx, user.ID)
+	if err != nil {
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Failed to compute today's progress")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if progress == nil {
+		progress = []models.TodayProgress{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(progress)
+}
+
+// handleHabitStatsAPI serves the habit's stats snapshot while it is younger
+// than HabitStatsTTL and recomputes it otherwise
+func (app *Server) handleHabitStatsAPI(w http.ResponseWriter, r *http.Request) {
+	habitIDStr := r.PathValue("id")
+	ctx := r.Context()
+	user, ok := middleware.GetUserFromContext(ctx)
+	if !ok {
+		// Redirect to login
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	habitID, err := strconv.ParseInt(habitIDStr, 10, 64)
+	if err != nil {
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Invalid habit ID")
+		http.Error(w, "Invalid habit ID", http.StatusBadRequest)
+		return
+	}
+
+	if _, ok := app.ownedHabit(w, r, habitID, user, "Habit not found"); !ok {
+		return
+	}
+
+	stats, err := app.repo.GetHabitStats(ctx, habitID)
+	if err != nil && err != sql.ErrNoRows {
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Failed to get habit stats")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if stats == nil || time.Since(stats.ComputedAt) >= app.cfg.HabitStatsTTL {
+		if stats, err = app.repo.RefreshHabitStats(ctx, habitID); err != nil {
+			middleware.LoggerFromContext(r.Context()).WithError(err).Error("Failed to refresh habit stats")
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+func (app *Server) handleHabitRefreshStatsAPI(w http.ResponseWriter, r *http.Request) {
+	habitIDStr := r.PathValue("id")
+	ctx := r.Context()
+	user, ok := middleware.GetUserFromContext(ctx)
+	if !ok {
+		// Redirect to login
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	habitID, err := strconv.ParseInt(habitIDStr, 10, 64)
+	if err != nil {
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Invalid habit ID")
+		http.Error(w, "Invalid habit ID", http.StatusBadRequest)
+		return
+	}
+
+	if _, ok := app.ownedHabit(w, r, habitID, user, "Habit not found"); !ok {
+		return
+	}
+
+	stats, err := app.repo.RefreshHabitStats(ctx, habitID)
+	if err != nil {
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Failed to refresh habit stats")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+// defaultBucketDays is the range served when no start/end is given
+const defaultBucketDays = 30
+
+// uncappedDefaultDays is the range endpoints that default to the longest
+// allowed range serve when AnalyticsMaxDays removes the cap
+const uncappedDefaultDays = 731
+
+// analyticsRange reads an inclusive range of whole days (YYYY-MM-DD) in loc
+// from the fromKey and toKey query parameters. A missing end is today and a
+// missing start is defaultDays before it, or the longest allowed range when
+// defaultDays is 0 (uncappedDefaultDays when there is no cap). Ranges longer
+// than AnalyticsMaxDays are rejected with the same 400 on every analytics
+// endpoint.
+func (app *Server) analyticsRange(w http.ResponseWriter, r *http.Request, loc *time.Location, fromKey, toKey string, defaultDays int) (from, to time.Time, ok bool) {
+	maxDays := app.cfg.AnalyticsMaxDays
+	if defaultDays <= 0 {
+		defaultDays = maxDays
+	}
+	if defaultDays <= 0 {
+		defaultDays = uncappedDefaultDays
+	}
+
+	fx := utils.New(r)
+	from = fx.Date(fromKey, loc)
+	to = fx.Date(toKey, loc)
+	if err := fx.Err(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return from, to, false
+	}
+	if to.IsZero() {
+		now := time.Now().In(loc)
+		to = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	}
+	if from.IsZero() {
+		from = to.AddDate(0, 0, -(defaultDays - 1))
+	}
+
+	if to.Before(from) {
+		http.Error(w, fmt.Sprintf("%s must not be before %s", toKey, fromKey), http.StatusBadRequest)
+		return from, to, false
+	}
+	// Count calendar days so a DST change inside the range does not matter
+	y1, m1, d1 := from.Date()
+	y2, m2, d2 := to.Date()
+	days := int(time.Date(y2, m2, d2, 0, 0, 0, 0, time.UTC).Sub(time.Date(y1, m1, d1, 0, 0, 0, 0, time.UTC))/(24*time.Hour)) + 1
+	if maxDays > 0 && days > maxDays {
+		http.Error(w, fmt.Sprintf("Date range is too large; the maximum is %d days", maxDays), http.StatusBadRequest)
+		return from, to, false
+	}
+	return from, to, true
+}
+
+func (app *Server) handleHabitBucketsAPI(w http.ResponseWriter, r *http.Request) {
+	habitIDStr := r.PathValue("id")
+	ctx := r.Context()
+	user, ok := middleware.GetUserFromContext(ctx)
+	if !ok {
+		// Redirect to login
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	habitID, err := strconv.ParseInt(habitIDStr, 10, 64)
+	if err != nil {
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Invalid habit ID")
+		http.Error(w, "Invalid habit ID", http.StatusBadRequest)
+		return
+	}
+
+	loc := app.userLocation(user)
+
+	// Dates are whole days (YYYY-MM-DD) in the user's timezone
+	start, end, ok := app.analyticsRange(w, r, loc, "start", "end", defaultBucketDays)
+	if !ok {
+		return
+	}
+
+	if _, ok := app.ownedHabit(w, r, habitID, user, "Habit not found"); !ok {
+		return
+	}
+
+	buckets, err := app.repo.RollupBuckets(ctx, habitID, start, end)
+	if err != nil {
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Failed to compute habit buckets")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if buckets == nil {
+		buckets = []models.BucketRow{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(buckets)
+}
+
+// completionRate is the number of periods that met target in a window
+type completionRate struct {
+	Met   int    `json:"met"`
+	Total int    `json:"total"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
+
+func (app *Server) handleHabitCompletionAPI(w http.ResponseWriter, r *http.Request) {
+	habitIDStr := r.PathValue("id")
+	ctx := r.Context()
+	user, ok := middleware.GetUserFromContext(ctx)
+	if !ok {
+		// Redirect to login
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	habitID, err := strconv.ParseInt(habitIDStr, 10, 64)
+	if err != nil {
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Invalid habit ID")
+		http.Error(w, "Invalid habit ID", http.StatusBadRequest)
+		return
+	}
+
+	loc := app.userLocation(user)
+
+	// Dates are whole days (YYYY-MM-DD) in the user's timezone, both inclusive
+	from, to, ok := app.analyticsRange(w, r, loc, "from", "to", defaultBucketDays)
+	if !ok {
+		return
+	}
+
+	if _, ok := app.ownedHabit(w, r, habitID, user, "Habit not found"); !ok {
+		return
+	}
+
+	met, total, err := app.repo.CompletionRate(ctx, habitID, from, to)
+	if err != nil {
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Failed to compute completion rate")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(completionRate{
+		Met:   met,
+		Total: total,
+		From:  from.Format(time.DateOnly),
+		To:    to.Format(time.DateOnly),
+	})
+}
+
+// dowTotal is a habit's summed quantity on one weekday
+type dowTotal struct {
+	DOW   int     `json:"dow"` // 0 = Sunday .. 6 = Saturday
+	Day   string  `json:"day"`
+	Total float64 `json:"total"`
+}
+
+func (app *Server) handleHabitByDOWAPI(w http.ResponseWriter, r *http.Request) {
+	habitIDStr := r.PathValue("id")
+	ctx := r.Context()
+	user, ok := middleware.GetUserFromContext(ctx)
+	if !ok {
+		// Redirect to login
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	habitID, err := strconv.ParseInt(habitIDStr, 10, 64)
+	if err != nil {
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Invalid habit ID")
+		http.Error(w, "Invalid habit ID", http.StatusBadRequest)
+		return
+	}
+
+	// from/to are whole days in the user's timezone, both inclusive; the
+	// default is the longest allowed range ending today
+	loc := app.userLocation(user)
+	from, to, ok := app.analyticsRange(w, r, loc, "from", "to", 0)
+	if !ok {
+		return
+	}
+
+	if _, ok := app.ownedHabit(w, r, habitID, user, "Habit not found"); !ok {
+		return
+	}
+
+	totals, err := app.repo.TotalsByDayOfWeek(ctx, habitID, from, to.AddDate(0, 0, 1))
+	if err != nil {
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Failed to compute weekday totals")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	out := make([]dowTotal, len(totals))
+	for i, t := range totals {
+		total, _ := t.Float64()
+		out[i] = dowTotal{DOW: i, Day: time.Weekday(i).String(), Total: total}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(out)
+}
+
+// hourTotal is a habit's summed quantity in one local hour of the day
+type hourTotal struct {
+	Hour  int     `json:"hour"` // 0..23
+	Total float64 `json:"total"`
+}
+
+func (app *Server) handleHabitByHourAPI(w http.ResponseWriter, r *http.Request) {
+	habitIDStr := r.PathValue("id")
+	ctx := r.Context()
+	user, ok := middleware.GetUserFromContext(ctx)
+	if !ok {
+		// Redirect to login
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	habitID, err := strconv.ParseInt(habitIDStr, 10, 64)
+	if err != nil {
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Invalid habit ID")
+		http.Error(w, "Invalid habit ID", http.StatusBadRequest)
+		return
+	}
+
+	// from/to are whole days in the user's timezone, both inclusive; the
+	// default is the longest allowed range ending today
+	loc := app.userLocation(user)
+	from, to, ok := app.analyticsRange(w, r, loc, "from", "to", 0)
+	if !ok {
+		return
+	}
+
+	if _, ok := app.ownedHabit(w, r, habitID, user, "Habit not found"); !ok {
+		return
+	}
+
+	totals, err := app.repo.TotalsByHour(ctx, habitID, from, to.AddDate(0, 0, 1))
+	if err != nil {
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Failed to compute hourly totals")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	out := make([]hourTotal, len(totals))
+	for i, t := range totals {
+		total, _ := t.Float64()
+		out[i] = hourTotal{Hour: i, Total: total}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(out)
+}
+
+// logsPage is one keyset page of logs; NextCursor is passed as ?after= to get
+// the following page and is empty on the last one
+type logsPage struct {
+	Logs       []FrontendLog `json:"logs"`
+	NextCursor string        `json:"nextCursor,omitempty"`
+}
+
+func (app *Server) handleLogsListAPI(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user, ok := middleware.GetUserFromContext(ctx)
+	if !ok {
+		// Redirect to login
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	page, ok := app.parsePage(w, r)
+	if !ok {
+		return
+	}
+
+	var filter models.LogFilter
+	if v := getQuery(r, "habit_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid habit ID", http.StatusBadRequest)
+			return
+		}
+		filter.HabitID = &id
+	}
+
+	loc := app.userLocation(user)
+
+	// from/to are whole days (YYYY-MM-DD) in the user's timezone; to is exclusive
+	fx := utils.New(r)
+	from := fx.Date("from", loc)
+	to := fx.Date("to", loc)
+	if err := fx.Err(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !from.IsZero() {
+		filter.From = &from
+	}
+	if !to.IsZero() {
+		filter.To = &to
+	}
+	if filter.From != nil && filter.To != nil && filter.To.Before(*filter.From) {
+		http.Error(w, "to must not be before from", http.StatusBadRequest)
+		return
+	}
+
+	layout := apiTimeLayout(r)
+
+	// ?after= switches to keyset pagination, newest first; an empty value
+	// starts at the newest log
+	if r.URL.Query().Has("after") {
+		if page.Offset != 0 {
+			http.Error(w, "offset cannot be combined with after", http.StatusBadRequest)
+			return
+		}
+		var after *models.LogCursor
+		if v := getQuery(r, "after"); v != "" {
+			c, err := models.ParseLogCursor(v)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			after = &c
+		}
+
+		logs, next, err := app.repo.ListLogsAfter(ctx, user.ID, filter, after, page.Limit)
+		if err != nil {
+			middleware.LoggerFromContext(r.Context()).WithError(err).Error("Failed to get logs")
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		resp := logsPage{Logs: make([]FrontendLog, len(logs))}
+		for i, l := range logs {
+			resp.Logs[i] = logToFrontend(&l, loc, layout)
+		}
+		if next != nil {
+			resp.NextCursor = next.String()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
+
+	allLogs, total, err := app.repo.ListLogsPaged(ctx, user.ID, filter, page.Limit, page.Offset)
+	if err != nil {
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Failed to get logs")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Transform to frontend format
+	frontendLogs := make([]FrontendLog, len(allLogs))
+	for i, l := range allLogs {
+		frontendLogs[i] = logToFrontend(&l, loc, layout)
+	}
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(frontendLogs)
+}
+
+// handleLogsTimelineAPI returns the logs of several habits, given as a
+// comma-separated habitIds, from the day from up to but excluding the day to
+// (YYYY-MM-DD), oldest first. As in /api/logs, to is exclusive; it defaults
+// to tomorrow and from to 30 days before it, so the default is the last 30
+// days including today. Every habit must be the user's, archived ones
+// included, or the request is a 404.
+func (app *Server) handleLogsTimelineAPI(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user, ok := middleware.GetUserFromContext(ctx)
+	if !ok {
+		// Redirect to login
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	var habitIDs []int64
+	for _, idStr := range strings.Split(getQuery(r, "habitIds"), ",") {
+		idStr = strings.TrimSpace(idStr)
+		if idStr == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid habit ID", http.StatusBadRequest)
+			return
+		}
+		habitIDs = append(habitIDs, id)
+	}
+	if len(habitIDs) == 0 {
+		http.Error(w, "At least one habit ID is required", http.StatusBadRequest)
+		return
+	}
+
+	loc := app.userLocation(user)
+
+	// from/to are whole days (YYYY-MM-DD) in the user's timezone; to is exclusive
+	fx := utils.New(r)
+	from := fx.Date("from", loc)
+	to := fx.Date("to", loc)
+	if err := fx.Err(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if to.IsZero() {
+		now := time.Now().In(loc)
+		to = time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, loc)
+	}
+	if from.IsZero() {
+		from = to.AddDate(0, 0, -defaultBucketDays)
+	}
+	if to.Before(from) {
+		http.Error(w, "to must not be before from", http.StatusBadRequest)
+		return
+	}
+	if maxDays := app.cfg.AnalyticsMaxDays; maxDays > 0 && to.After(from.AddDate(0, 0, maxDays)) {
+		http.Error(w, fmt.Sprintf("Date range is too large; the maximum is %d days", maxDays), http.StatusBadRequest)
+		return
+	}
+
+	habits, err := app.repo.ListHabitsByUser(ctx, user.ID, false)
+	if err != nil {
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Failed to get habits")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	owned := make(map[int64]bool, len(habits))
+	for _, h := range habits {
+		owned[h.ID] = true
+	}
+	for _, id := range habitIDs {
+		if !owned[id] {
+			http.Error(w, "Habit not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	logs, err := app.repo.ListLogsForHabitsWithin(ctx, habitIDs, from, to)
+	if err != nil {
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Failed to list logs")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	layout := apiTimeLayout(r)
+	out := make([]FrontendLog, len(logs))
+	for i := range logs {
+		out[i] = logToFrontend(&logs[i], loc, layout)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(out)
+}
+
+// anonLocalLayout formats anonymized times as local wall-clock time without an
+// offset, keeping daily patterns without revealing the user's timezone
+const anonLocalLayout = "2006-01-02T15:04:05"
+
+// habitLabeler replaces habit identities with "Habit 1", "Habit 2", ... in
+// order of first appearance, so an anonymized export keeps which logs share
+// a habit without naming it
+type habitLabeler map[int64]string
+
+func (hl habitLabeler) label(habitID int64) string {
+	if l, ok := hl[habitID]; ok {
+		return l
+	}
+	l := fmt.Sprintf("Habit %d", len(hl)+1)
+	hl[habitID] = l
+	return l
+}
+
+// handleLogsExportCSV streams all of the user's logs as CSV. Rows are written
+// as they are read from the database, so the export is never fully buffered.
+// With ?anonymize=true habit names become generic labels, notes are dropped
+// and times carry no offset; units and quantities are kept.
+func (app *Server) handleLogsExportCSV(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user, ok := middleware.GetUserFromContext(ctx)
+	if !ok {
+		// Redirect to login
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	loc := app.userLocation(user)
+	anonymize, _ := strconv.ParseBool(getQuery(r, "anonymize"))
+	labels := habitLabeler{}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", "attachment; filename=epoch-logs.csv")
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"habit_name", "occurred_at", "quantity", "unit", "note"}); err != nil {
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Failed to write CSV header")
+		return
+	}
+
+	err := app.repo.EachLogExportRow(ctx, user.ID, func(row models.LogExportRow) error {
+		if anonymize {
+			return cw.Write([]string{
+				labels.label(row.HabitID),
+				row.OccurredAt.In(loc).Format(anonLocalLayout),
+				row.Quantity.String(),
+				row.UnitLabel.String,
+				"",
+			})
+		}
+		return cw.Write([]string{
+			row.HabitName,
+			row.OccurredAt.In(loc).Format(time.RFC3339),
+			row.Quantity.String(),
+			row.UnitLabel.String,
+			row.Note.String,
+		})
+	})
+	cw.Flush()
+	if err == nil {
+		err = cw.Error()
+	}
+	if err != nil {
+		// Headers are already sent; the client sees a truncated file
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Failed to export logs")
+	}
+}
+
+// handleLogsExportJSON streams all of the user's logs as a JSON array of
+// FrontendLog, encoding each row as it is read. ?anonymize=true redacts it as
+// the CSV export does: ids are renumbered, habitId holds the generic label
+// and notes and display dates are dropped.
+func (app *Server) handleLogsExportJSON(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user, ok := middleware.GetUserFromContext(ctx)
+	if !ok {
+		// Redirect to login
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	loc := app.userLocation(user)
+	layout := apiTimeLayout(r)
+	anonymize, _ := strconv.ParseBool(getQuery(r, "anonymize"))
+	labels := habitLabeler{}
+	n := 0
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", "attachment; filename=epoch-logs.json")
+
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	sep := "["
+	err := app.repo.StreamLogsForUser(ctx, user.ID, func(l models.HabitLog) error {
+		if _, err := bw.WriteString(sep); err != nil {
+			return err
+		}
+		sep = ","
+		if anonymize {
+			n++
+			return enc.Encode(FrontendLog{
+				ID:      strconv.Itoa(n),
+				HabitID: labels.label(l.HabitID),
+				Date:    l.OccurredAt.In(loc).Format(anonLocalLayout),
+				Qty:     l.Quantity.InexactFloat64(),
+			})
+		}
+		return enc.Encode(logToFrontend(&l, loc, layout))
+	})
+	if err == nil {
+		if sep == "[" {
+			_, err = bw.WriteString("[]\n")
+		} else {
+			_, err = bw.WriteString("]\n")
+		}
+	}
+	if flushErr := bw.Flush(); err == nil {
+		err = flushErr
+	}
+	if err != nil {
+		// Headers are already sent; the client sees a truncated file
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Failed to export logs")
+	}
+}
+
+// checkFutureSkew rejects a log dated more than LogFutureSkew past now, which
+// is almost always a mistyped year. The limit is shown in the user's timezone.
+func (app *Server) checkFutureSkew(occurredAt time.Time, loc *time.Location) error {
+	if app.cfg.LogFutureSkew <= 0 {
+		return nil
+	}
+	limit := time.Now().Add(app.cfg.LogFutureSkew)
+	if occurredAt.After(limit) {
+		return fmt.Errorf("date is too far in the future; logs may be dated up to %s", limit.In(loc).Format("Jan 2, 2006 at 3:04pm"))
+	}
+	return nil
+}
+
+// handleLogCreateAPI creates a log under the habit's log policies. The
+// habit's onDuplicate policy decides first, for a live log at the very same
+// instant: replace updates it and reject answers 409. Only then does its
+// logPolicy apply to the whole period: single answers 409 for a second log and
+// replace drops the period's logs. With ?upsert=true both are bypassed; a log
+// already in the same habit period is updated instead (200), and a log is only
+// inserted when the period has none (201).
+func (app *Server) handleLogCreateAPI(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user, ok := middleware.GetUserFromContext(ctx)
+	if !ok {
+		// Redirect to login
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	loc := app.userLocation(user)
+
+	fx := utils.New(r)
+	habitID := fx.Int64("habitId", utils.Required())
+	occurredAt := fx.Time("date", models.ToFrontEndFormat, loc, utils.Required())
+	qty := fx.Decimal("qty", utils.MinFloat(0))
+	note := fx.String("note", utils.MaxLen(maxNoteLen))
+	snapStr := fx.String("snap")
+	if err := fx.Err(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := models.CheckQuantity("qty", qty); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := app.checkFutureSkew(occurredAt, loc); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if snapStr == "" {
+		snapStr = app.cfg.LogSnap
+	}
+	snap, err := models.ToLogSnap(snapStr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	habit, ok := app.ownedHabit(w, r, habitID, user, "Habit not found")
+	if !ok {
+		return
+	}
+
+	// Snap within the habit's own timezone so the log lands in the bucket
+	// RollupBuckets and the log policy will place it in
+	occurredAt = habit.SnapToPeriod(occurredAt, habit.Location(user.TZ), snap)
+
+	log := &models.HabitLog{
+		HabitID:    habitID,
+		OccurredAt: occurredAt.UTC(),
+		Quantity:   qty,
+		Note:       noteToSQL(note),
+	}
+
+	if upsert, _ := strconv.ParseBool(getQuery(r, "upsert")); upsert {
+		l, created, value, err := app.repo.UpsertLogForBucket(ctx, habitID, log.OccurredAt, log.Quantity, log.Note)
+		if err != nil {
+			middleware.LoggerFromContext(r.Context()).WithError(err).Error("Failed to upsert log")
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		periodValue, _ := value.Float64()
+		periodTarget, _ := habit.TargetPerPeriod.Float64()
+		resp := logWithProgress{
+			FrontendLog:  logToFrontend(l, loc, apiTimeLayout(r)),
+			PeriodValue:  periodValue,
+			PeriodTarget: periodTarget,
+		}
+		if created {
+			writeCreated(w, resp)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
+
+	// Enforces the habit's per-period log policy in the habit's own timezone
+	createdLog, value, target, err := app.repo.InsertLogWithProgress(ctx, log)
+	if err != nil {
+		if errors.Is(err, models.ErrPeriodAlreadyLogged) {
+			http.Error(w, "Habit already logged for this period", http.StatusConflict)
+			return
+		}
+		if errors.Is(err, models.ErrDuplicateLog) {
+			http.Error(w, "Habit already has a log at this time", http.StatusConflict)
+			return
+		}
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Failed to create log")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	periodValue, _ := value.Float64()
+	periodTarget, _ := target.Float64()
+	writeCreated(w, logWithProgress{
+		FrontendLog:  logToFrontend(createdLog, loc, apiTimeLayout(r)),
+		PeriodValue:  periodValue,
+		PeriodTarget: periodTarget,
+	})
+}
+
+// logWithProgress is a created or adjusted log plus the aggregate of the period it fell in
+type logWithProgress struct {
+	FrontendLog
+	PeriodValue  float64 `json:"periodValue"`
+	PeriodTarget float64 `json:"periodTarget"`
+}
+
+// incrementRequest adjusts the current period's log; Delta defaults to 1 and
+// may be negative to decrement
+type incrementRequest struct {
+	Delta *float64 `json:"delta"`
+}
+
+// handleHabitIncrementAPI bumps the latest log of the habit's current period
+// by delta, creating one if the period has none. It answers 201 when a log was
+// created and 200 when an existing one was adjusted. The result is always
+// clamped at zero; there is no allow-negative option because habit_log's
+// CHECK (quantity >= 0) forbids negative quantities for every habit.
+func (app *Server) handleHabitIncrementAPI(w http.ResponseWriter, r *http.Request) {
+	habitIDStr := r.PathValue("id")
+	ctx := r.Context()
+	user, ok := middleware.GetUserFromContext(ctx)
+	if !ok {
+		// Redirect to login
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	habitID, err := strconv.ParseInt(habitIDStr, 10, 64)
+	if err != nil {
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Invalid habit ID")
+		http.Error(w, "Invalid habit ID", http.StatusBadRequest)
+		return
+	}
+
+	var req incrementRequest
+	if _, err := decodeOptionalJSON(w, r, &req); err != nil {
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Failed to decode request JSON")
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	delta := decimal.NewFromInt(1)
+	if req.Delta != nil {
+		delta = decimal.NewFromFloat(*req.Delta)
+	}
+	if delta.IsZero() {
+		http.Error(w, "delta must not be zero", http.StatusBadRequest)
+		return
+	}
+	if err := models.CheckNumeric("delta", delta); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	habit, ok := app.ownedHabit(w, r, habitID, user, "Habit not found")
+	if !ok {
+		return
+	}
+	// Count and boolean habits ignore quantities, so adjusting one changes nothing
+	if habit.Agg == models.AggCount || habit.Agg == models.AggBoolean {
+		http.Error(w, fmt.Sprintf("%s habits do not track quantities; log instead", habit.Agg), http.StatusBadRequest)
+		return
+	}
+
+	loc := app.userLocation(user)
+	l, created, value, err := app.repo.IncrementLog(ctx, habit, user.TZ, delta, time.Now().In(loc))
+	if err != nil {
+		if errors.Is(err, models.ErrNothingToDecrement) {
+			http.Error(w, "Nothing logged this period to decrement", http.StatusConflict)
+			return
+		}
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Failed to increment log")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	periodValue, _ := value.Float64()
+	periodTarget, _ := habit.TargetPerPeriod.Float64()
+	resp := logWithProgress{
+		FrontendLog:  logToFrontend(l, loc, apiTimeLayout(r)),
+		PeriodValue:  periodValue,
+		PeriodTarget: periodTarget,
+	}
+	if created {
+		writeCreated(w, resp)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// importSummary reports the outcome of a bulk log import
+type importSummary struct {
+	Inserted int      `json:"inserted"`
+	Failed   int      `json:"failed"`
+	Errors   []string `json:"errors"`
+}
+
+// handleLogImportAPI inserts a JSON array of logs. Failures are listed by
+// their index in the array. By default valid entries are inserted even when
+// others fail, answering 207 for a mix; with ?atomic=true any failure means
+// nothing is inserted.
+func (app *Server) handleLogImportAPI(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user, ok := middleware.GetUserFromContext(ctx)
+	if !ok {
+		// Redirect to login
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	if !app.imports.acquire(user.ID) {
+		http.Error(w, "An import is already running; try again when it finishes", http.StatusConflict)
+		return
+	}
+	defer app.imports.release(user.ID)
+
+	// Rows are decoded as objects so each is validated like a created log
+	var req []map[string]any
+	if err := decodeJSON(w, r, &req); err != nil {
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Failed to decode request JSON")
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if len(req) == 0 {
+		http.Error(w, "At least one log is required", http.StatusBadRequest)
+		return
+	}
+
+	habits, err := app.repo.ListHabitsByUser(ctx, user.ID, false)
+	if err != nil {
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Failed to get habits")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	owned := make(map[int64]bool, len(habits))
+	for _, h := range habits {
+		owned[h.ID] = true
+	}
+
+	loc := app.userLocation(user)
+
+	atomic, _ := strconv.ParseBool(getQuery(r, "atomic"))
+
+	summary := importSummary{Errors: []string{}}
+	logs := make([]*models.HabitLog, 0, len(req))
+	indexes := make([]int, 0, len(req)) // position in req of each entry in logs
+	for i, entry := range req {
+		fx := utils.FromJSON(entry)
+		habitID := fx.Int64("habitId")
+		if !owned[habitID] {
+			summary.Errors = append(summary.Errors, fmt.Sprintf("log %d: habit not found", i))
+			continue
+		}
+		occurredAt := fx.Time("date", models.ToFrontEndFormat, loc, utils.Required())
+		qty := fx.Decimal("qty", utils.MinFloat(0))
+		note := fx.String("note", utils.MaxLen(maxNoteLen))
+		if err := fx.Err(); err != nil {
+			summary.Errors = append(summary.Errors, fmt.Sprintf("log %d: %v", i, err))
+			continue
+		}
+		if err := models.CheckQuantity("qty", qty); err != nil {
+			summary.Errors = append(summary.Errors, fmt.Sprintf("log %d: %v", i, err))
+			continue
+		}
+		logs = append(logs, &models.HabitLog{
+			HabitID:    habitID,
+			OccurredAt: occurredAt.UTC(),
+			Quantity:   qty,
+			Note:       noteToSQL(note),
+		})
+		indexes = append(indexes, i)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if len(logs) == 0 || (atomic && len(summary.Errors) > 0) {
+		summary.Failed = len(summary.Errors)
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(summary)
+		return
+	}
+
+	if atomic {
+		if err := app.repo.InsertLogsBatch(ctx, logs); err != nil {
+			middleware.LoggerFromContext(r.Context()).WithError(err).Error("Failed to import logs")
+			summary.Failed = len(logs)
+			summary.Errors = append(summary.Errors, err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(summary)
+			return
+		}
+		summary.Inserted = len(logs)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(summary)
+		return
+	}
+
+	rowErrs, err := app.repo.InsertLogsEach(ctx, logs)
+	if err != nil {
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Failed to import logs")
+		summary.Failed = len(req)
+		summary.Errors = append(summary.Errors, err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(summary)
+		return
+	}
+	for j, rowErr := range rowErrs {
+		if rowErr != nil {
+			summary.Errors = append(summary.Errors, fmt.Sprintf("log %d: %v", indexes[j], rowErr))
+		}
+	}
+	summary.Failed = len(summary.Errors)
+	summary.Inserted = len(req) - summary.Failed
+
+	switch {
+	case summary.Inserted == 0:
+		w.WriteHeader(http.StatusBadRequest)
+	case summary.Failed > 0:
+		w.WriteHeader(http.StatusMultiStatus)
+	default:
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(summary)
+}
+
+type quickCompleteRequest struct {
+	HabitIDs []string `json:"habitIds"`
+}
+
+func (app *Server) handleLogQuickCompleteAPI(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user, ok := middleware.GetUserFromContext(ctx)
+	if !ok {
+		// Redirect to login
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	var req quickCompleteRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Failed to decode request JSON")
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if len(req.HabitIDs) == 0 {
+		http.Error(w, "At least one habit ID is required", http.StatusBadRequest)
+		return
+	}
+
+	habitIDs := make([]int64, len(req.HabitIDs))
+	for i, idStr := range req.HabitIDs {
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			middleware.LoggerFromContext(r.Context()).WithError(err).Error("Invalid habit ID")
+			http.Error(w, "Invalid habit ID", http.StatusBadRequest)
+			return
+		}
+		habitIDs[i] = id
+	}
+
+	loc := app.userLocation(user)
+
+	logs, err := app.repo.QuickComplete(ctx, user.ID, user.TZ, habitIDs, time.Now().In(loc))
+	if err != nil {
+		if errors.Is(err, models.ErrHabitNotOwned) {
+			http.Error(w, "Habit not found", http.StatusNotFound)
+			return
+		}
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Failed to quick-complete habits")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	layout := apiTimeLayout(r)
+	frontendLogs := make([]FrontendLog, len(logs))
+	for i, l := range logs {
+		frontendLogs[i] = logToFrontend(&l, loc, layout)
+	}
+	writeCreated(w, frontendLogs)
+}
+
+func (app *Server) handleLogUpdateAPI(w http.ResponseWriter, r *http.Request) {
+	logIDStr := r.PathValue("id")
+	ctx := r.Context()
+	user, ok := middleware.GetUserFromContext(ctx)
+	if !ok {
+		// Redirect to login
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	logID, err := strconv.ParseInt(logIDStr, 10, 64)
+	if err != nil {
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Invalid log ID")
+		http.Error(w, "Invalid log ID", http.StatusBadRequest)
+		return
+	}
+
+	var req map[string]any
+	hasBody, err := decodeOptionalJSON(w, r, &req)
+	if err != nil {
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Failed to decode request JSON")
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	loc := app.userLocation(user)
+
+	// Another user's log reads as missing, as in handleLogDeleteAPI
+	existing, current, ok := app.ownedLog(w, r, logID, user)
+	if !ok {
+		return
+	}
+
+	// An empty body is a no-op update; return the log unchanged
+	if !hasBody {
+		frontendLog := logToFrontend(existing, loc, apiTimeLayout(r))
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(frontendLog)
+		return
+	}
+
+	// The body is validated the same way handleLogCreateAPI validates its form
+	fx := utils.FromJSON(req)
+	habitID := fx.Int64("habitId", utils.Required())
+	occurredAt := fx.Time("date", models.ToFrontEndFormat, loc, utils.Required())
+	qty := fx.Decimal("qty", utils.MinFloat(0))
+	note := fx.String("note", utils.MaxLen(maxNoteLen))
+	if err := fx.Err(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := models.CheckQuantity("qty", qty); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := app.checkFutureSkew(occurredAt, loc); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// The log may only move into one of the user's own habits
+	if habitID != current.ID {
+		if _, ok := app.ownedHabit(w, r, habitID, user, "Habit not found"); !ok {
+			return
+		}
+	}
+
+	log := &models.HabitLog{
+		ID:         logID,
 		HabitID:    habitID,
-		OccurredAt: occurredAt,
-		Quantity:   decimal.NewFromFloat(req.Qty),
+		OccurredAt: occurredAt.UTC(),
+		Quantity:   qty,
+		Note:       noteToSQL(note),
+	}
+
+	updated, err := app.repo.UpdateLog(ctx, user.ID, log)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Log not found", http.StatusNotFound)
+			return
+		}
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Failed to update log")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	frontendLog := logToFrontend(updated, loc, apiTimeLayout(r))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(frontendLog)
+}
+
+// handleLogDeleteAPI soft-deletes a log and returns it, so the client can
+// offer an undo through POST /api/logs/{id}/restore
+func (app *Server) handleLogDeleteAPI(w http.ResponseWriter, r *http.Request) {
+	logIDStr := r.PathValue("id")
+	ctx := r.Context()
+	user, ok := middleware.GetUserFromContext(ctx)
+	if !ok {
+		// Redirect to login
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	logID, err := strconv.ParseInt(logIDStr, 10, 64)
+	if err != nil {
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Invalid log ID")
+		http.Error(w, "Invalid log ID", http.StatusBadRequest)
+		return
+	}
+
+	if _, _, ok := app.ownedLog(w, r, logID, user); !ok {
+		return
 	}
 
-	err = app.repo.UpdateLog(ctx, log)
+	deleted, err := app.repo.SoftDeleteLog(ctx, logID)
 	if err != nil {
-		app.log.WithError(err).Error("Failed to update log")
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Log not found", http.StatusNotFound)
+			return
+		}
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Failed to delete log")
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	frontendLog := logToFrontend(log, loc)
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(frontendLog)
+	json.NewEncoder(w).Encode(logToFrontend(deleted, app.userLocation(user), apiTimeLayout(r)))
 }
 
-func (app *Server) handleLogDeleteAPI(w http.ResponseWriter, r *http.Request) {
+// handleLogRestoreAPI undoes a log deletion within the retention window
+func (app *Server) handleLogRestoreAPI(w http.ResponseWriter, r *http.Request) {
 	logIDStr := r.PathValue("id")
 	ctx := r.Context()
+	user, ok := middleware.GetUserFromContext(ctx)
+	if !ok {
+		// Redirect to login
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
 
 	logID, err := strconv.ParseInt(logIDStr, 10, 64)
 	if err != nil {
-		app.log.WithError(err).Error("Invalid log ID")
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Invalid log ID")
 		http.Error(w, "Invalid log ID", http.StatusBadRequest)
 		return
 	}
 
-	err = app.repo.DeleteLog(ctx, logID)
+	// RestoreLog is scoped to the user, so another user's log is not found
+	restored, err := app.repo.RestoreLog(ctx, logID, user.ID)
 	if err != nil {
-		app.log.WithError(err).Error("Failed to delete log")
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Deleted log not found", http.StatusNotFound)
+			return
+		}
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Failed to restore log")
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	writeNoContent(w)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(logToFrontend(restored, app.userLocation(user), apiTimeLayout(r)))
 }
 
 // ======= Authentication Handlers =======
 
+// loginPageData is the template data for the login page.
+// FieldErrors maps form field names to their validation message.
+type loginPageData struct {
+	IsAuthPage  bool
+	Error       string
+	FieldErrors map[string]string
+	Username    string
+	RememberMe  bool
+}
+
+// signupPageData is the template data for the signup page
+type signupPageData struct {
+	IsAuthPage  bool
+	Error       string
+	FieldErrors map[string]string
+	Username    string
+	Email       string
+}
+
 func (app *Server) handleLoginPage(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	user, ok := middleware.GetUserFromContext(ctx)
 	if ok && user != nil {
-		app.log.Debug("Redirecting authenticated user from login page to home")
+		middleware.LoggerFromContext(r.Context()).Debug("Redirecting authenticated user from login page to home")
 		http.Redirect(w, r, "/", http.StatusSeeOther)
 		return
 	}
 
-	data := struct {
-		IsAuthPage bool
-		Error      string
-		Username   string
-	}{
+	data := loginPageData{
 		IsAuthPage: true,
 	}
-	app.rend.Render(w, "login", data)
+	app.rend.Render(w, r, "login", data)
+}
+
+// findLoginUser resolves a login identifier: values containing "@" are tried
+// as an email first, anything else as a username first, falling back to the
+// other lookup on a miss. It returns sql.ErrNoRows when neither matches.
+func (app *Server) findLoginUser(ctx context.Context, login string) (*models.AppUser, error) {
+	lookups := []func(context.Context, string) (*models.AppUser, error){
+		app.repo.GetUserByUsername,
+		app.repo.GetUserByEmail,
+	}
+	if strings.Contains(login, "@") {
+		lookups[0], lookups[1] = lookups[1], lookups[0]
+	}
+	for _, lookup := range lookups {
+		user, err := lookup(ctx, login)
+		if err != sql.ErrNoRows {
+			return user, err
+		}
+	}
+	return nil, sql.ErrNoRows
 }
 
 func (app *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
@@ -617,75 +2646,80 @@ func (app *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
 	fx := utils.New(r)
 	username := fx.String("username", utils.Required())
 	password := fx.String("password", utils.Required())
+	remember := fx.Bool("remember_me")
 
 	if err := fx.Err(); err != nil {
-		data := struct {
-			IsAuthPage bool
-			Error      string
-			Username   string
-		}{
-			IsAuthPage: true,
-			Error:      "Username and password are required",
-			Username:   username,
+		data := loginPageData{
+			IsAuthPage:  true,
+			Error:       "Username and password are required",
+			FieldErrors: fx.FieldErrors(),
+			Username:    username,
+			RememberMe:  remember,
 		}
-		app.rend.Render(w, "login", data)
+		app.rend.Render(w, r, "login", data)
 		return
 	}
 
-	// Get user by username
-	user, err := app.repo.GetUserByUsername(r.Context(), username)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			data := struct {
-				IsAuthPage bool
-				Error      string
-				Username   string
-			}{
-				IsAuthPage: true,
-				Error:      "Invalid username or password",
-				Username:   username,
-			}
-			app.rend.Render(w, "login", data)
-			return
-		}
-		app.log.WithError(err).Error("Failed to get user")
+	// The field takes a username or an email; failures are counted against
+	// the account's username when it resolves, so switching between the two
+	// does not earn extra attempts
+	user, err := app.findLoginUser(r.Context(), username)
+	if err != nil && err != sql.ErrNoRows {
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Failed to get user")
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
+	lockKey := username
+	if user != nil {
+		lockKey = user.Username
+	}
+
+	// Locked usernames get the same answer whether or not the password is right
+	if app.lockout.Locked(lockKey) {
+		middleware.LoggerFromContext(r.Context()).WithField("login_username", lockKey).Warn("Login attempt on locked username")
+		data := loginPageData{
+			IsAuthPage: true,
+			Error:      "Too many attempts, please try again later",
+			Username:   username,
+			RememberMe: remember,
+		}
+		app.rend.Render(w, r, "login", data)
+		return
+	}
 
-	// Check password
-	if !auth.CheckPassword(password, user.PasswordHash) {
-		data := struct {
-			IsAuthPage bool
-			Error      string
-			Username   string
-		}{
+	// Unknown accounts and wrong passwords get the same answer
+	if user == nil || !auth.CheckPassword(password, user.PasswordHash) {
+		app.lockout.RecordFailure(lockKey)
+		data := loginPageData{
 			IsAuthPage: true,
 			Error:      "Invalid username or password",
 			Username:   username,
+			RememberMe: remember,
 		}
-		app.rend.Render(w, "login", data)
+		app.rend.Render(w, r, "login", data)
 		return
 	}
 
 	// Create session
 	sessionToken, err := auth.GenerateSessionToken()
 	if err != nil {
-		app.log.WithError(err).Error("Failed to generate session token")
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Failed to generate session token")
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	expiresAt := auth.GetSessionExpiry()
-	_, err = app.repo.CreateSession(r.Context(), user.ID, sessionToken, expiresAt)
+	expiresAt := auth.GetSessionExpiryFor(remember)
+	_, err = app.repo.CreateSession(r.Context(), user.ID, sessionToken, expiresAt, remember)
 	if err != nil {
-		app.log.WithError(err).Error("Failed to create session")
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Failed to create session")
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	// Set session cookie
-	middleware.SetSessionCookie(w, sessionToken)
+	app.lockout.Clear(lockKey)
+
+	// Set session cookie; without "remember me" it ends with the browser
+	middleware.SetSessionCookieFor(w, sessionToken, remember)
 
 	// Redirect to home
 	http.Redirect(w, r, "/", http.StatusSeeOther)
@@ -699,17 +2733,19 @@ func (app *Server) handleSignupPage(w http.ResponseWriter, r *http.Request) {
 		http.Redirect(w, r, "/", http.StatusSeeOther)
 		return
 	}
-	data := struct {
-		IsAuthPage bool
-		Error      string
-		Username   string
-		Email      string
-	}{
+	data := signupPageData{
 		IsAuthPage: true,
 	}
-	app.rend.Render(w, "signup", data)
+	app.rend.Render(w, r, "signup", data)
 }
 
+var (
+	// usernamePattern is the character set allowed in usernames
+	usernamePattern = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+	// emailPattern is a loose local@domain.tld check; the address is not verified
+	emailPattern = regexp.MustCompile(`^[^\s@]+@[^\s@]+\.[^\s@]+$`)
+)
+
 func (app *Server) handleSignup(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -717,110 +2753,99 @@ func (app *Server) handleSignup(w http.ResponseWriter, r *http.Request) {
 	}
 
 	fx := utils.New(r)
-	username := fx.String("username", utils.Required())
-	email := fx.String("email", utils.Required())
+	username := fx.String("username", utils.Required(), utils.MinLen(3), utils.MaxLen(50),
+		utils.Match(usernamePattern, "may only contain letters, digits, '.', '_' and '-'"))
+	email := fx.String("email", utils.Required(), utils.Match(emailPattern, "must be a valid email address"))
 	password := fx.String("password", utils.Required())
 	confirmPassword := fx.String("confirm_password", utils.Required())
-	timezone := fx.String("timezone") // optional
+	timezone := fx.String("timezone", utils.Timezone()) // optional
 
 	if err := fx.Err(); err != nil {
-		data := struct {
-			IsAuthPage bool
-			Error      string
-			Username   string
-			Email      string
-		}{
-			IsAuthPage: true,
-			Error:      "All fields are required",
-			Username:   username,
-			Email:      email,
+		data := signupPageData{
+			IsAuthPage:  true,
+			Error:       "Please fix the highlighted fields",
+			FieldErrors: fx.FieldErrors(),
+			Username:    username,
+			Email:       email,
 		}
-		app.rend.Render(w, "signup", data)
+		app.rend.Render(w, r, "signup", data)
 		return
 	}
 
-	// Validate passwords match
-	if password != confirmPassword {
-		data := struct {
-			IsAuthPage bool
-			Error      string
-			Username   string
-			Email      string
-		}{
+	// Verify captcha (no-op unless a verifier is configured)
+	captchaToken := fx.String("captcha_token")
+	if err := app.captcha.Verify(r.Context(), captchaToken, r.RemoteAddr); err != nil {
+		middleware.LoggerFromContext(r.Context()).WithError(err).Warn("Signup captcha verification failed")
+		data := signupPageData{
 			IsAuthPage: true,
-			Error:      "Passwords do not match",
+			Error:      "Captcha verification failed, please try again",
 			Username:   username,
 			Email:      email,
 		}
-		app.rend.Render(w, "signup", data)
+		app.rend.Render(w, r, "signup", data)
 		return
 	}
 
-	// Check if username already exists
-	_, err := app.repo.GetUserByUsername(r.Context(), username)
-	if err == nil {
-		data := struct {
-			IsAuthPage bool
-			Error      string
-			Username   string
-			Email      string
-		}{
-			IsAuthPage: true,
-			Error:      "Username already exists",
-			Username:   username,
-			Email:      email,
+	// Validate passwords match
+	if password != confirmPassword {
+		data := signupPageData{
+			IsAuthPage:  true,
+			Error:       "Passwords do not match",
+			FieldErrors: map[string]string{"confirm_password": "does not match password"},
+			Username:    username,
+			Email:       email,
 		}
-		app.rend.Render(w, "signup", data)
-		return
-	} else if err != sql.ErrNoRows {
-		app.log.WithError(err).Error("Failed to check username")
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		app.rend.Render(w, r, "signup", data)
 		return
 	}
 
 	// Hash password
 	passwordHash, err := auth.HashPassword(password)
 	if err != nil {
-		app.log.WithError(err).Error("Failed to hash password")
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Failed to hash password")
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	// Create user
+	// Create user. Taken usernames and emails are caught by the unique
+	// indexes rather than looked up first, and get the same message as any
+	// other failure so signup cannot be used to probe for accounts; the real
+	// reason is only logged.
 	user, err := app.repo.CreateUser(r.Context(), username, email, passwordHash, timezone)
 	if err != nil {
-		app.log.WithError(err).Error("Failed to create user")
-		data := struct {
-			IsAuthPage bool
-			Error      string
-			Username   string
-			Email      string
-		}{
+		if errors.Is(err, models.ErrUserExists) {
+			middleware.LoggerFromContext(r.Context()).WithError(err).Info("Signup rejected for an existing account")
+		} else {
+			middleware.LoggerFromContext(r.Context()).WithError(err).Error("Failed to create user")
+		}
+		data := signupPageData{
 			IsAuthPage: true,
-			Error:      "Failed to create account. Username or email may already exist.",
+			Error:      "Could not create account",
 			Username:   username,
 			Email:      email,
 		}
-		app.rend.Render(w, "signup", data)
+		app.rend.Render(w, r, "signup", data)
 		return
 	}
 
 	// Create session
 	sessionToken, err := auth.GenerateSessionToken()
 	if err != nil {
-		app.log.WithError(err).Error("Failed to generate session token")
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Failed to generate session token")
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
 	expiresAt := auth.GetSessionExpiry()
-	_, err = app.repo.CreateSession(r.Context(), user.ID, sessionToken, expiresAt)
+	_, err = app.repo.CreateSession(r.Context(), user.ID, sessionToken, expiresAt, true)
 	if err != nil {
-		app.log.WithError(err).Error("Failed to create session")
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Failed to create session")
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
+	app.lockout.Clear(username)
+
 	// Set session cookie
 	middleware.SetSessionCookie(w, sessionToken)
 
@@ -828,29 +2853,468 @@ func (app *Server) handleSignup(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
 
+// sessionRemembered reports whether the request's session is a "remember me"
+// session; replacements of it keep the same kind
+func sessionRemembered(r *http.Request) bool {
+	session, ok := middleware.GetSessionFromContext(r.Context())
+	return !ok || session.Remember
+}
+
+// renewSession rotates the current session token after a privilege change and
+// resets the cookie, when configured. Failure is logged but not fatal: the
+// operation that triggered it has already succeeded.
+func (app *Server) renewSession(w http.ResponseWriter, r *http.Request) {
+	if !app.cfg.RotateSessionOnPrivilegeChange {
+		return
+	}
+	c, err := r.Cookie(middleware.SessionCookieName)
+	if err != nil || c.Value == "" {
+		return
+	}
+	newToken, err := app.repo.RotateSession(r.Context(), c.Value)
+	if err != nil {
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Failed to rotate session")
+		return
+	}
+	middleware.SetSessionCookieFor(w, newToken, sessionRemembered(r))
+}
+
+// handleLogoutOthersAPI ends every session of the user except the current one
+func (app *Server) handleLogoutOthersAPI(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user, ok := middleware.GetUserFromContext(ctx)
+	if !ok {
+		// Redirect to login
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	c, err := r.Cookie(middleware.SessionCookieName)
+	if err != nil {
+		http.Error(w, "No session", http.StatusUnauthorized)
+		return
+	}
+
+	if err := app.repo.DeleteOtherUserSessions(ctx, user.ID, c.Value); err != nil {
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Failed to delete other sessions")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	app.renewSession(w, r)
+	writeNoContent(w)
+}
+
+// handleTimezoneChangeAPI updates the user's timezone. Auth loads the user
+// per request (or from a cache the repo invalidates here), so the next
+// request already renders times in the new zone.
+func (app *Server) handleTimezoneChangeAPI(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user, ok := middleware.GetUserFromContext(ctx)
+	if !ok {
+		// Redirect to login
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	fx := utils.New(r)
+	timezone := fx.String("timezone", utils.Required(), utils.Timezone())
+	if err := fx.Err(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := app.repo.UpdateUserTimezone(ctx, user.ID, timezone); err != nil {
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Failed to update timezone")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	updated := *user
+	updated.TZ = timezone
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(userToFrontend(&updated))
+}
+
+// handlePasswordChangeAPI changes the user's password after verifying the
+// current one. Every session is ended and the caller gets a fresh one.
+func (app *Server) handlePasswordChangeAPI(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user, ok := middleware.GetUserFromContext(ctx)
+	if !ok {
+		// Redirect to login
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	fx := utils.New(r)
+	currentPassword := fx.String("current_password", utils.Required())
+	newPassword := fx.String("new_password", utils.Required())
+	if err := fx.Err(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !auth.CheckPassword(currentPassword, user.PasswordHash) {
+		http.Error(w, "Current password is incorrect", http.StatusForbidden)
+		return
+	}
+	if len(newPassword) < auth.MinPasswordLength {
+		http.Error(w, fmt.Sprintf("New password must be at least %d characters", auth.MinPasswordLength), http.StatusBadRequest)
+		return
+	}
+	if newPassword == currentPassword {
+		http.Error(w, "New password must differ from the current one", http.StatusBadRequest)
+		return
+	}
+
+	passwordHash, err := auth.HashPassword(newPassword)
+	if err != nil {
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Failed to hash password")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if err := app.repo.UpdatePasswordHash(ctx, user.ID, passwordHash); err != nil {
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Failed to update password")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// End every session, then re-create the caller's so they stay logged in
+	if err := app.repo.DeleteUserSessions(ctx, user.ID); err != nil {
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Failed to delete sessions after password change")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	sessionToken, err := auth.GenerateSessionToken()
+	if err != nil {
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Failed to generate session token")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	remember := sessionRemembered(r)
+	if _, err := app.repo.CreateSession(ctx, user.ID, sessionToken, auth.GetSessionExpiryFor(remember), remember); err != nil {
+		middleware.LoggerFromContext(r.Context()).WithError(err).Error("Failed to create session")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	middleware.SetSessionCookieFor(w, sessionToken, remember)
+
+	middleware.LoggerFromContext(r.Context()).WithField("user_id", user.ID).Info("Password changed")
+	writeNoContent(w)
+}
+
 func (app *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	// Get session token from cookie
-	if c, err := r.Cookie("session_token"); err == nil && c.Value != "" {
+	// Auth lets /logout through without a session; a user in the context
+	// means the cookie named a live session
+	_, hasSession := middleware.GetUserFromContext(r.Context())
+	if c, err := r.Cookie(middleware.SessionCookieName); err == nil && c.Value != "" {
 		_ = app.repo.DeleteSession(r.Context(), c.Value)
 	}
 
-	http.SetCookie(w, &http.Cookie{
-		Name:     "session_token",
-		Value:    "",
-		Path:     "/", // match original Path
-		Domain:   "",  // set if you originally set it
-		HttpOnly: true,
-		SameSite: http.SameSiteLaxMode, // match original SameSite
-		Secure:   false,                // Match the setting in SetSessionCookie
-		MaxAge:   -1,                   // expire immediately (don’t rely on Expires alone)
-	})
+	middleware.ClearSessionCookie(w)
 
 	w.Header().Set("Cache-Control", "no-store")
 
+	if !hasSession && app.cfg.LogoutRequireSession {
+		http.Error(w, "No active session", http.StatusUnauthorized)
+		return
+	}
 	w.WriteHeader(http.StatusNoContent) // 204
 }
+
+// adminStats is the admin overview of users, live sessions, habits and logs.
+// Habits and Logs count current data unless IncludeDeleted is set, in which
+// case archived habits and soft-deleted logs are counted too.
+type adminStats struct {
+	Users          int  `json:"users"`
+	ActiveSessions int  `json:"activeSessions"`
+	Habits         int  `json:"habits"`
+	Logs           int  `json:"logs"`
+	IncludeDeleted bool `json:"includeDeleted"`
+}
+
+// isAdmin reports whether u is listed in the configured admin usernames
+func (app *Server) isAdmin(u *models.AppUser) bool {
+	for _, name := range app.cfg.AdminUsers {
+		if name == u.Username {
+			return true
+		}
+	}
+	return false
+}
+
+func (app *Server) handleAdminStatsAPI(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user, ok := middleware.GetUserFromContext(ctx)
+	if !ok {
+		// Redirect to login
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	// Non-admins see the same 404 as any unknown route
+	if !app.isAdmin(user) {
+		http.NotFound(w, r)
+		return
+	}
+
+	users, err := app.repo.CountUsers(ctx)
+	if err != nil {
+		middleware.LoggerFromContext(ctx).WithError(err).Error("Failed to count users")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	sessions, err := app.repo.CountActiveSessions(ctx)
+	if err != nil {
+		middleware.LoggerFromContext(ctx).WithError(err).Error("Failed to count active sessions")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	includeDeleted, _ := strconv.ParseBool(getQuery(r, "includeDeleted"))
+	habits, err := app.repo.CountHabits(ctx, includeDeleted)
+	if err != nil {
+		middleware.LoggerFromContext(ctx).WithError(err).Error("Failed to count habits")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	logs, err := app.repo.CountLogs(ctx, includeDeleted)
+	if err != nil {
+		middleware.LoggerFromContext(ctx).WithError(err).Error("Failed to count logs")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(adminStats{
+		Users:          users,
+		ActiveSessions: sessions,
+		Habits:         habits,
+		Logs:           logs,
+		IncludeDeleted: includeDeleted,
+	})
+}
+
+// redactedValue replaces a set secret in /api/admin/config; an unset secret
+// is shown empty so a missing one is still visible
+const redactedValue = "[redacted]"
+
+func redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return redactedValue
+}
+
+// adminConfig is the effective configuration served by /api/admin/config.
+// Durations are Go duration strings, as in the environment variables.
+type adminConfig struct {
+	Log struct {
+		Level         string   `json:"level"`
+		Format        string   `json:"format"`
+		Output        string   `json:"output"`
+		HTTPLogging   bool     `json:"httpLogging"`
+		BodyLogExempt []string `json:"bodyLogExempt"`
+	} `json:"log"`
+	Database struct {
+		Host            string `json:"host"`
+		Port            string `json:"port"`
+		User            string `json:"user"`
+		Password        string `json:"password"`
+		Name            string `json:"name"`
+		MaxOpenConns    int    `json:"maxOpenConns"`
+		MaxIdleConns    int    `json:"maxIdleConns"`
+		ConnMaxLifetime string `json:"connMaxLifetime"`
+		ConnMaxIdleTime string `json:"connMaxIdleTime"`
+	} `json:"database"`
+	Cookies struct {
+		Secure   bool   `json:"secure"`
+		SameSite string `json:"sameSite"`
+		Domain   string `json:"domain"`
+	} `json:"cookies"`
+	Sessions struct {
+		RefreshBelow         string `json:"refreshBelow"`
+		CacheTTL             string `json:"cacheTtl"`
+		CacheSize            int    `json:"cacheSize"`
+		ReapInterval         string `json:"reapInterval"`
+		RotateOnPrivilege    bool   `json:"rotateOnPrivilegeChange"`
+		LogoutRequireSession bool   `json:"logoutRequireSession"`
+	} `json:"sessions"`
+	Features struct {
+		PublicLanding  bool   `json:"publicLanding"`
+		ExposeVersion  bool   `json:"exposeVersion"`
+		APIHead        bool   `json:"apiHead"`
+		CSRFProtection bool   `json:"csrfProtection"`
+		OptionsAllow   bool   `json:"optionsAllow"`
+		TrailingSlash  string `json:"trailingSlash"`
+		ReadOnly       bool   `json:"readOnly"`
+	} `json:"features"`
+	Limits struct {
+		RateLimit          int    `json:"rateLimit"`
+		RateWindow         string `json:"rateWindow"`
+		UserRateLimit      int    `json:"userRateLimit"`
+		UserRateWindow     string `json:"userRateWindow"`
+		AuthRateLimit      int    `json:"authRateLimit"`
+		AuthRateWindow     string `json:"authRateWindow"`
+		RateLimitIPHeader  string `json:"rateLimitIpHeader"`
+		LoginMaxFailures   int    `json:"loginMaxFailures"`
+		LoginFailureWindow string `json:"loginFailureWindow"`
+		LoginLockout       string `json:"loginLockout"`
+		MaxBodyBytes       int64  `json:"maxBodyBytes"`
+		RequestTimeout     string `json:"requestTimeout"`
+		ShutdownTimeout    string `json:"shutdownTimeout"`
+		ImportConcurrency  int    `json:"importConcurrency"`
+		AnalyticsMaxDays   int    `json:"analyticsMaxDays"`
+		PageDefaultLimit   int    `json:"pageDefaultLimit"`
+		PageMaxLimit       int    `json:"pageMaxLimit"`
+		BcryptCost         int    `json:"bcryptCost"`
+	} `json:"limits"`
+	Habits struct {
+		DeleteLogs       string `json:"deleteLogs"`
+		LogSnap          string `json:"logSnap"`
+		LogFutureSkew    string `json:"logFutureSkew"`
+		MetGrace         string `json:"metGrace"`
+		StatsTTL         string `json:"statsTtl"`
+		LogRetention     string `json:"logRetention"`
+		LogPurgeInterval string `json:"logPurgeInterval"`
+		HabitRetention   string `json:"habitRetention"`
+	} `json:"habits"`
+	AdminUsers []string `json:"adminUsers"`
+}
+
+// effectiveConfig assembles the server's loaded configuration with secrets redacted
+func (app *Server) effectiveConfig() adminConfig {
+	var c adminConfig
+	cfg, lc, db := app.cfg, app.logConfig, app.dbConfig
+
+	if lc != nil {
+		c.Log.Level = lc.Level
+		c.Log.Format = lc.Format
+		c.Log.Output = lc.Output
+		c.Log.HTTPLogging = lc.HTTPLogging
+		c.Log.BodyLogExempt = lc.BodyLogExempt
+	}
+
+	c.Database.Host = db.Host
+	c.Database.Port = db.Port
+	c.Database.User = db.User
+	c.Database.Password = redact(db.Password)
+	c.Database.Name = db.Name
+	c.Database.MaxOpenConns = db.Pool.MaxOpenConns
+	c.Database.MaxIdleConns = db.Pool.MaxIdleConns
+	c.Database.ConnMaxLifetime = db.Pool.ConnMaxLifetime.String()
+	c.Database.ConnMaxIdleTime = db.Pool.ConnMaxIdleTime.String()
+
+	c.Cookies.Secure = cfg.SecureCookies
+	c.Cookies.SameSite = cfg.CookieSameSite
+	c.Cookies.Domain = cfg.CookieDomain
+
+	c.Sessions.RefreshBelow = cfg.SessionRefreshBelow.String()
+	c.Sessions.CacheTTL = cfg.SessionCacheTTL.String()
+	c.Sessions.CacheSize = cfg.SessionCacheSize
+	c.Sessions.ReapInterval = cfg.SessionReapInterval.String()
+	c.Sessions.RotateOnPrivilege = cfg.RotateSessionOnPrivilegeChange
+	c.Sessions.LogoutRequireSession = cfg.LogoutRequireSession
+
+	c.Features.PublicLanding = cfg.PublicLanding
+	c.Features.ExposeVersion = cfg.ExposeVersion
+	c.Features.APIHead = cfg.APIHead
+	c.Features.CSRFProtection = cfg.CSRFProtection
+	c.Features.OptionsAllow = cfg.OptionsAllow
+	c.Features.TrailingSlash = cfg.TrailingSlash
+	c.Features.ReadOnly = app.readOnly.Enabled()
+
+	c.Limits.RateLimit = cfg.RateLimit
+	c.Limits.RateWindow = cfg.RateWindow.String()
+	c.Limits.UserRateLimit = cfg.UserRateLimit
+	c.Limits.UserRateWindow = cfg.UserRateWindow.String()
+	c.Limits.AuthRateLimit = cfg.AuthRateLimit
+	c.Limits.AuthRateWindow = cfg.AuthRateWindow.String()
+	c.Limits.RateLimitIPHeader = cfg.RateLimitIPHeader
+	c.Limits.LoginMaxFailures = cfg.LoginMaxFailures
+	c.Limits.LoginFailureWindow = cfg.LoginFailureWindow.String()
+	c.Limits.LoginLockout = cfg.LoginLockout.String()
+	c.Limits.MaxBodyBytes = cfg.MaxBodyBytes
+	c.Limits.RequestTimeout = cfg.RequestTimeout.String()
+	c.Limits.ShutdownTimeout = cfg.ShutdownTimeout.String()
+	c.Limits.ImportConcurrency = cfg.ImportConcurrency
+	c.Limits.AnalyticsMaxDays = cfg.AnalyticsMaxDays
+	c.Limits.PageDefaultLimit = cfg.PageDefaultLimit
+	c.Limits.PageMaxLimit = cfg.PageMaxLimit
+	c.Limits.BcryptCost = cfg.BcryptCost
+
+	c.Habits.DeleteLogs = cfg.HabitDeleteLogs
+	c.Habits.LogSnap = cfg.LogSnap
+	c.Habits.LogFutureSkew = cfg.LogFutureSkew.String()
+	c.Habits.MetGrace = cfg.MetGrace.String()
+	c.Habits.StatsTTL = cfg.HabitStatsTTL.String()
+	c.Habits.LogRetention = cfg.LogRetention.String()
+	c.Habits.LogPurgeInterval = cfg.LogPurgeInterval.String()
+	c.Habits.HabitRetention = cfg.HabitRetention.String()
+
+	c.AdminUsers = cfg.AdminUsers
+	return c
+}
+
+// handleAdminConfigAPI serves the effective configuration to admins, with
+// secrets such as the database password redacted
+func (app *Server) handleAdminConfigAPI(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user, ok := middleware.GetUserFromContext(ctx)
+	if !ok {
+		// Redirect to login
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	// Non-admins see the same 404 as any unknown route
+	if !app.isAdmin(user) {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(app.effectiveConfig())
+}
+
+// readOnlyState is the body of /api/admin/read-only in both directions
+type readOnlyState struct {
+	Enabled bool `json:"enabled"`
+}
+
+// handleAdminReadOnlyAPI reports read-only mode on GET and sets it on POST
+// from {"enabled": bool}. The setting is in memory and resets to
+// EPOCH_READ_ONLY on restart.
+func (app *Server) handleAdminReadOnlyAPI(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user, ok := middleware.GetUserFromContext(ctx)
+	if !ok {
+		// Redirect to login
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	// Non-admins see the same 404 as any unknown route
+	if !app.isAdmin(user) {
+		http.NotFound(w, r)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		var req readOnlyState
+		if err := decodeJSON(w, r, &req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		app.readOnly.Set(req.Enabled)
+		middleware.LoggerFromContext(ctx).WithField("enabled", req.Enabled).Warn("Read-only mode changed")
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(readOnlyState{Enabled: app.readOnly.Enabled()})
+}
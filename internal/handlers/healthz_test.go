@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestHealthzReportsTemplates(t *testing.T) {
+	app := newTestServer(t, nil)
+
+	for _, tc := range []struct {
+		target string
+		want   []string
+	}{
+		{"/healthz", nil},
+		{"/healthz?verbose=true", []string{"home", "landing", "login", "signup"}},
+	} {
+		w := serve(app.handleHealthz, apiRequest("GET", tc.target, "", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d, want 200", tc.target, w.Code)
+		}
+		var got healthz
+		if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Status != "ok" || !reflect.DeepEqual(got.Templates, tc.want) {
+			t.Errorf("%s: status %q templates %v, want ok %v", tc.target, got.Status, got.Templates, tc.want)
+		}
+		if tc.want == nil && got.TemplateCount != nil {
+			t.Errorf("%s: templateCount reported without verbose", tc.target)
+		}
+		if tc.want != nil && (got.TemplateCount == nil || *got.TemplateCount != len(tc.want)) {
+			t.Errorf("%s: templateCount = %v, want %d", tc.target, got.TemplateCount, len(tc.want))
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/noahjalex/epoch/internal/config"
+)
+
+func TestHomeLoggedOut(t *testing.T) {
+	t.Run("redirects to login by default", func(t *testing.T) {
+		app := newTestServer(t, func(c *config.Config) { c.PublicLanding = false })
+		w := serve(app.handleHome, apiRequest("GET", "/", "", nil))
+		if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/login" {
+			t.Errorf("got %d to %q, want 303 to /login", w.Code, w.Header().Get("Location"))
+		}
+	})
+
+	t.Run("serves the landing page when public", func(t *testing.T) {
+		app := newTestServer(t, func(c *config.Config) { c.PublicLanding = true })
+		w := serve(app.handleHome, apiRequest("GET", "/", "", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200", w.Code)
+		}
+		if !strings.Contains(w.Body.String(), `href="/signup"`) {
+			t.Error("landing page does not link to signup")
+		}
+	})
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/noahjalex/epoch/internal/testdb"
+)
+
+func TestLogImportPartialAndAtomic(t *testing.T) {
+	app, repo := newDBServer(t, nil)
+	user := testdb.NewUser(t, repo, "UTC")
+
+	// Rows 1 and 3 are bad: an unknown habit and an unparseable date
+	body := func(habitID int64) string {
+		return fmt.Sprintf(`[
+			{"habitId":"%[1]d","date":"2024-03-10T09:00","qty":1},
+			{"habitId":"0","date":"2024-03-10T10:00","qty":1},
+			{"habitId":"%[1]d","date":"2024-03-11T09:00","qty":2},
+			{"habitId":"%[1]d","date":"yesterday","qty":1}
+		]`, habitID)
+	}
+	wantErrors := []string{"log 1: habit not found", "log 3: invalid input: date: must be a time formatted as 2006-01-02T15:04"}
+
+	for _, tc := range []struct {
+		name       string
+		target     string
+		wantStatus int
+		inserted   int
+		stored     int
+	}{
+		{"partial", "/api/logs/import", http.StatusMultiStatus, 2, 2},
+		{"atomic", "/api/logs/import?atomic=true", http.StatusBadRequest, 0, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			habit := testdb.NewHabit(t, repo, user.ID, nil)
+			w := serve(app.handleLogImportAPI, apiRequest("POST", tc.target, body(habit.ID), user))
+			if w.Code != tc.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tc.wantStatus, w.Body)
+			}
+			var got importSummary
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Inserted != tc.inserted || got.Failed != 2 || !reflect.DeepEqual(got.Errors, wantErrors) {
+				t.Errorf("summary = %+v, want %d inserted and errors %q", got, tc.inserted, wantErrors)
+			}
+
+			logs, err := repo.ListLogs(context.Background(), habit.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(logs) != tc.stored {
+				t.Errorf("%d logs stored, want %d", len(logs), tc.stored)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/noahjalex/epoch/internal/models"
+	"github.com/noahjalex/epoch/internal/testdb"
+	"github.com/shopspring/decimal"
+)
+
+func TestHabitIncrement(t *testing.T) {
+	app, repo := newDBServer(t, nil)
+	user := testdb.NewUser(t, repo, "Pacific/Auckland")
+	habit := testdb.NewHabit(t, repo, user.ID, func(h *models.Habit) { h.TargetPerPeriod = decimal.NewFromInt(10) })
+	id := fmt.Sprint(habit.ID)
+
+	steps := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantValue  float64
+	}{
+		{"decrement before any log", `{"delta":-1}`, http.StatusConflict, 0},
+		{"first increment creates a log", "", http.StatusCreated, 1},
+		{"increment updates it", `{"delta":4}`, http.StatusOK, 5},
+		{"decrement updates it", `{"delta":-2}`, http.StatusOK, 3},
+		{"decrement clamps at zero", `{"delta":-10}`, http.StatusOK, 0},
+		{"zero delta", `{"delta":0}`, http.StatusBadRequest, 0},
+	}
+	var logID string
+	for _, s := range steps {
+		w := serve(app.handleHabitIncrementAPI, apiRequest("POST", "/api/habits/"+id+"/increment", s.body, user), "id", id)
+		if w.Code != s.wantStatus {
+			t.Fatalf("%s: status = %d, want %d: %s", s.name, w.Code, s.wantStatus, w.Body)
+		}
+		if w.Code != http.StatusOK && w.Code != http.StatusCreated {
+			continue
+		}
+		var got logWithProgress
+		if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		if got.PeriodValue != s.wantValue || got.PeriodTarget != 10 {
+			t.Errorf("%s: period %v/%v, want %v/10", s.name, got.PeriodValue, got.PeriodTarget, s.wantValue)
+		}
+		if logID == "" {
+			logID = got.ID
+		} else if got.ID != logID {
+			t.Errorf("%s: adjusted log %s, want %s", s.name, got.ID, logID)
+		}
+	}
+}
+
+func TestHabitIncrementRejectsCountHabitsAndOtherUsers(t *testing.T) {
+	app, repo := newDBServer(t, nil)
+	owner := testdb.NewUser(t, repo, "")
+	other := testdb.NewUser(t, repo, "")
+	counter := testdb.NewHabit(t, repo, owner.ID, func(h *models.Habit) { h.Agg = models.AggCount })
+	summed := testdb.NewHabit(t, repo, owner.ID, nil)
+
+	for _, tt := range []struct {
+		name    string
+		habitID int64
+		user    *models.AppUser
+		want    int
+	}{
+		{"count habit", counter.ID, owner, http.StatusBadRequest},
+		{"another user's habit", summed.ID, other, http.StatusNotFound},
+	} {
+		id := fmt.Sprint(tt.habitID)
+		w := serve(app.handleHabitIncrementAPI, apiRequest("POST", "/api/habits/"+id+"/increment", "", tt.user), "id", id)
+		if w.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, w.Code, tt.want)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package handlers
+
+import "sync"
+
+// inflight counts running operations per user and refuses new ones past a
+// limit. The zero value is not usable; see newInflight.
+type inflight struct {
+	mu    sync.Mutex
+	limit int
+	count map[int64]int
+}
+
+// newInflight allows up to limit concurrent operations per user; a
+// non-positive limit means no limit
+func newInflight(limit int) *inflight {
+	return &inflight{limit: limit, count: make(map[int64]int)}
+}
+
+// acquire claims a slot for userID, reporting false when the user is at the
+// limit. Every successful acquire must be paired with a release.
+func (f *inflight) acquire(userID int64) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.limit > 0 && f.count[userID] >= f.limit {
+		return false
+	}
+	f.count[userID]++
+	return true
+}
+
+func (f *inflight) release(userID int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.count[userID] <= 1 {
+		delete(f.count, userID)
+		return
+	}
+	f.count[userID]--
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/noahjalex/epoch/internal/config"
+	"github.com/noahjalex/epoch/internal/models"
+)
+
+func TestInflight(t *testing.T) {
+	f := newInflight(2)
+	if !f.acquire(1) || !f.acquire(1) {
+		t.Fatal("slots under the limit refused")
+	}
+	if f.acquire(1) {
+		t.Error("third concurrent acquire allowed")
+	}
+	if !f.acquire(2) {
+		t.Error("another user was refused")
+	}
+	f.release(1)
+	if !f.acquire(1) {
+		t.Error("released slot not reusable")
+	}
+	f.release(1)
+	f.release(1)
+	if len(f.count) != 1 {
+		t.Errorf("count = %v, want only user 2 left", f.count)
+	}
+
+	unlimited := newInflight(0)
+	for i := 0; i < 5; i++ {
+		if !unlimited.acquire(1) {
+			t.Fatal("unlimited guard refused")
+		}
+	}
+}
+
+func TestLogImportRejectsConcurrentImport(t *testing.T) {
+	app := newTestServer(t, func(c *config.Config) { c.ImportConcurrency = 1 })
+	user := &models.AppUser{ID: 42}
+
+	// An import already running holds the user's only slot; the server has no
+	// repository, so the second one must be turned away before using it
+	app.imports.acquire(user.ID)
+	w := serve(app.handleLogImportAPI, apiRequest("POST", "/api/logs/import", `[]`, user))
+	if w.Code != http.StatusConflict {
+		t.Errorf("concurrent import status = %d, want 409", w.Code)
+	}
+
+	// Once it finishes the next import runs, and its slot is released even
+	// when it fails
+	app.imports.release(user.ID)
+	w = serve(app.handleLogImportAPI, apiRequest("POST", "/api/logs/import", `[]`, user))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("import after release status = %d, want 400 for the empty body", w.Code)
+	}
+	if !app.imports.acquire(user.ID) {
+		t.Error("failed import kept its slot")
+	}
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/noahjalex/epoch/internal/models"
+)
+
+var camelCase = regexp.MustCompile(`^[a-z][A-Za-z0-9]*$`)
+
+// checkJSONNames reports every JSON field name in t, including nested
+// structs, that is not camelCase
+func checkJSONNames(t *testing.T, typ reflect.Type, path string) {
+	t.Helper()
+	for typ.Kind() == reflect.Pointer || typ.Kind() == reflect.Slice || typ.Kind() == reflect.Map {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct || typ == reflect.TypeOf(time.Time{}) || typ.PkgPath() == "github.com/shopspring/decimal" {
+		return
+	}
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" && f.Anonymous {
+			// Embedded structs are flattened into their parent
+			checkJSONNames(t, f.Type, path)
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		if !camelCase.MatchString(name) {
+			t.Errorf("%s.%s has JSON name %q, want camelCase", path, f.Name, name)
+		}
+		checkJSONNames(t, f.Type, path+"."+name)
+	}
+}
+
+func TestAPIJSONNamesAreCamelCase(t *testing.T) {
+	for _, v := range []any{
+		FrontendHabit{}, FrontendWeek{}, FrontendUser{}, FrontendLog{},
+		healthz{}, readiness{}, mergeResult{}, completionRate{}, dowTotal{}, hourTotal{},
+		logsPage{}, logWithProgress{}, importSummary{}, adminStats{}, adminConfig{}, readOnlyState{},
+		models.BucketRow{}, models.Streak{}, models.TodayProgress{}, models.HabitStats{},
+	} {
+		typ := reflect.TypeOf(v)
+		checkJSONNames(t, typ, typ.Name())
+	}
+}
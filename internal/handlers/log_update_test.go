@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/noahjalex/epoch/internal/testdb"
+)
+
+func TestLogUpdateEmptyBodyReturnsUnchangedLog(t *testing.T) {
+	app, repo := newDBServer(t, nil)
+	user := testdb.NewUser(t, repo, "")
+	habit := testdb.NewHabit(t, repo, user.ID, nil)
+	l := testdb.NewLog(t, repo, habit.ID, time.Now().Add(-time.Hour), 3)
+	id := fmt.Sprint(l.ID)
+
+	w := serve(app.handleLogUpdateAPI, apiRequest("PATCH", "/api/logs/"+id, "", user), "id", id)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+	var got FrontendLog
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != id || got.Qty != 3 {
+		t.Errorf("got %+v, want log %s with qty 3", got, id)
+	}
+}
+
+func TestLogUpdateRejectsOtherUsersLogs(t *testing.T) {
+	app, repo := newDBServer(t, nil)
+	owner := testdb.NewUser(t, repo, "")
+	other := testdb.NewUser(t, repo, "")
+	ownerHabit := testdb.NewHabit(t, repo, owner.ID, nil)
+	otherHabit := testdb.NewHabit(t, repo, other.ID, nil)
+	ownerLog := testdb.NewLog(t, repo, ownerHabit.ID, time.Now().Add(-time.Hour), 3)
+	otherLog := testdb.NewLog(t, repo, otherHabit.ID, time.Now().Add(-time.Hour), 5)
+	date := time.Now().Add(-time.Hour).UTC().Format("2006-01-02T15:04")
+
+	tests := []struct {
+		name  string
+		logID int64
+		body  string
+	}{
+		{"read another user's log", ownerLog.ID, ""},
+		{"overwrite another user's log", ownerLog.ID, fmt.Sprintf(`{"habitId":"%d","date":"%s","qty":9}`, ownerHabit.ID, date)},
+		{"move another user's log into own habit", ownerLog.ID, fmt.Sprintf(`{"habitId":"%d","date":"%s","qty":9}`, otherHabit.ID, date)},
+		{"move own log into another user's habit", otherLog.ID, fmt.Sprintf(`{"habitId":"%d","date":"%s","qty":9}`, ownerHabit.ID, date)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id := fmt.Sprint(tt.logID)
+			w := serve(app.handleLogUpdateAPI, apiRequest("PATCH", "/api/logs/"+id, tt.body, other), "id", id)
+			if w.Code != http.StatusNotFound {
+				t.Fatalf("status = %d, want 404: %s", w.Code, w.Body)
+			}
+		})
+	}
+
+	for _, l := range []struct {
+		id      int64
+		habitID int64
+		qty     int64
+	}{{ownerLog.ID, ownerHabit.ID, 3}, {otherLog.ID, otherHabit.ID, 5}} {
+		got, err := repo.GetLog(context.Background(), l.id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.HabitID != l.habitID || got.Quantity.IntPart() != l.qty {
+			t.Errorf("log %d changed to habit %d qty %s", l.id, got.HabitID, got.Quantity)
+		}
+	}
+}
+
+func TestLogUpdateWithinOwnHabits(t *testing.T) {
+	app, repo := newDBServer(t, nil)
+	user := testdb.NewUser(t, repo, "")
+	from := testdb.NewHabit(t, repo, user.ID, nil)
+	to := testdb.NewHabit(t, repo, user.ID, nil)
+	l := testdb.NewLog(t, repo, from.ID, time.Now().Add(-time.Hour), 3)
+	id := fmt.Sprint(l.ID)
+	date := time.Now().Add(-time.Hour).UTC().Format("2006-01-02T15:04")
+
+	body := fmt.Sprintf(`{"habitId":"%d","date":"%s","qty":7}`, to.ID, date)
+	w := serve(app.handleLogUpdateAPI, apiRequest("PATCH", "/api/logs/"+id, body, user), "id", id)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+	got, err := repo.GetLog(context.Background(), l.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.HabitID != to.ID || got.Quantity.IntPart() != 7 {
+		t.Errorf("log is habit %d qty %s, want habit %d qty 7", got.HabitID, got.Quantity, to.ID)
+	}
+}
+
+func TestLogDateErrorsMatchAcrossCreateUpdateImport(t *testing.T) {
+	app, repo := newDBServer(t, nil)
+	user := testdb.NewUser(t, repo, "")
+	habit := testdb.NewHabit(t, repo, user.ID, nil)
+	l := testdb.NewLog(t, repo, habit.ID, time.Now().Add(-time.Hour), 3)
+	id := fmt.Sprint(l.ID)
+	const want = "invalid input: date: must be a time formatted as 2006-01-02T15:04"
+
+	body := fmt.Sprintf(`{"habitId":"%d","date":"yesterday","qty":1}`, habit.ID)
+	for name, w := range map[string]*httptest.ResponseRecorder{
+		"create": serve(app.handleLogCreateAPI, apiRequest("POST", "/api/logs", body, user)),
+		"update": serve(app.handleLogUpdateAPI, apiRequest("PATCH", "/api/logs/"+id, body, user), "id", id),
+	} {
+		if w.Code != http.StatusBadRequest || strings.TrimSpace(w.Body.String()) != want {
+			t.Errorf("%s: %d %q, want 400 %q", name, w.Code, w.Body, want)
+		}
+	}
+
+	w := serve(app.handleLogImportAPI, apiRequest("POST", "/api/logs/import", "["+body+"]", user))
+	var got importSummary
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Errors) != 1 || got.Errors[0] != "log 0: "+want {
+		t.Errorf("import errors = %q, want [%q]", got.Errors, "log 0: "+want)
+	}
+}
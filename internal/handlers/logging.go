@@ -65,18 +65,33 @@ func isTextLike(ct string) bool {
 		strings.Contains(ct, "form-urlencoded")
 }
 
+// bodyLogExempt reports whether path falls under one of the exempt prefixes
+func bodyLogExempt(path string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(path, p) {
+			return true
+		}
+	}
+	return false
+}
+
 // LoggingMiddleware logs method, path, headers, (truncated) body, status, size, duration.
-func LoggingMiddleware(log *logrus.Logger) func(http.Handler) http.Handler {
+// Bodies of requests under an exemptPrefixes path are neither read nor logged.
+func LoggingMiddleware(log *logrus.Logger, exemptPrefixes ...string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			start := time.Now()
+			exempt := bodyLogExempt(r.URL.Path, exemptPrefixes)
 
-			// Read and restore request body
+			// Read the head of the body for the log and hand the handler the
+			// whole stream, so its own size limit still applies
 			var body []byte
-			if r.Body != nil {
+			if r.Body != nil && !exempt {
 				body, _ = io.ReadAll(io.LimitReader(r.Body, maxBodyLog+1))
-				_ = r.Body.Close()
-				r.Body = io.NopCloser(bytes.NewReader(body)) // restore for handler
+				r.Body = struct {
+					io.Reader
+					io.Closer
+				}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
 			}
 
 			lrw := &loggingRW{ResponseWriter: w, status: http.StatusOK}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/noahjalex/epoch/internal/auth"
+	"github.com/noahjalex/epoch/internal/middleware"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func loginRequest(login, password string) *http.Request {
+	form := url.Values{"username": {login}, "password": {password}}
+	r := httptest.NewRequest("POST", "/login", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return r
+}
+
+func TestLoginByUsernameOrEmail(t *testing.T) {
+	app, repo := newDBServer(t, nil)
+	ctx := context.Background()
+	hash, err := auth.HashPasswordWithCost("correct horse", bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := fmt.Sprintf("login%d", time.Now().UnixNano())
+	if _, err := repo.CreateUser(ctx, name, name+"@example.com", hash, "UTC"); err != nil {
+		t.Fatal(err)
+	}
+	// Older accounts may have an @ in the username; the email lookup misses
+	// and the username one is tried next
+	legacy := name + "@legacy"
+	if _, err := repo.CreateUser(ctx, legacy, name+".legacy@example.com", hash, "UTC"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		login    string
+		password string
+		ok       bool
+	}{
+		{"username", name, "correct horse", true},
+		{"email", name + "@example.com", "correct horse", true},
+		{"username with @", legacy, "correct horse", true},
+		{"wrong password by username", name, "wrong", false},
+		{"wrong password by email", name + "@example.com", "wrong", false},
+		{"unknown email", "nobody-" + name + "@example.com", "correct horse", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(app.handleLogin, loginRequest(tt.login, tt.password))
+			if tt.ok {
+				if w.Code != http.StatusSeeOther {
+					t.Fatalf("status = %d, want 303", w.Code)
+				}
+				var session bool
+				for _, c := range w.Result().Cookies() {
+					session = session || c.Name == middleware.SessionCookieName && c.Value != ""
+				}
+				if !session {
+					t.Error("no session cookie set")
+				}
+				return
+			}
+			if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Invalid username or password") {
+				t.Errorf("status = %d, want the login page with the generic error", w.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/noahjalex/epoch/internal/config"
+	"github.com/noahjalex/epoch/internal/middleware"
+	"github.com/noahjalex/epoch/internal/testdb"
+)
+
+// clearsSessionCookie reports whether w expires the session cookie
+func clearsSessionCookie(w *httptest.ResponseRecorder) bool {
+	for _, c := range w.Result().Cookies() {
+		if c.Name == middleware.SessionCookieName && c.MaxAge < 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func TestLogoutWithoutSession(t *testing.T) {
+	for _, tc := range []struct {
+		require bool
+		want    int
+	}{
+		{false, http.StatusNoContent},
+		{true, http.StatusUnauthorized},
+	} {
+		app := newTestServer(t, func(c *config.Config) { c.LogoutRequireSession = tc.require })
+		w := serve(app.handleLogout, apiRequest("POST", "/logout", "", nil))
+		if w.Code != tc.want {
+			t.Errorf("require session %v: status = %d, want %d", tc.require, w.Code, tc.want)
+		}
+		if !clearsSessionCookie(w) {
+			t.Errorf("require session %v: session cookie not cleared", tc.require)
+		}
+	}
+}
+
+func TestLogoutWithSession(t *testing.T) {
+	for _, require := range []bool{false, true} {
+		app, repo := newDBServer(t, func(c *config.Config) { c.LogoutRequireSession = require })
+		user := testdb.NewUser(t, repo, "")
+		token := newSession(t, repo, user.ID)
+
+		r := apiRequest("POST", "/logout", "", user)
+		r.AddCookie(&http.Cookie{Name: middleware.SessionCookieName, Value: token})
+		w := serve(app.handleLogout, r)
+		if w.Code != http.StatusNoContent {
+			t.Errorf("require session %v: status = %d, want 204", require, w.Code)
+		}
+		if !clearsSessionCookie(w) {
+			t.Errorf("require session %v: session cookie not cleared", require)
+		}
+		if _, _, err := repo.GetSessionWithUser(context.Background(), token); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("require session %v: session still found, err = %v", require, err)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/noahjalex/epoch/internal/config"
+	"github.com/noahjalex/epoch/internal/logging"
+	"github.com/noahjalex/epoch/internal/middleware"
+	"github.com/noahjalex/epoch/internal/models"
+	"github.com/noahjalex/epoch/internal/testdb"
+	"github.com/sirupsen/logrus"
+)
+
+func TestMain(m *testing.M) {
+	// Templates load from paths relative to the repository root
+	if err := os.Chdir(filepath.Join("..", "..")); err != nil {
+		panic(err)
+	}
+	logrus.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// newTestServer builds a server without a database; edit adjusts the
+// configuration before the server is created
+func newTestServer(t *testing.T, edit func(*config.Config)) *Server {
+	t.Helper()
+	return newServerWithRepo(t, nil, edit)
+}
+
+// newDBServer builds a server over the integration test database, skipping
+// the test when none is configured
+func newDBServer(t *testing.T, edit func(*config.Config)) (*Server, *models.Repo) {
+	t.Helper()
+	repo := testdb.Repo(t)
+	return newServerWithRepo(t, repo, edit), repo
+}
+
+func newServerWithRepo(t *testing.T, repo *models.Repo, edit func(*config.Config)) *Server {
+	t.Helper()
+	cfg := config.LoadConfig()
+	if edit != nil {
+		edit(cfg)
+	}
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	server, err := NewServer(repo, log, logging.LoadConfig(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return server
+}
+
+// apiRequest builds a request with an optional JSON body, authenticated as
+// user when it is not nil
+func apiRequest(method, target, body string, user *models.AppUser) *http.Request {
+	var r *http.Request
+	if body == "" {
+		r = httptest.NewRequest(method, target, nil)
+	} else {
+		r = httptest.NewRequest(method, target, strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+	}
+	if user != nil {
+		r = r.WithContext(context.WithValue(r.Context(), middleware.UserContextKey, user))
+	}
+	return r
+}
+
+// serve runs h on r with the given path values and returns the recorder
+func serve(h http.HandlerFunc, r *http.Request, pathValues ...string) *httptest.ResponseRecorder {
+	for i := 0; i+1 < len(pathValues); i += 2 {
+		r.SetPathValue(pathValues[i], pathValues[i+1])
+	}
+	w := httptest.NewRecorder()
+	h(w, r)
+	return w
+}
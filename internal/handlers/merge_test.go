@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/noahjalex/epoch/internal/models"
+)
+
+func TestMergeWarnings(t *testing.T) {
+	keep := &models.Habit{Agg: models.AggSum, Period: models.PeriodDaily, UnitLabel: sql.NullString{String: "km", Valid: true}}
+
+	same := *keep
+	if w := mergeWarnings(keep, &same); len(w) != 0 {
+		t.Errorf("matching habits warned: %v", w)
+	}
+
+	differs := &models.Habit{Agg: models.AggCount, Period: models.PeriodWeekly}
+	want := []string{
+		`unit differs: "" was merged into "km"`,
+		"period differs: weekly was merged into daily",
+		"aggregation differs: count was merged into sum",
+	}
+	got := mergeWarnings(keep, differs)
+	if len(got) != len(want) {
+		t.Fatalf("warnings = %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("warning %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
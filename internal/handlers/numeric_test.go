@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/noahjalex/epoch/internal/models"
+	"github.com/noahjalex/epoch/internal/testdb"
+)
+
+func TestHabitCreateRejectsOverflowingGoal(t *testing.T) {
+	// The server has no repository, so the request must fail before any query
+	app := newTestServer(t, nil)
+	user := &models.AppUser{ID: 1, Username: "u", TZ: "UTC"}
+
+	w := serve(app.handleHabitCreateAPI, apiRequest("POST", "/api/habits", `{"name":"Run","goal":1e12}`, user))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "goal") {
+		t.Errorf("error %q does not name the field", w.Body.String())
+	}
+}
+
+func TestLogCreateRejectsBadQuantity(t *testing.T) {
+	// Quantities are checked before the habit is loaded, so no repository is needed
+	app := newTestServer(t, nil)
+	user := &models.AppUser{ID: 1, Username: "u", TZ: "UTC"}
+
+	for _, qty := range []string{`-1`, `-0.01`, `1e12`, `"NaN"`, `"Inf"`} {
+		body := `{"habitId":1,"date":"2024-03-10T09:00","qty":` + qty + `}`
+		w := serve(app.handleLogCreateAPI, apiRequest("POST", "/api/logs", body, user))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("qty %s: status = %d, want 400", qty, w.Code)
+		}
+		if !strings.Contains(w.Body.String(), "qty") {
+			t.Errorf("qty %s: error %q does not name the field", qty, w.Body.String())
+		}
+	}
+}
+
+func TestLogQuantityBounds(t *testing.T) {
+	app, repo := newDBServer(t, nil)
+	user := testdb.NewUser(t, repo, "")
+	habit := testdb.NewHabit(t, repo, user.ID, nil)
+
+	body := fmt.Sprintf(`{"habitId":%d,"date":"2024-03-10T09:00","qty":0}`, habit.ID)
+	w := serve(app.handleLogCreateAPI, apiRequest("POST", "/api/logs", body, user))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("zero qty: status = %d, want 201: %s", w.Code, w.Body)
+	}
+	var created FrontendLog
+	if err := json.NewDecoder(w.Body).Decode(&created); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		qty  string
+		want int
+	}{
+		{"-1", http.StatusBadRequest},
+		{"1e12", http.StatusBadRequest},
+		{"0", http.StatusOK},
+		{"9999999999.99", http.StatusOK},
+	} {
+		body := fmt.Sprintf(`{"habitId":"%d","date":"2024-03-10T09:00","qty":%s}`, habit.ID, tc.qty)
+		w := serve(app.handleLogUpdateAPI, apiRequest("PATCH", "/api/logs/"+created.ID, body, user), "id", created.ID)
+		if w.Code != tc.want {
+			t.Errorf("update qty %s: status = %d, want %d: %s", tc.qty, w.Code, tc.want, w.Body)
+		}
+	}
+}
+
+func TestLogQuantityErrorsMatchCreateAndUpdate(t *testing.T) {
+	app, repo := newDBServer(t, nil)
+	user := testdb.NewUser(t, repo, "")
+	habit := testdb.NewHabit(t, repo, user.ID, nil)
+	l := testdb.NewLog(t, repo, habit.ID, time.Now().Add(-time.Hour), 1)
+	id := fmt.Sprint(l.ID)
+
+	for _, qty := range []string{`-1`, `-0.01`, `1e12`, `"NaN"`, `"Inf"`} {
+		body := fmt.Sprintf(`{"habitId":"%d","date":"2024-03-10T09:00","qty":%s}`, habit.ID, qty)
+		create := serve(app.handleLogCreateAPI, apiRequest("POST", "/api/logs", body, user))
+		update := serve(app.handleLogUpdateAPI, apiRequest("PATCH", "/api/logs/"+id, body, user), "id", id)
+		if create.Code != http.StatusBadRequest || update.Code != http.StatusBadRequest {
+			t.Errorf("qty %s: create %d, update %d, want 400 for both", qty, create.Code, update.Code)
+		}
+		if create.Body.String() != update.Body.String() {
+			t.Errorf("qty %s: create says %q, update says %q", qty, create.Body, update.Body)
+		}
+	}
+}
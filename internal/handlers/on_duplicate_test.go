@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/noahjalex/epoch/internal/models"
+	"github.com/noahjalex/epoch/internal/testdb"
+)
+
+func TestCreateHabitOnDuplicate(t *testing.T) {
+	yes := true
+	tests := []struct {
+		name string
+		req  FrontendHabit
+		want models.OnDuplicate
+	}{
+		{"default", FrontendHabit{}, models.OnDuplicateAllow},
+		{"reject", FrontendHabit{OnDuplicate: "reject"}, models.OnDuplicateReject},
+		{"replace", FrontendHabit{OnDuplicate: "replace"}, models.OnDuplicateReplace},
+		{"upsertLogs", FrontendHabit{UpsertLogs: yes}, models.OnDuplicateReplace},
+		{"onDuplicate wins over upsertLogs", FrontendHabit{OnDuplicate: "reject", UpsertLogs: yes}, models.OnDuplicateReject},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &models.Habit{
+				Agg:            models.AggSum,
+				Period:         models.PeriodDaily,
+				WeekStartDOW:   1,
+				MonthAnchorDay: 1,
+				LogPolicy:      models.LogPolicyMultiple,
+				OnDuplicate:    models.OnDuplicateAllow,
+			}
+			if err := tt.req.applyTo(h); err != nil {
+				t.Fatal(err)
+			}
+			if h.OnDuplicate != tt.want {
+				t.Errorf("OnDuplicate = %q, want %q", h.OnDuplicate, tt.want)
+			}
+		})
+	}
+
+	t.Run("unknown policy", func(t *testing.T) {
+		h := &models.Habit{Period: models.PeriodDaily, WeekStartDOW: 1, MonthAnchorDay: 1}
+		if err := (FrontendHabit{OnDuplicate: "merge"}).applyTo(h); err == nil {
+			t.Error("expected an error")
+		}
+	})
+}
+
+func TestLogCreateOnDuplicate(t *testing.T) {
+	app, repo := newDBServer(t, nil)
+	user := testdb.NewUser(t, repo, "America/Toronto")
+	loc, err := time.LoadLocation("America/Toronto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Both logs fall in the same local day; "other" is a different instant
+	first := time.Now().In(loc).Add(-2 * time.Hour).Truncate(time.Minute)
+	if first.Day() != time.Now().In(loc).Day() {
+		first = time.Now().In(loc).Truncate(time.Minute)
+	}
+	other := first.Add(time.Minute)
+
+	tests := []struct {
+		name        string
+		onDuplicate models.OnDuplicate
+		logPolicy   models.LogPolicy
+		second      time.Time
+		wantStatus  int
+		wantLogs    int
+		wantQty     int64 // quantity of the latest live log
+	}{
+		{"allow inserts another", models.OnDuplicateAllow, models.LogPolicyMultiple, first, http.StatusCreated, 2, 2},
+		{"reject answers 409", models.OnDuplicateReject, models.LogPolicyMultiple, first, http.StatusConflict, 1, 1},
+		{"replace updates", models.OnDuplicateReplace, models.LogPolicyMultiple, first, http.StatusCreated, 1, 2},
+		{"reject ignores other instants", models.OnDuplicateReject, models.LogPolicyMultiple, other, http.StatusCreated, 2, 2},
+		{"replace ignores other instants", models.OnDuplicateReplace, models.LogPolicyMultiple, other, http.StatusCreated, 2, 2},
+		{"replace wins over single", models.OnDuplicateReplace, models.LogPolicySingle, first, http.StatusCreated, 1, 2},
+		{"single still limits other instants", models.OnDuplicateReplace, models.LogPolicySingle, other, http.StatusConflict, 1, 1},
+		{"reject before single", models.OnDuplicateReject, models.LogPolicySingle, first, http.StatusConflict, 1, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := testdb.NewHabit(t, repo, user.ID, func(h *models.Habit) {
+				h.OnDuplicate = tt.onDuplicate
+				h.LogPolicy = tt.logPolicy
+			})
+			post := func(at time.Time, qty int) int {
+				body := fmt.Sprintf(`{"habitId":%d,"date":%q,"qty":%d,"snap":"none"}`, h.ID, at.Format(models.ToFrontEndFormat), qty)
+				return serve(app.handleLogCreateAPI, apiRequest("POST", "/api/logs", body, user)).Code
+			}
+			if code := post(first, 1); code != http.StatusCreated {
+				t.Fatalf("first log: status = %d, want 201", code)
+			}
+			if code := post(tt.second, 2); code != tt.wantStatus {
+				t.Errorf("second log: status = %d, want %d", code, tt.wantStatus)
+			}
+
+			logs, err := repo.ListLogs(context.Background(), h.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(logs) != tt.wantLogs {
+				t.Fatalf("%d logs, want %d", len(logs), tt.wantLogs)
+			}
+			if got := logs[len(logs)-1].Quantity.IntPart(); got != tt.wantQty {
+				t.Errorf("latest quantity = %d, want %d", got, tt.wantQty)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/noahjalex/epoch/internal/auth"
+	"github.com/noahjalex/epoch/internal/config"
+	"github.com/noahjalex/epoch/internal/middleware"
+	"github.com/noahjalex/epoch/internal/models"
+	"github.com/noahjalex/epoch/internal/testdb"
+)
+
+// newSession stores a session for userID and returns its token
+func newSession(t *testing.T, repo *models.Repo, userID int64) string {
+	t.Helper()
+	token, err := auth.GenerateSessionToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.CreateSession(context.Background(), userID, token, time.Now().Add(time.Hour), true); err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestLogoutOthersRotatesSession(t *testing.T) {
+	for _, rotate := range []bool{true, false} {
+		name := "rotation off"
+		if rotate {
+			name = "rotation on"
+		}
+		t.Run(name, func(t *testing.T) {
+			app, repo := newDBServer(t, func(c *config.Config) { c.RotateSessionOnPrivilegeChange = rotate })
+			ctx := context.Background()
+			user := testdb.NewUser(t, repo, "")
+			current := newSession(t, repo, user.ID)
+			elsewhere := newSession(t, repo, user.ID)
+
+			r := apiRequest("POST", "/api/account/logout-others", "", user)
+			r.AddCookie(&http.Cookie{Name: middleware.SessionCookieName, Value: current})
+			w := serve(app.handleLogoutOthersAPI, r)
+			if w.Code != http.StatusNoContent {
+				t.Fatalf("status = %d, want 204: %s", w.Code, w.Body)
+			}
+
+			if _, _, err := repo.GetSessionWithUser(ctx, elsewhere); !errors.Is(err, sql.ErrNoRows) {
+				t.Errorf("other session still valid: %v", err)
+			}
+
+			var newToken string
+			for _, c := range w.Result().Cookies() {
+				if c.Name == middleware.SessionCookieName {
+					newToken = c.Value
+				}
+			}
+			if !rotate {
+				if newToken != "" {
+					t.Error("session cookie reset with rotation off")
+				}
+				if _, _, err := repo.GetSessionWithUser(ctx, current); err != nil {
+					t.Errorf("current session lost with rotation off: %v", err)
+				}
+				return
+			}
+
+			if newToken == "" || newToken == current {
+				t.Fatalf("cookie token %q, want a new token", newToken)
+			}
+			if _, _, err := repo.GetSessionWithUser(ctx, current); !errors.Is(err, sql.ErrNoRows) {
+				t.Errorf("old token still valid: %v", err)
+			}
+			_, got, err := repo.GetSessionWithUser(ctx, newToken)
+			if err != nil || got.ID != user.ID {
+				t.Errorf("new token resolves to %v, %v; want user %d", got, err, user.ID)
+			}
+		})
+	}
+}
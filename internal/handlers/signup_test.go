@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// captchaFunc adapts a function to auth.CaptchaVerifier
+type captchaFunc func(ctx context.Context, token, remoteAddr string) error
+
+func (f captchaFunc) Verify(ctx context.Context, token, remoteAddr string) error {
+	return f(ctx, token, remoteAddr)
+}
+
+func signupRequest(form url.Values) *http.Request {
+	r := httptest.NewRequest("POST", "/signup", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return r
+}
+
+func TestSignupCaptchaRejected(t *testing.T) {
+	app := newTestServer(t, nil)
+	var gotToken string
+	app.SetCaptchaVerifier(captchaFunc(func(ctx context.Context, token, remoteAddr string) error {
+		gotToken = token
+		return errors.New("bad token")
+	}))
+
+	// The server has no repository, so reaching CreateUser would panic
+	w := serve(app.handleSignup, signupRequest(url.Values{
+		"username":         {"alice"},
+		"email":            {"alice@example.com"},
+		"password":         {"correct horse"},
+		"confirm_password": {"correct horse"},
+		"captcha_token":    {"tok-123"},
+	}))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want the signup page re-rendered", w.Code)
+	}
+	if gotToken != "tok-123" {
+		t.Errorf("verifier got token %q, want tok-123", gotToken)
+	}
+	if !strings.Contains(w.Body.String(), "Captcha verification failed") {
+		t.Error("page does not show the captcha error")
+	}
+	if w.Header().Get("Set-Cookie") != "" {
+		t.Error("rejected signup set a cookie")
+	}
+}
+
+func TestSignupFieldErrors(t *testing.T) {
+	app := newTestServer(t, nil)
+
+	w := serve(app.handleSignup, signupRequest(url.Values{
+		"username":         {"a!"},
+		"email":            {"not-an-email"},
+		"password":         {"pw"},
+		"confirm_password": {"pw"},
+		"timezone":         {"Mars/Olympus"},
+	}))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want the signup page re-rendered", w.Code)
+	}
+	body := w.Body.String()
+	for _, msg := range []string{
+		"must be at least 3 characters",
+		"must be a valid email address",
+		"must be a valid timezone",
+		`value="not-an-email"`,
+	} {
+		if !strings.Contains(body, msg) {
+			t.Errorf("page is missing %q", msg)
+		}
+	}
+}
@@ -6,9 +6,12 @@ import (
 	"html/template"
 	"net/http"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
+	"github.com/noahjalex/epoch/internal/middleware"
+	"github.com/noahjalex/epoch/internal/tzcache"
 	"github.com/sirupsen/logrus"
 )
 
@@ -31,7 +34,7 @@ func NewRendererWithLogger(logger *logrus.Logger) (*Renderer, error) {
 			return time.Now().Format("2006-01-02T15:04")
 		},
 		"currentDateTimeInTZ": func(tzName string) string {
-			loc, err := time.LoadLocation(tzName)
+			loc, err := tzcache.Load(tzName)
 			if err != nil {
 				loc = time.Local // fallback
 			}
@@ -47,6 +50,9 @@ func NewRendererWithLogger(logger *logrus.Logger) (*Renderer, error) {
 			jd, _ := json.MarshalIndent(data, "", "  ")
 			return string(jd)
 		},
+		// Placeholders; Render rebinds these to the request's CSRF token
+		"csrfToken": func() string { return "" },
+		"csrfField": func() template.HTML { return "" },
 	}
 
 	base, err := template.New("base").Funcs(funcs).ParseFiles("templates/layout.gohtml")
@@ -110,7 +116,34 @@ func NewRendererWithLogger(logger *logrus.Logger) (*Renderer, error) {
 	return &Renderer{cache: cache, log: logger}, nil
 }
 
-func (r *Renderer) Render(w http.ResponseWriter, name string, data any) {
+// Templates returns the names of the cached templates, sorted
+func (r *Renderer) Templates() []string {
+	names := make([]string, 0, len(r.cache))
+	for name := range r.cache {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// withCSRF clones tmpl with the csrfToken/csrfField funcs bound to the
+// request's CSRF token
+func withCSRF(tmpl *template.Template, req *http.Request) (*template.Template, error) {
+	token := middleware.GetCSRFTokenFromContext(req.Context())
+	clone, err := tmpl.Clone()
+	if err != nil {
+		return nil, err
+	}
+	return clone.Funcs(template.FuncMap{
+		"csrfToken": func() string { return token },
+		"csrfField": func() template.HTML {
+			return template.HTML(`<input type="hidden" name="` + middleware.CSRFFormField +
+				`" value="` + template.HTMLEscapeString(token) + `">`)
+		},
+	}), nil
+}
+
+func (r *Renderer) Render(w http.ResponseWriter, req *http.Request, name string, data any) {
 	tmpl, ok := r.cache[name]
 	if !ok {
 		r.log.WithFields(logrus.Fields{
@@ -128,6 +161,18 @@ func (r *Renderer) Render(w http.ResponseWriter, name string, data any) {
 		"template":  name,
 	}).Debug("Rendering template")
 
+	tmpl, err := withCSRF(tmpl, req)
+	if err != nil {
+		r.log.WithFields(logrus.Fields{
+			"component": "renderer",
+			"action":    "render",
+			"template":  name,
+			"error":     err.Error(),
+		}).Error("Failed to clone template")
+		http.Error(w, "template execution error", http.StatusInternalServerError)
+		return
+	}
+
 	var buf bytes.Buffer
 	if err := tmpl.ExecuteTemplate(&buf, "base", data); err != nil {
 		r.log.WithFields(logrus.Fields{
@@ -157,7 +202,7 @@ func (r *Renderer) Render(w http.ResponseWriter, name string, data any) {
 	}).Debug("Template rendered successfully")
 }
 
-func (r *Renderer) RenderPartial(w http.ResponseWriter, name string, data any) {
+func (r *Renderer) RenderPartial(w http.ResponseWriter, req *http.Request, name string, data any) {
 	tmpl, ok := r.cache[name]
 	if !ok {
 		r.log.WithFields(logrus.Fields{
@@ -175,6 +220,18 @@ func (r *Renderer) RenderPartial(w http.ResponseWriter, name string, data any) {
 		"template":  name,
 	}).Debug("Rendering partial template")
 
+	tmpl, err := withCSRF(tmpl, req)
+	if err != nil {
+		r.log.WithFields(logrus.Fields{
+			"component": "renderer",
+			"action":    "render_partial",
+			"template":  name,
+			"error":     err.Error(),
+		}).Error("Failed to clone partial template")
+		http.Error(w, "partial template execution error", http.StatusInternalServerError)
+		return
+	}
+
 	var buf bytes.Buffer
 	if err := tmpl.ExecuteTemplate(&buf, name, data); err != nil {
 		r.log.WithFields(logrus.Fields{
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/noahjalex/epoch/internal/models"
+	"github.com/shopspring/decimal"
+)
+
+func TestLogToFrontendTimeFormat(t *testing.T) {
+	loc := time.FixedZone("IST", 5*3600+1800)
+	l := &models.HabitLog{
+		ID:         7,
+		HabitID:    3,
+		OccurredAt: time.Date(2024, 3, 10, 3, 30, 45, 0, time.UTC),
+		Quantity:   decimal.NewFromInt(1),
+	}
+
+	for _, tc := range []struct {
+		target string
+		want   string
+	}{
+		{"/api/logs", "2024-03-10T09:00"},
+		{"/api/logs?timeFormat=rfc3339", "2024-03-10T09:00:45+05:30"},
+		{"/api/logs?timeFormat=RFC3339", "2024-03-10T09:00:45+05:30"},
+	} {
+		out := logToFrontend(l, loc, apiTimeLayout(httptest.NewRequest("GET", tc.target, nil)))
+		if out.Date != tc.want {
+			t.Errorf("%s: date = %q, want %q", tc.target, out.Date, tc.want)
+		}
+		if want := l.OccurredAt.In(loc).Format(models.HumanDateFormat); out.DateDisplay != want {
+			t.Errorf("%s: display = %q, want %q", tc.target, out.DateDisplay, want)
+		}
+	}
+}
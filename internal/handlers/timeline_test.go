@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/noahjalex/epoch/internal/config"
+	"github.com/noahjalex/epoch/internal/models"
+	"github.com/noahjalex/epoch/internal/testdb"
+)
+
+func TestLogsTimelineChecksEveryHabit(t *testing.T) {
+	app, repo := newDBServer(t, nil)
+	user := testdb.NewUser(t, repo, "")
+	other := testdb.NewUser(t, repo, "")
+	a := testdb.NewHabit(t, repo, user.ID, nil)
+	b := testdb.NewHabit(t, repo, user.ID, nil)
+	foreign := testdb.NewHabit(t, repo, other.ID, nil)
+	day := time.Date(2024, 3, 10, 9, 0, 0, 0, time.UTC)
+	testdb.NewLog(t, repo, a.ID, day, 1)
+	testdb.NewLog(t, repo, b.ID, day.Add(time.Hour), 2)
+	testdb.NewLog(t, repo, foreign.ID, day, 3)
+
+	target := func(x, y int64) string {
+		return fmt.Sprintf("/api/logs/timeline?from=2024-03-10&to=2024-03-11&habitIds=%d,%d", x, y)
+	}
+
+	w := serve(app.handleLogsTimelineAPI, apiRequest("GET", target(a.ID, foreign.ID), "", user))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("with another user's habit: status = %d, want 404", w.Code)
+	}
+
+	w = serve(app.handleLogsTimelineAPI, apiRequest("GET", target(a.ID, b.ID), "", user))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+	var logs []FrontendLog
+	if err := json.NewDecoder(w.Body).Decode(&logs); err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != 2 || logs[0].HabitID != fmt.Sprint(a.ID) || logs[1].HabitID != fmt.Sprint(b.ID) {
+		t.Errorf("logs = %+v, want one from each habit, oldest first", logs)
+	}
+}
+
+func TestLogsTimelineToIsExclusive(t *testing.T) {
+	app, repo := newDBServer(t, nil)
+	user := testdb.NewUser(t, repo, "UTC")
+	habit := testdb.NewHabit(t, repo, user.ID, nil)
+	testdb.NewLog(t, repo, habit.ID, time.Date(2024, 3, 10, 9, 0, 0, 0, time.UTC), 1)
+	testdb.NewLog(t, repo, habit.ID, time.Date(2024, 3, 11, 9, 0, 0, 0, time.UTC), 2)
+
+	// The same from/to select the same logs here and on /api/logs
+	const query = "from=2024-03-10&to=2024-03-11"
+	var timeline, list []FrontendLog
+	w := serve(app.handleLogsTimelineAPI, apiRequest("GET", fmt.Sprintf("/api/logs/timeline?%s&habitIds=%d", query, habit.ID), "", user))
+	if err := json.NewDecoder(w.Body).Decode(&timeline); err != nil {
+		t.Fatal(err)
+	}
+	w = serve(app.handleLogsListAPI, apiRequest("GET", fmt.Sprintf("/api/logs?%s&habit_id=%d", query, habit.ID), "", user))
+	if err := json.NewDecoder(w.Body).Decode(&list); err != nil {
+		t.Fatal(err)
+	}
+	if len(timeline) != 1 || timeline[0].Qty != 1 || len(list) != 1 || list[0].ID != timeline[0].ID {
+		t.Errorf("timeline %+v, /api/logs %+v, want only the Mar 10 log in both", timeline, list)
+	}
+}
+
+func TestLogsTimelineRangeLimit(t *testing.T) {
+	app := newTestServer(t, func(c *config.Config) { c.AnalyticsMaxDays = 7 })
+	user := &models.AppUser{ID: 1, Username: "u", TZ: "UTC"}
+
+	// The range is checked before any query, so no repository is needed
+	w := serve(app.handleLogsTimelineAPI, apiRequest("GET", "/api/logs/timeline?from=2024-03-01&to=2024-03-09&habitIds=1", "", user))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("8-day range: status = %d, want 400", w.Code)
+	}
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/noahjalex/epoch/internal/middleware"
+	"github.com/noahjalex/epoch/internal/testdb"
+	"github.com/sirupsen/logrus"
+)
+
+func TestTimezoneChangeShowsOnNextRequest(t *testing.T) {
+	app, repo := newDBServer(t, nil)
+	// Auth may serve the user from the session cache; the change must still show
+	repo.EnableSessionCache(time.Minute, 100)
+	user := testdb.NewUser(t, repo, "UTC")
+	habit := testdb.NewHabit(t, repo, user.ID, nil)
+	testdb.NewLog(t, repo, habit.ID, time.Date(2024, 3, 10, 23, 30, 0, 0, time.UTC), 1)
+	token := newSession(t, repo, user.ID)
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/logs", app.handleLogsListAPI)
+	mux.HandleFunc("POST /api/account/timezone", app.handleTimezoneChangeAPI)
+	h := middleware.AuthMiddleware(repo, log, 0)(mux)
+
+	do := func(r *http.Request) *httptest.ResponseRecorder {
+		r.AddCookie(&http.Cookie{Name: middleware.SessionCookieName, Value: token})
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+	logDate := func() string {
+		t.Helper()
+		w := do(apiRequest("GET", "/api/logs", "", nil))
+		var logs []FrontendLog
+		if err := json.NewDecoder(w.Body).Decode(&logs); err != nil || len(logs) != 1 {
+			t.Fatalf("logs = %v, %v (status %d)", logs, err, w.Code)
+		}
+		return logs[0].Date
+	}
+
+	if got := logDate(); got != "2024-03-10T23:30" {
+		t.Fatalf("before: date = %s, want 2024-03-10T23:30", got)
+	}
+	if w := do(apiRequest("POST", "/api/account/timezone", `{"timezone":"Asia/Tokyo"}`, nil)); w.Code != http.StatusOK {
+		t.Fatalf("timezone change status = %d: %s", w.Code, w.Body)
+	}
+	if got := logDate(); got != "2024-03-11T08:30" {
+		t.Errorf("after: date = %s, want 2024-03-11T08:30", got)
+	}
+}
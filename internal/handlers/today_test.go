@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/noahjalex/epoch/internal/models"
+	"github.com/noahjalex/epoch/internal/testdb"
+	"github.com/shopspring/decimal"
+)
+
+func TestTodayProgressCoversTheCurrentPeriod(t *testing.T) {
+	app, repo := newDBServer(t, nil)
+	user := testdb.NewUser(t, repo, "")
+	now := time.Now().UTC()
+	weekStart, _ := models.WeekBounds(now, time.UTC, 1)
+	earlierToday := now.Truncate(24 * time.Hour).Add(now.Sub(now.Truncate(24*time.Hour)) / 2)
+
+	daily := testdb.NewHabit(t, repo, user.ID, func(h *models.Habit) { h.TargetPerPeriod = decimal.NewFromInt(2) })
+	weekly := testdb.NewHabit(t, repo, user.ID, func(h *models.Habit) {
+		h.Period = models.PeriodWeekly
+		h.TargetPerPeriod = decimal.NewFromInt(3)
+	})
+	maxHabit := testdb.NewHabit(t, repo, user.ID, func(h *models.Habit) { h.Agg = models.AggMax })
+
+	testdb.NewLog(t, repo, daily.ID, earlierToday, 2)
+	testdb.NewLog(t, repo, daily.ID, now.AddDate(0, 0, -8), 5) // outside today and this week
+	// Three logs this week, spread from its first moment up to now
+	testdb.NewLog(t, repo, weekly.ID, weekStart, 1)
+	testdb.NewLog(t, repo, weekly.ID, weekStart.Add(now.Sub(weekStart)/2), 1)
+	testdb.NewLog(t, repo, weekly.ID, now, 1)
+
+	w := serve(app.handleTodayAPI, apiRequest("GET", "/api/today", "", user))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+	var got []models.TodayProgress
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	byHabit := make(map[int64]models.TodayProgress, len(got))
+	for _, p := range got {
+		byHabit[p.HabitID] = p
+	}
+
+	tests := []struct {
+		name     string
+		habitID  int64
+		value    int64
+		logCount int
+		met      bool
+	}{
+		{"daily counts today only", daily.ID, 2, 1, true},
+		{"weekly counts the whole week", weekly.ID, 3, 3, true},
+		{"max without logs is not met", maxHabit.ID, 0, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, ok := byHabit[tt.habitID]
+			if !ok {
+				t.Fatalf("habit %d missing from %+v", tt.habitID, got)
+			}
+			if !p.Value.Equal(decimal.NewFromInt(tt.value)) || p.LogCount != tt.logCount || p.Met != tt.met {
+				t.Errorf("got value %s, %d logs, met %v; want %d, %d, %v", p.Value, p.LogCount, p.Met, tt.value, tt.logCount, tt.met)
+			}
+			if !p.PeriodStart.Before(now) || !p.PeriodEnd.After(now) {
+				t.Errorf("period [%v, %v) does not hold now", p.PeriodStart, p.PeriodEnd)
+			}
+		})
+	}
+}
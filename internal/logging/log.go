@@ -13,6 +13,8 @@ type Config struct {
 	Format      string
 	Output      string
 	HTTPLogging bool
+	// BodyLogExempt lists path prefixes whose request bodies are never captured
+	BodyLogExempt []string
 }
 
 // LoadConfig loads logging configuration from environment variables
@@ -22,6 +24,8 @@ func LoadConfig() *Config {
 		Format:      getEnv("EPOCH_LOG_FORMAT", "text"),   // text or json
 		Output:      getEnv("EPOCH_LOG_OUTPUT", "stdout"), // stdout, stderr, or file path
 		HTTPLogging: getEnvBool("EPOCH_LOG_HTTP", true),   // Enable HTTP logging by default
+		// Comma-separated; bulk imports and password changes are exempt by default
+		BodyLogExempt: getEnvList("EPOCH_LOG_BODY_EXEMPT", []string{"/api/logs/import", "/api/account/password"}),
 	}
 }
 
@@ -72,6 +76,7 @@ func Init(config *Config) *logrus.Logger {
 		"format":       config.Format,
 		"output":       config.Output,
 		"http_logging": config.HTTPLogging,
+		"body_exempt":  config.BodyLogExempt,
 	}).Info("Logger initialized")
 
 	return logger
@@ -93,3 +98,18 @@ func getEnvBool(key string, defaultValue bool) bool {
 	}
 	return strings.ToLower(value) == "true" || value == "1"
 }
+
+// getEnvList gets a comma-separated environment variable with a default value
+func getEnvList(key string, defaultValue []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	var out []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
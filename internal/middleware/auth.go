@@ -3,7 +3,10 @@ package middleware
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/noahjalex/epoch/internal/auth"
@@ -14,17 +17,32 @@ import (
 type contextKey string
 
 const (
-	UserContextKey contextKey = "user"
+	UserContextKey    contextKey = "user"
+	SessionContextKey contextKey = "session"
 )
 
-// AuthMiddleware checks for a valid session and adds user to context
-func AuthMiddleware(repo *models.Repo, log *logrus.Logger) func(http.Handler) http.Handler {
+// AuthMiddleware checks for a valid session and adds user to context.
+// Protected API requests without one get a 401 instead of the login redirect.
+// Requests to publicPaths (exact match) are let through without a user,
+// the same way the login and signup pages are.
+// A session with less than refreshBelow remaining (at most half its lifetime
+// for short sessions) is extended to a full session duration of its kind;
+// zero disables sliding expiration.
+func AuthMiddleware(repo *models.Repo, log *logrus.Logger, refreshBelow time.Duration, publicPaths ...string) func(http.Handler) http.Handler {
+	public := map[string]struct{}{
+		"/login":  {},
+		"/signup": {},
+	}
+	for _, p := range publicPaths {
+		public[p] = struct{}{}
+	}
+
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			isAuthPage := r.URL.Path == "/login" || r.URL.Path == "/signup"
+			_, isAuthPage := public[r.URL.Path]
 
 			// Get session token from cookie
-			cookie, err := r.Cookie("session_token")
+			cookie, err := r.Cookie(SessionCookieName)
 			if err != nil {
 				log.Debug("No session cookie found")
 				// No session cookie
@@ -33,24 +51,22 @@ func AuthMiddleware(repo *models.Repo, log *logrus.Logger) func(http.Handler) ht
 					next.ServeHTTP(w, r)
 					return
 				}
-				// Redirect to login for protected pages
-				http.Redirect(w, r, "/login", http.StatusSeeOther)
+				denyUnauthenticated(w, r)
 				return
 			}
 
-			// Look up session in database
-			session, err := repo.GetSessionByToken(r.Context(), cookie.Value)
+			// Look up session and its user in one query
+			session, user, err := repo.GetSessionWithUser(r.Context(), cookie.Value)
 			if err != nil {
 				if err == sql.ErrNoRows {
 					// Invalid session, clear cookie
-					clearSessionCookie(w)
+					ClearSessionCookie(w)
 					if isAuthPage {
 						// Allow access to auth pages with invalid session
 						next.ServeHTTP(w, r)
 						return
 					}
-					// Redirect to login for protected pages
-					http.Redirect(w, r, "/login", http.StatusSeeOther)
+					denyUnauthenticated(w, r)
 					return
 				}
 				log.WithError(err).Error("Failed to get session")
@@ -62,67 +78,162 @@ func AuthMiddleware(repo *models.Repo, log *logrus.Logger) func(http.Handler) ht
 			if auth.IsSessionExpired(session.ExpiresAt) {
 				// Session expired, clean up
 				_ = repo.DeleteSession(r.Context(), session.SessionToken)
-				clearSessionCookie(w)
+				ClearSessionCookie(w)
 				if isAuthPage {
 					// Allow access to auth pages with expired session
 					next.ServeHTTP(w, r)
 					return
 				}
-				// Redirect to login for protected pages
-				http.Redirect(w, r, "/login", http.StatusSeeOther)
+				denyUnauthenticated(w, r)
 				return
 			}
 
-			// Get user from session
-			user, err := repo.GetUser(r.Context(), session.UserID)
-			if err != nil {
-				log.WithError(err).Error("Failed to get user from session")
-				clearSessionCookie(w)
-				if isAuthPage {
-					// Allow access to auth pages if user lookup fails
-					next.ServeHTTP(w, r)
-					return
+			// Slide the expiry forward once it gets close; sessions with more
+			// time left are not touched, so most requests skip the write
+			lifetime := auth.SessionDuration(session.Remember)
+			if refreshBelow > 0 && time.Until(session.ExpiresAt) < min(refreshBelow, lifetime/2) {
+				newExpiry := time.Now().Add(lifetime)
+				if err := repo.TouchSession(r.Context(), session.SessionToken, newExpiry); err != nil {
+					log.WithError(err).Warn("Failed to extend session")
+				} else {
+					SetSessionCookieFor(w, session.SessionToken, session.Remember)
 				}
-				// Redirect to login for protected pages
-				http.Redirect(w, r, "/login", http.StatusSeeOther)
-				return
 			}
 
-			// Add user to request context
+			// Add user and session to request context
 			ctx := context.WithValue(r.Context(), UserContextKey, user)
+			ctx = context.WithValue(ctx, SessionContextKey, session)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
 
+// GetSessionFromContext extracts the session that authenticated the request
+func GetSessionFromContext(ctx context.Context) (*models.UserSession, bool) {
+	session, ok := ctx.Value(SessionContextKey).(*models.UserSession)
+	return session, ok
+}
+
+// isAPIRequest reports whether r comes from a script rather than a browser
+// navigation: anything under /api/ or asking for JSON
+func isAPIRequest(r *http.Request) bool {
+	return strings.HasPrefix(r.URL.Path, "/api/") ||
+		strings.Contains(r.Header.Get("Accept"), "application/json")
+}
+
+// denyUnauthenticated answers a protected request without a valid session:
+// API requests get a 401 JSON error, pages are redirected to login
+func denyUnauthenticated(w http.ResponseWriter, r *http.Request) {
+	if isAPIRequest(r) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": "authentication required"})
+		return
+	}
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
+}
+
 // GetUserFromContext extracts the user from the request context
 func GetUserFromContext(ctx context.Context) (*models.AppUser, bool) {
 	user, ok := ctx.Value(UserContextKey).(*models.AppUser)
 	return user, ok
 }
 
-// SetSessionCookie sets the session cookie
-func SetSessionCookie(w http.ResponseWriter, sessionToken string) {
-	cookie := &http.Cookie{
-		Name:     "session_token",
-		Value:    sessionToken,
-		Path:     "/",
+// SessionCookieName names the session cookie
+const SessionCookieName = "session_token"
+
+// SessionCookieConfig holds the cookie attributes that setting and clearing
+// must agree on; a cleared cookie only replaces the original when Name, Path
+// and Domain match. The CSRF cookie shares Domain, Secure and SameSite.
+type SessionCookieConfig struct {
+	Path     string
+	Domain   string
+	Secure   bool
+	SameSite http.SameSite
+}
+
+// DefaultSessionCookieConfig is host-only, Lax and not Secure, suitable for
+// local development over HTTP
+var DefaultSessionCookieConfig = SessionCookieConfig{
+	Path:     "/",
+	SameSite: http.SameSiteLaxMode,
+}
+
+var sessionCookie = DefaultSessionCookieConfig
+
+// SetSessionCookieConfig sets the cookie attributes; call it once at startup.
+// An empty Path means "/". Browsers drop SameSite=None cookies that are not
+// Secure, so that combination is rejected and the config left unchanged.
+func SetSessionCookieConfig(c SessionCookieConfig) error {
+	if c.SameSite == http.SameSiteNoneMode && !c.Secure {
+		return errors.New("SameSite=None session cookies must be Secure")
+	}
+	if c.Path == "" {
+		c.Path = "/"
+	}
+	sessionCookie = c
+	return nil
+}
+
+// ParseSameSite maps "lax", "strict" or "none" (any case) to its http.SameSite
+// mode, falling back to Lax for anything else
+func ParseSameSite(s string) http.SameSite {
+	switch strings.ToLower(s) {
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteLaxMode
+	}
+}
+
+// newSessionCookie builds the session cookie carrying token
+func newSessionCookie(token string) *http.Cookie {
+	return &http.Cookie{
+		Name:     SessionCookieName,
+		Value:    token,
+		Path:     sessionCookie.Path,
+		Domain:   sessionCookie.Domain,
 		HttpOnly: true,
-		Secure:   false, // Set to true in production with HTTPS
-		SameSite: http.SameSiteLaxMode,
+		Secure:   sessionCookie.Secure,
+		SameSite: sessionCookie.SameSite,
 		Expires:  time.Now().Add(auth.DefaultSessionDuration),
 	}
-	http.SetCookie(w, cookie)
 }
 
-// clearSessionCookie clears the session cookie
-func clearSessionCookie(w http.ResponseWriter) {
-	cookie := &http.Cookie{
-		Name:     "session_token",
-		Value:    "",
-		Path:     "/",
-		HttpOnly: true,
-		Expires:  time.Unix(0, 0),
+// newClearedSessionCookie builds a cookie that expires the session cookie
+func newClearedSessionCookie() *http.Cookie {
+	c := newSessionCookie("")
+	c.Expires = time.Unix(0, 0)
+	c.MaxAge = -1
+	return c
+}
+
+// SetSessionCookie sets the session cookie
+func SetSessionCookie(w http.ResponseWriter, sessionToken string) {
+	http.SetCookie(w, newSessionCookie(sessionToken))
+}
+
+// SetBrowserSessionCookie sets the session cookie without Expires, so the
+// browser drops it when it closes
+func SetBrowserSessionCookie(w http.ResponseWriter, sessionToken string) {
+	c := newSessionCookie(sessionToken)
+	c.Expires = time.Time{}
+	http.SetCookie(w, c)
+}
+
+// SetSessionCookieFor sets the cookie matching a remembered or short session
+func SetSessionCookieFor(w http.ResponseWriter, sessionToken string, remember bool) {
+	if remember {
+		SetSessionCookie(w, sessionToken)
+		return
 	}
-	http.SetCookie(w, cookie)
+	SetBrowserSessionCookie(w, sessionToken)
+}
+
+// ClearSessionCookie expires the session cookie
+func ClearSessionCookie(w http.ResponseWriter) {
+	http.SetCookie(w, newClearedSessionCookie())
 }
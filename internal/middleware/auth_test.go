@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSetSessionCookieConfig(t *testing.T) {
+	t.Cleanup(func() { sessionCookie = DefaultSessionCookieConfig })
+
+	if err := SetSessionCookieConfig(SessionCookieConfig{SameSite: http.SameSiteNoneMode}); err == nil {
+		t.Error("SameSite=None without Secure was accepted")
+	}
+	if sessionCookie != DefaultSessionCookieConfig {
+		t.Errorf("rejected config was applied: %+v", sessionCookie)
+	}
+
+	if err := SetSessionCookieConfig(SessionCookieConfig{SameSite: http.SameSiteNoneMode, Secure: true}); err != nil {
+		t.Fatalf("SameSite=None with Secure: %v", err)
+	}
+	c := newSessionCookie("tok")
+	if !c.Secure || c.SameSite != http.SameSiteNoneMode || c.Path != "/" {
+		t.Errorf("cookie Secure %v SameSite %v Path %q, want true None /", c.Secure, c.SameSite, c.Path)
+	}
+}
+
+func TestParseSameSite(t *testing.T) {
+	for in, want := range map[string]http.SameSite{
+		"lax":    http.SameSiteLaxMode,
+		"Strict": http.SameSiteStrictMode,
+		"NONE":   http.SameSiteNoneMode,
+		"bogus":  http.SameSiteLaxMode,
+		"":       http.SameSiteLaxMode,
+	} {
+		if got := ParseSameSite(in); got != want {
+			t.Errorf("ParseSameSite(%q) = %v, want %v", in, got, want)
+		}
+	}
+}
+
+func TestClearedSessionCookieMatchesSet(t *testing.T) {
+	t.Cleanup(func() { sessionCookie = DefaultSessionCookieConfig })
+
+	for _, cfg := range []SessionCookieConfig{
+		DefaultSessionCookieConfig,
+		{Path: "/app", Domain: "example.com", Secure: true, SameSite: http.SameSiteStrictMode},
+		{Domain: "example.com", Secure: true, SameSite: http.SameSiteNoneMode},
+	} {
+		if err := SetSessionCookieConfig(cfg); err != nil {
+			t.Fatal(err)
+		}
+		w := httptest.NewRecorder()
+		SetSessionCookie(w, "tok")
+		ClearSessionCookie(w)
+		cookies := w.Result().Cookies()
+		if len(cookies) != 2 {
+			t.Fatalf("%+v: %d cookies written, want 2", cfg, len(cookies))
+		}
+		set, cleared := cookies[0], cookies[1]
+
+		if cleared.Name != set.Name || cleared.Path != set.Path || cleared.Domain != set.Domain ||
+			cleared.SameSite != set.SameSite || cleared.Secure != set.Secure || cleared.HttpOnly != set.HttpOnly {
+			t.Errorf("%+v: cleared cookie %+v does not match set cookie %+v", cfg, cleared, set)
+		}
+		if cleared.Value != "" || cleared.MaxAge >= 0 {
+			t.Errorf("%+v: cleared cookie value %q MaxAge %d, want empty and expired", cfg, cleared.Value, cleared.MaxAge)
+		}
+	}
+}
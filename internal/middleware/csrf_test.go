@@ -0,0 +1,120 @@
+package middleware
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/noahjalex/epoch/internal/utils"
+)
+
+func TestCSRFMiddleware(t *testing.T) {
+	token := strings.Repeat("ab", csrfTokenLength)
+	var seen string
+	h := CSRFMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = GetCSRFTokenFromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	form := func(v string) (string, string) {
+		return url.Values{CSRFFormField: {v}}.Encode(), "application/x-www-form-urlencoded"
+	}
+	multi := func(v string) (string, string) {
+		var b bytes.Buffer
+		mw := multipart.NewWriter(&b)
+		mw.WriteField(CSRFFormField, v)
+		mw.Close()
+		return b.String(), mw.FormDataContentType()
+	}
+
+	tests := []struct {
+		name, method string
+		cookie       bool
+		header       string
+		body         func(string) (string, string)
+		field        string
+		want         int
+	}{
+		{"GET without cookie", "GET", false, "", nil, "", http.StatusOK},
+		{"HEAD with cookie", "HEAD", true, "", nil, "", http.StatusOK},
+		{"POST without token", "POST", true, "", nil, "", http.StatusForbidden},
+		{"POST without cookie", "POST", false, token, nil, "", http.StatusForbidden},
+		{"header matches", "POST", true, token, nil, "", http.StatusOK},
+		{"header mismatches", "DELETE", true, strings.Repeat("cd", csrfTokenLength), nil, "", http.StatusForbidden},
+		{"form field matches", "POST", true, "", form, token, http.StatusOK},
+		{"form field mismatches", "POST", true, "", form, "nope", http.StatusForbidden},
+		{"multipart field matches", "POST", true, "", multi, token, http.StatusOK},
+		{"header wins over form field", "POST", true, token, form, "nope", http.StatusOK},
+	}
+	for _, tt := range tests {
+		seen = ""
+		body, ct := "", ""
+		if tt.body != nil {
+			body, ct = tt.body(tt.field)
+		}
+		r := httptest.NewRequest(tt.method, "/api/habits", strings.NewReader(body))
+		if ct != "" {
+			r.Header.Set("Content-Type", ct)
+		}
+		if tt.cookie {
+			r.AddCookie(&http.Cookie{Name: CSRFCookieName, Value: token})
+		}
+		if tt.header != "" {
+			r.Header.Set(CSRFHeader, tt.header)
+		}
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, w.Code, tt.want)
+			continue
+		}
+		if tt.want != http.StatusOK {
+			continue
+		}
+
+		// A visitor without a cookie is issued a token; one with a cookie keeps it
+		issued := w.Result().Cookies()
+		if tt.cookie {
+			if len(issued) != 0 || seen != token {
+				t.Errorf("%s: cookies %v, context token %q, want none and %q", tt.name, issued, seen, token)
+			}
+		} else if len(issued) != 1 || !issued[0].HttpOnly || len(issued[0].Value) != csrfTokenLength*2 || seen != issued[0].Value {
+			t.Errorf("%s: issued %v, context token %q", tt.name, issued, seen)
+		}
+	}
+}
+
+func TestCSRFMiddlewareBodyLimit(t *testing.T) {
+	t.Cleanup(func() { utils.SetMaxBodyBytes(0) })
+	utils.SetMaxBodyBytes(1 << 10)
+
+	token := strings.Repeat("ab", csrfTokenLength)
+	var note string
+	h := CSRFMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		note = r.PostForm.Get("note")
+		w.WriteHeader(http.StatusOK)
+	}))
+	post := func(note string) *httptest.ResponseRecorder {
+		body := url.Values{CSRFFormField: {token}, "note": {note}}.Encode()
+		r := httptest.NewRequest("POST", "/api/habits", strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.AddCookie(&http.Cookie{Name: CSRFCookieName, Value: token})
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	if w := post(strings.Repeat("x", 2<<10)); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized form = %d, want 413", w.Code)
+	}
+
+	// The form parsed for the token is the one the handler reads
+	if w := post("ok"); w.Code != http.StatusOK || note != "ok" {
+		t.Errorf("small form = %d with note %q, want 200 and %q", w.Code, note, "ok")
+	}
+}
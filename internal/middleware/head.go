@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// headRW swallows the response body of a HEAD request while counting its size
+type headRW struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (w *headRW) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+}
+
+func (w *headRW) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.bytes += len(b)
+	return len(b), nil
+}
+
+// HeadMiddleware serves HEAD requests under prefix by running the GET handler
+// (the mux routes HEAD to GET patterns), discarding the body and reporting its
+// length in Content-Length. Without it net/http only sets Content-Length for
+// HEAD when the body happens to fit in its write buffer.
+func HeadMiddleware(prefix string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodHead || !strings.HasPrefix(r.URL.Path, prefix) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			hrw := &headRW{ResponseWriter: w}
+			next.ServeHTTP(hrw, r)
+
+			if hrw.status == 0 {
+				hrw.status = http.StatusOK
+			}
+			if w.Header().Get("Content-Length") == "" {
+				w.Header().Set("Content-Length", strconv.Itoa(hrw.bytes))
+			}
+			w.WriteHeader(hrw.status)
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHeadMiddleware(t *testing.T) {
+	body := strings.Repeat("x", 10000)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/items", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	})
+	mux.HandleFunc("GET /api/missing", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Not found", http.StatusNotFound)
+	})
+	mux.HandleFunc("GET /page", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	})
+	h := HeadMiddleware("/api/")(mux)
+
+	tests := []struct {
+		path       string
+		wantStatus int
+		wantLength string
+	}{
+		{"/api/items", http.StatusOK, "10000"},
+		{"/api/missing", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("HEAD", tt.path, nil))
+		if w.Code != tt.wantStatus {
+			t.Errorf("HEAD %s = %d, want %d", tt.path, w.Code, tt.wantStatus)
+		}
+		if w.Body.Len() != 0 {
+			t.Errorf("HEAD %s wrote a %d byte body", tt.path, w.Body.Len())
+		}
+		if tt.wantLength != "" && w.Header().Get("Content-Length") != tt.wantLength {
+			t.Errorf("HEAD %s Content-Length = %q, want %s", tt.path, w.Header().Get("Content-Length"), tt.wantLength)
+		}
+	}
+
+	// GET and routes outside the prefix pass through untouched
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/api/items", nil))
+	if w.Body.Len() != len(body) {
+		t.Errorf("GET body = %d bytes, want %d", w.Body.Len(), len(body))
+	}
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("HEAD", "/page", nil))
+	if w.Header().Get("Content-Length") != "" {
+		t.Errorf("HEAD outside the prefix got Content-Length %q", w.Header().Get("Content-Length"))
+	}
+}
@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+)
+
+type loggerKey string
+
+const LoggerContextKey loggerKey = "logger"
+
+// LoggerMiddleware attaches a request-scoped log entry to the context carrying
+// the request ID, method, path and, once authenticated, the user. Place it
+// inside AuthMiddleware so the user is known.
+func LoggerMiddleware(log *logrus.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fields := logrus.Fields{
+				"request_id": GetRequestIDFromContext(r.Context()),
+				"method":     r.Method,
+				"path":       r.URL.Path,
+			}
+			if user, ok := GetUserFromContext(r.Context()); ok {
+				fields["user_id"] = user.ID
+				fields["username"] = user.Username
+			}
+			ctx := context.WithValue(r.Context(), LoggerContextKey, log.WithFields(fields))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// LoggerFromContext returns the request's log entry, or an entry on the
+// standard logger when LoggerMiddleware did not run
+func LoggerFromContext(ctx context.Context) *logrus.Entry {
+	if entry, ok := ctx.Value(LoggerContextKey).(*logrus.Entry); ok {
+		return entry
+	}
+	return logrus.NewEntry(logrus.StandardLogger())
+}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/noahjalex/epoch/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+func TestLoggerFromContextCarriesRequestFields(t *testing.T) {
+	var out bytes.Buffer
+	log := logrus.New()
+	log.SetOutput(&out)
+	log.SetFormatter(&logrus.JSONFormatter{})
+
+	h := RequestIDMiddleware()(LoggerMiddleware(log)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		LoggerFromContext(r.Context()).Info("handled")
+	})))
+
+	r := httptest.NewRequest("POST", "/api/habits", nil)
+	r.Header.Set("X-Request-ID", "abc123")
+	r = r.WithContext(context.WithValue(r.Context(), UserContextKey, &models.AppUser{ID: 7, Username: "ada"}))
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	var entry map[string]any
+	if err := json.Unmarshal(out.Bytes(), &entry); err != nil {
+		t.Fatalf("log output %q: %v", out.String(), err)
+	}
+	want := map[string]any{
+		"msg":        "handled",
+		"request_id": "abc123",
+		"method":     "POST",
+		"path":       "/api/habits",
+		"user_id":    float64(7),
+		"username":   "ada",
+	}
+	for k, v := range want {
+		if entry[k] != v {
+			t.Errorf("%s = %v, want %v", k, entry[k], v)
+		}
+	}
+
+	// Without the middleware there is still an entry to log through
+	if LoggerFromContext(context.Background()) == nil {
+		t.Error("LoggerFromContext without middleware returned nil")
+	}
+}
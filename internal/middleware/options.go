@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+)
+
+// probeMethods are checked against the mux to build the Allow header
+var probeMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+}
+
+// OptionsMiddleware answers OPTIONS requests with 204 and an Allow header
+// listing the methods mux routes for the path. The "/" catch-all pattern only
+// counts for the root path itself, so unknown paths get a 404.
+func OptionsMiddleware(mux *http.ServeMux) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodOptions {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			allowed := AllowedMethods(mux, r)
+			if len(allowed) == 0 {
+				http.NotFound(w, r)
+				return
+			}
+
+			w.Header().Set("Allow", strings.Join(append([]string{http.MethodOptions}, allowed...), ", "))
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
+
+// AllowedMethods returns the methods mux has a route for at r's path
+func AllowedMethods(mux *http.ServeMux, r *http.Request) []string {
+	var allowed []string
+	for _, m := range probeMethods {
+		probe := r.Clone(r.Context())
+		probe.Method = m
+		_, pattern := mux.Handler(probe)
+		if pattern == "" || (pattern == "/" && r.URL.Path != "/") {
+			continue
+		}
+		allowed = append(allowed, m)
+	}
+	return allowed
+}
@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOptionsMiddleware(t *testing.T) {
+	noop := func(w http.ResponseWriter, r *http.Request) {}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", noop)
+	mux.HandleFunc("GET /api/habits", noop)
+	mux.HandleFunc("POST /api/habits", noop)
+	mux.HandleFunc("PATCH /api/habits/{id}", noop)
+	mux.HandleFunc("DELETE /api/habits/{id}", noop)
+
+	var reached bool
+	h := OptionsMiddleware(mux)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+	}))
+
+	tests := []struct {
+		path       string
+		wantStatus int
+		wantAllow  string
+	}{
+		{"/api/habits", http.StatusNoContent, "OPTIONS, GET, HEAD, POST"},
+		{"/api/habits/7", http.StatusNoContent, "OPTIONS, PATCH, DELETE"},
+		{"/", http.StatusNoContent, "OPTIONS, GET, HEAD, POST, PUT, PATCH, DELETE"},
+		{"/api/nope", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		reached = false
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("OPTIONS", tt.path, nil))
+		if w.Code != tt.wantStatus || w.Header().Get("Allow") != tt.wantAllow {
+			t.Errorf("OPTIONS %s = %d Allow %q, want %d Allow %q", tt.path, w.Code, w.Header().Get("Allow"), tt.wantStatus, tt.wantAllow)
+		}
+		if reached {
+			t.Errorf("OPTIONS %s reached the next handler", tt.path)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/api/habits", nil))
+	if !reached {
+		t.Error("GET did not reach the next handler")
+	}
+}
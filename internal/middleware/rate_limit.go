@@ -0,0 +1,139 @@
+package middleware
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokenBucket is one key's remaining allowance as of last
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiter is a token bucket per key: each holds up to max tokens, refills
+// at max per window, and a request spends one. Unlike a fixed window, a client
+// cannot double its burst by straddling a window boundary.
+type rateLimiter struct {
+	mu        sync.Mutex
+	max       float64
+	window    time.Duration
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+// allow spends a token for key, reporting false and the time until one is
+// available when the bucket is empty
+func (l *rateLimiter) allow(key string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// A bucket idle for a whole window is full again, the same as a missing
+	// one, so drop those at most once per window to keep idle keys from piling up
+	if now.Sub(l.lastSweep) >= l.window {
+		for k, b := range l.buckets {
+			if now.Sub(b.last) >= l.window {
+				delete(l.buckets, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: l.max, last: now}
+		l.buckets[key] = b
+	}
+	perSecond := l.max / l.window.Seconds()
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = math.Min(l.max, b.tokens+elapsed.Seconds()*perSecond)
+		b.last = now
+	}
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / perSecond * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// RateLimit allows bursts of max requests from each client IP, refilled
+// evenly over window. Excess requests get 429 with Retry-After. State is in
+// memory, per middleware instance. A non-positive max or window disables the
+// limit.
+//
+// The IP is the connection's remote address unless ipHeader names a header
+// set by a trusted reverse proxy, such as X-Forwarded-For or X-Real-IP; its
+// last entry is used, as that is the one the proxy itself appended. Only set
+// ipHeader behind a proxy that overwrites or appends to it, since clients can
+// send any value.
+func RateLimit(max int, window time.Duration, ipHeader string) func(http.Handler) http.Handler {
+	return rateLimit(max, window, func(r *http.Request) (string, bool) {
+		return clientIP(r, ipHeader), true
+	})
+}
+
+// UserRateLimit is RateLimit keyed by the authenticated user's ID, so one
+// account gets a single allowance however many addresses it comes from. It
+// belongs inside AuthMiddleware; requests without a user pass unlimited.
+func UserRateLimit(max int, window time.Duration) func(http.Handler) http.Handler {
+	return rateLimit(max, window, func(r *http.Request) (string, bool) {
+		user, ok := GetUserFromContext(r.Context())
+		if !ok {
+			return "", false
+		}
+		return strconv.FormatInt(user.ID, 10), true
+	})
+}
+
+// rateLimit limits requests per key; key reports false for requests that are
+// not limited
+func rateLimit(max int, window time.Duration, key func(*http.Request) (string, bool)) func(http.Handler) http.Handler {
+	if max <= 0 || window <= 0 {
+		return func(next http.Handler) http.Handler { return next }
+	}
+	l := &rateLimiter{max: float64(max), window: window, buckets: make(map[string]*tokenBucket)}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			k, limited := key(r)
+			if !limited {
+				next.ServeHTTP(w, r)
+				return
+			}
+			ok, retry := l.allow(k, time.Now())
+			if !ok {
+				secs := int(math.Ceil(retry.Seconds()))
+				if secs < 1 {
+					secs = 1
+				}
+				w.Header().Set("Retry-After", strconv.Itoa(secs))
+				http.Error(w, "Too many requests", http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// clientIP returns the last address in the trusted ipHeader when it is set
+// and present, and the remote address otherwise
+func clientIP(r *http.Request, ipHeader string) string {
+	if ipHeader != "" {
+		if v := r.Header.Values(ipHeader); len(v) > 0 {
+			parts := strings.Split(v[len(v)-1], ",")
+			if ip := strings.TrimSpace(parts[len(parts)-1]); ip != "" {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return host
+}
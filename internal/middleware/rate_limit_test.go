@@ -0,0 +1,174 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/noahjalex/epoch/internal/models"
+)
+
+func newTestLimiter(max int, window time.Duration) *rateLimiter {
+	return &rateLimiter{max: float64(max), window: window, buckets: make(map[string]*tokenBucket)}
+}
+
+func TestRateLimiterBurstThenRefill(t *testing.T) {
+	l := newTestLimiter(3, 3*time.Second)
+	now := time.Unix(1_700_000_000, 0)
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.allow("a", now); !ok {
+			t.Fatalf("request %d refused within the burst", i+1)
+		}
+	}
+	ok, retry := l.allow("a", now)
+	if ok {
+		t.Fatal("request past the burst allowed")
+	}
+	if retry != time.Second {
+		t.Errorf("retry = %v, want 1s for one token", retry)
+	}
+
+	// Other keys have their own bucket
+	if ok, _ := l.allow("b", now); !ok {
+		t.Error("another key was limited")
+	}
+
+	// One token comes back per second, not the whole burst
+	now = now.Add(time.Second)
+	if ok, _ := l.allow("a", now); !ok {
+		t.Error("refilled token refused")
+	}
+	if ok, _ := l.allow("a", now); ok {
+		t.Error("more than one token refilled after a second")
+	}
+}
+
+func TestRateLimiterNoDoubleBurstAcrossWindows(t *testing.T) {
+	// A fixed window would allow 2*max around a boundary; the bucket allows
+	// max plus what refilled in between
+	l := newTestLimiter(10, 10*time.Second)
+	start := time.Unix(1_700_000_000, 0)
+	allowed := 0
+	for i := 0; i < 40; i++ {
+		// 40 requests over 2 seconds
+		if ok, _ := l.allow("a", start.Add(time.Duration(i)*50*time.Millisecond)); ok {
+			allowed++
+		}
+	}
+	if allowed > 12 {
+		t.Errorf("%d requests allowed in 2s, want at most 12", allowed)
+	}
+}
+
+func TestRateLimiterSweepsIdleBuckets(t *testing.T) {
+	l := newTestLimiter(1, time.Second)
+	now := time.Unix(1_700_000_000, 0)
+	l.allow("a", now)
+	l.allow("b", now.Add(2*time.Second))
+	if _, ok := l.buckets["a"]; ok {
+		t.Error("idle bucket kept after a full window")
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	h := RateLimit(1, time.Minute, "")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	do := func(addr string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/api/habits", nil)
+		r.RemoteAddr = addr
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+	if w := do("10.0.0.1:1000"); w.Code != http.StatusOK {
+		t.Fatalf("first request: status %d", w.Code)
+	}
+	w := do("10.0.0.1:2000")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("second request from the same IP: status %d, want 429", w.Code)
+	}
+	if w.Header().Get("Retry-After") != "60" {
+		t.Errorf("Retry-After = %q, want 60", w.Header().Get("Retry-After"))
+	}
+	if w := do("10.0.0.2:1000"); w.Code != http.StatusOK {
+		t.Errorf("another IP: status %d", w.Code)
+	}
+}
+
+func TestUserRateLimit(t *testing.T) {
+	h := UserRateLimit(1, time.Minute)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	do := func(userID int64, addr string) int {
+		r := httptest.NewRequest("GET", "/api/habits", nil)
+		r.RemoteAddr = addr
+		if userID != 0 {
+			r = r.WithContext(context.WithValue(r.Context(), UserContextKey, &models.AppUser{ID: userID}))
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	if code := do(1, "10.0.0.1:1000"); code != http.StatusOK {
+		t.Fatalf("first request: status %d", code)
+	}
+	// The bucket follows the user, not the address
+	if code := do(1, "10.0.0.2:1000"); code != http.StatusTooManyRequests {
+		t.Errorf("same user from another IP: status %d, want 429", code)
+	}
+	if code := do(2, "10.0.0.1:1000"); code != http.StatusOK {
+		t.Errorf("another user from the same IP: status %d", code)
+	}
+	// Requests without a user are left to the IP limit
+	for i := 0; i < 3; i++ {
+		if code := do(0, "10.0.0.1:1000"); code != http.StatusOK {
+			t.Fatalf("anonymous request %d: status %d", i+1, code)
+		}
+	}
+}
+
+func TestRateLimitDisabled(t *testing.T) {
+	h := RateLimit(0, time.Minute, "")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for i := 0; i < 5; i++ {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("status %d with the limit disabled", w.Code)
+		}
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   string
+		values   []string
+		remote   string
+		expected string
+	}{
+		{"remote address", "", nil, "203.0.113.5:4000", "203.0.113.5"},
+		{"header ignored unless trusted", "", []string{"198.51.100.1"}, "203.0.113.5:4000", "203.0.113.5"},
+		{"trusted header", "X-Real-IP", []string{"198.51.100.1"}, "10.0.0.1:4000", "198.51.100.1"},
+		{"last forwarded entry", "X-Forwarded-For", []string{"1.1.1.1, 198.51.100.1"}, "10.0.0.1:4000", "198.51.100.1"},
+		{"last of repeated headers", "X-Forwarded-For", []string{"1.1.1.1", "198.51.100.2"}, "10.0.0.1:4000", "198.51.100.2"},
+		{"missing header falls back", "X-Forwarded-For", nil, "10.0.0.1:4000", "10.0.0.1"},
+		{"remote without port", "", nil, "10.0.0.1", "10.0.0.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remote
+			for _, v := range tt.values {
+				name := tt.header
+				if name == "" {
+					name = "X-Forwarded-For"
+				}
+				r.Header.Add(name, v)
+			}
+			if got := clientIP(r, tt.header); got != tt.expected {
+				t.Errorf("clientIP = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
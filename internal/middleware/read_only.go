@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+// ReadOnly is a switch that, while on, makes its middleware refuse writes to
+// the API. It is safe for concurrent use, so an admin can flip it at runtime.
+type ReadOnly struct {
+	on atomic.Bool
+}
+
+// NewReadOnly returns a switch that starts on when enabled is true
+func NewReadOnly(enabled bool) *ReadOnly {
+	ro := &ReadOnly{}
+	ro.on.Store(enabled)
+	return ro
+}
+
+// Enabled reports whether writes are currently refused
+func (ro *ReadOnly) Enabled() bool {
+	return ro.on.Load()
+}
+
+// Set turns read-only mode on or off
+func (ro *ReadOnly) Set(enabled bool) {
+	ro.on.Store(enabled)
+}
+
+// Middleware answers unsafe methods (POST, PUT, PATCH, DELETE) on /api/ routes with a 503
+// JSON error while read-only mode is on. Reads and pages outside /api/ pass
+// through, as do requests to the exempt paths (exact match), so the switch
+// can still be turned off.
+func (ro *ReadOnly) Middleware(exempt ...string) func(http.Handler) http.Handler {
+	skip := make(map[string]struct{}, len(exempt))
+	for _, p := range exempt {
+		skip[p] = struct{}{}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if ro.Enabled() && !isSafeMethod(r.Method) && strings.HasPrefix(r.URL.Path, "/api/") {
+				if _, ok := skip[r.URL.Path]; !ok {
+					w.Header().Set("Content-Type", "application/json")
+					w.Header().Set("Retry-After", "60")
+					w.WriteHeader(http.StatusServiceUnavailable)
+					json.NewEncoder(w).Encode(map[string]string{"error": "server is in read-only mode"})
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestReadOnlyMiddleware(t *testing.T) {
+	ro := NewReadOnly(true)
+	h := ro.Middleware("/api/admin/read-only")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		method, path string
+		want         int
+	}{
+		{"GET", "/api/habits", http.StatusOK},
+		{"HEAD", "/api/habits", http.StatusOK},
+		{"OPTIONS", "/api/habits", http.StatusOK},
+		{"POST", "/api/habits", http.StatusServiceUnavailable},
+		{"PUT", "/api/habits/1", http.StatusServiceUnavailable},
+		{"PATCH", "/api/habits/1", http.StatusServiceUnavailable},
+		{"DELETE", "/api/logs/1", http.StatusServiceUnavailable},
+		{"POST", "/api/admin/read-only", http.StatusOK},
+		{"POST", "/logout", http.StatusOK},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+		if w.Code != tt.want {
+			t.Errorf("%s %s = %d, want %d", tt.method, tt.path, w.Code, tt.want)
+		}
+		if tt.want == http.StatusServiceUnavailable && w.Header().Get("Retry-After") == "" {
+			t.Errorf("%s %s: missing Retry-After", tt.method, tt.path)
+		}
+	}
+
+	// Turning the switch off lets writes through again
+	ro.Set(false)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/api/habits", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("POST after Set(false) = %d, want 200", w.Code)
+	}
+}
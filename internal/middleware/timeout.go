@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// timeoutRW turns the 500 a handler writes after its deadline has passed into
+// a 504, dropping the handler's body, which is usually a driver error such as
+// "pq: canceling statement due to user request"
+type timeoutRW struct {
+	http.ResponseWriter
+	ctx      context.Context
+	timedOut bool
+}
+
+func (w *timeoutRW) WriteHeader(code int) {
+	if code == http.StatusInternalServerError && errors.Is(w.ctx.Err(), context.DeadlineExceeded) {
+		w.timedOut = true
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.ResponseWriter.WriteHeader(http.StatusGatewayTimeout)
+		w.ResponseWriter.Write([]byte("Request timed out\n"))
+		return
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *timeoutRW) Write(b []byte) (int, error) {
+	if w.timedOut {
+		return len(b), nil
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush passes through so handlers that stream can still push partial output
+func (w *timeoutRW) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (w *timeoutRW) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Timeout gives each request a context deadline of d, so repo calls made with
+// the request context are cancelled rather than hanging. A handler that fails
+// with a 500 once the deadline has passed answers 504 instead. Zero or less
+// disables the deadline. Requests to the exempt paths (exact match), such as
+// streaming exports and bulk imports, run without one.
+func Timeout(d time.Duration, exempt ...string) func(http.Handler) http.Handler {
+	skip := make(map[string]struct{}, len(exempt))
+	for _, p := range exempt {
+		skip[p] = struct{}{}
+	}
+
+	return func(next http.Handler) http.Handler {
+		if d <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := skip[r.URL.Path]; ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+
+			next.ServeHTTP(&timeoutRW{ResponseWriter: w, ctx: ctx}, r.WithContext(ctx))
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTimeoutSetsDeadline(t *testing.T) {
+	var hasDeadline bool
+	h := Timeout(time.Minute)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, hasDeadline = r.Context().Deadline()
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/habits", nil))
+	if !hasDeadline {
+		t.Error("request context has no deadline")
+	}
+}
+
+func TestTimeoutAnswers504AfterDeadline(t *testing.T) {
+	h := Timeout(time.Millisecond)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		http.Error(w, "pq: canceling statement due to user request", http.StatusInternalServerError)
+	}))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/api/habits", nil))
+	if w.Code != http.StatusGatewayTimeout {
+		t.Errorf("status = %d, want 504", w.Code)
+	}
+	if got := w.Body.String(); got != "Request timed out\n" {
+		t.Errorf("body = %q", got)
+	}
+}
+
+func TestTimeoutExemptPaths(t *testing.T) {
+	var hasDeadline bool
+	h := Timeout(time.Minute, "/api/logs/export.csv")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, hasDeadline = r.Context().Deadline()
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/logs/export.csv", nil))
+	if hasDeadline {
+		t.Error("exempt path got a deadline")
+	}
+}
+
+func TestTimeoutForwardsFlush(t *testing.T) {
+	h := Timeout(time.Minute)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f, ok := w.(http.Flusher)
+		if !ok {
+			t.Fatal("writer does not implement http.Flusher")
+		}
+		w.Write([]byte("row\n"))
+		f.Flush()
+	}))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/api/habits", nil))
+	if !w.Flushed {
+		t.Error("flush did not reach the underlying writer")
+	}
+}
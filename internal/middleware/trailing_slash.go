@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Trailing-slash normalization modes
+const (
+	TrailingSlashOff      = "off"      // leave paths untouched
+	TrailingSlashRedirect = "redirect" // redirect to the path without the slash
+	TrailingSlashRewrite  = "rewrite"  // strip the slash before routing
+)
+
+// TrailingSlashMiddleware normalizes "/api/habits/" to "/api/habits" so both
+// forms reach the same handler. The root path "/" is left alone. In redirect
+// mode GET/HEAD get a 301; other methods get a 308 so the method and body
+// survive the redirect.
+func TrailingSlashMiddleware(mode string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if mode != TrailingSlashRedirect && mode != TrailingSlashRewrite {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			path := r.URL.Path
+			if path == "/" || !strings.HasSuffix(path, "/") {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			trimmed := strings.TrimRight(path, "/")
+			if trimmed == "" {
+				trimmed = "/"
+			}
+
+			if mode == TrailingSlashRedirect {
+				target := *r.URL
+				target.Path = trimmed
+				target.RawPath = ""
+				code := http.StatusMovedPermanently
+				if r.Method != http.MethodGet && r.Method != http.MethodHead {
+					code = http.StatusPermanentRedirect
+				}
+				http.Redirect(w, r, target.String(), code)
+				return
+			}
+
+			r2 := r.Clone(r.Context())
+			r2.URL.Path = trimmed
+			r2.URL.RawPath = ""
+			next.ServeHTTP(w, r2)
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTrailingSlashMiddleware(t *testing.T) {
+	var gotPath string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+	})
+
+	tests := []struct {
+		mode, method, target string
+		wantStatus           int
+		wantPath             string // path seen by next, or the redirect Location
+	}{
+		{TrailingSlashOff, "GET", "/api/habits/", http.StatusOK, "/api/habits/"},
+		{TrailingSlashRewrite, "GET", "/api/habits/", http.StatusOK, "/api/habits"},
+		{TrailingSlashRewrite, "POST", "/api/habits//", http.StatusOK, "/api/habits"},
+		{TrailingSlashRewrite, "GET", "/", http.StatusOK, "/"},
+		{TrailingSlashRedirect, "GET", "/api/habits/?limit=5", http.StatusMovedPermanently, "/api/habits?limit=5"},
+		{TrailingSlashRedirect, "HEAD", "/api/habits/", http.StatusMovedPermanently, "/api/habits"},
+		{TrailingSlashRedirect, "POST", "/api/logs/", http.StatusPermanentRedirect, "/api/logs"},
+		{TrailingSlashRedirect, "GET", "/api/habits", http.StatusOK, "/api/habits"},
+	}
+	for _, tt := range tests {
+		gotPath = ""
+		w := httptest.NewRecorder()
+		TrailingSlashMiddleware(tt.mode)(next).ServeHTTP(w, httptest.NewRequest(tt.method, tt.target, nil))
+		if w.Code != tt.wantStatus {
+			t.Errorf("%s %s %s: status %d, want %d", tt.mode, tt.method, tt.target, w.Code, tt.wantStatus)
+			continue
+		}
+		got := gotPath
+		if w.Code != http.StatusOK {
+			got = w.Header().Get("Location")
+		}
+		if got != tt.wantPath {
+			t.Errorf("%s %s %s: got %q, want %q", tt.mode, tt.method, tt.target, got, tt.wantPath)
+		}
+	}
+}
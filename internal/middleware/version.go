@@ -0,0 +1,13 @@
+package middleware
+
+import "net/http"
+
+// VersionMiddleware sets the X-Epoch-Version header on every response
+func VersionMiddleware(version string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Epoch-Version", version)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
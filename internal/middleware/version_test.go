@@ -0,0 +1,20 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestVersionMiddleware(t *testing.T) {
+	// The header is set before the handler runs, so errors carry it too
+	h := VersionMiddleware("1.2.3")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "nope", http.StatusNotFound)
+	}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/missing", nil))
+	if got := w.Header().Get("X-Epoch-Version"); got != "1.2.3" {
+		t.Errorf("X-Epoch-Version = %q, want 1.2.3", got)
+	}
+}
@@ -0,0 +1,69 @@
+package models_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/noahjalex/epoch/internal/testdb"
+)
+
+func TestDeactivateHabitKeepsArchiveTime(t *testing.T) {
+	db := testdb.Open(t)
+	repo := testdb.Repo(t)
+	ctx := context.Background()
+	user := testdb.NewUser(t, repo, "")
+	h := testdb.NewHabit(t, repo, user.ID, nil)
+
+	archivedAt := func() time.Time {
+		t.Helper()
+		var at time.Time
+		check(t, db.GetContext(ctx, &at, `SELECT archived_at FROM habit WHERE id = $1`, h.ID))
+		return at
+	}
+
+	check(t, repo.DeactivateHabit(ctx, h.ID))
+	// Backdate the archive so a reset to NOW() would be visible
+	_, err := db.ExecContext(ctx, `UPDATE habit SET archived_at = archived_at - INTERVAL '10 days' WHERE id = $1`, h.ID)
+	check(t, err)
+	first := archivedAt()
+
+	check(t, repo.DeactivateHabit(ctx, h.ID))
+	if again := archivedAt(); !again.Equal(first) {
+		t.Errorf("archiving again moved archived_at from %v to %v", first, again)
+	}
+}
+
+func TestPurgeArchivedHabits(t *testing.T) {
+	db := testdb.Open(t)
+	repo := testdb.Repo(t)
+	ctx := context.Background()
+	user := testdb.NewUser(t, repo, "")
+	old := testdb.NewHabit(t, repo, user.ID, nil)
+	recent := testdb.NewHabit(t, repo, user.ID, nil)
+	active := testdb.NewHabit(t, repo, user.ID, nil)
+	testdb.NewLog(t, repo, old.ID, time.Now().Add(-time.Hour), 1)
+
+	check(t, repo.DeactivateHabit(ctx, old.ID))
+	check(t, repo.DeactivateHabit(ctx, recent.ID))
+	_, err := db.ExecContext(ctx, `UPDATE habit SET archived_at = NOW() - INTERVAL '60 days' WHERE id = $1`, old.ID)
+	check(t, err)
+
+	_, err = repo.PurgeArchivedHabits(ctx, time.Now().AddDate(0, 0, -30))
+	check(t, err)
+
+	for _, tt := range []struct {
+		id   int64
+		kept bool
+	}{{old.ID, false}, {recent.ID, true}, {active.ID, true}} {
+		_, err := repo.GetHabit(ctx, tt.id)
+		if kept := err == nil; kept != tt.kept {
+			t.Errorf("habit %d kept = %v, want %v (err %v)", tt.id, kept, tt.kept, err)
+		}
+	}
+	var logs int
+	check(t, db.GetContext(ctx, &logs, `SELECT COUNT(*) FROM habit_log WHERE habit_id = $1`, old.ID))
+	if logs != 0 {
+		t.Errorf("purged habit left %d logs", logs)
+	}
+}
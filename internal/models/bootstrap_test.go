@@ -0,0 +1,51 @@
+package models_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/noahjalex/epoch/internal/models"
+	"github.com/noahjalex/epoch/internal/testdb"
+)
+
+func TestGetBootstrap(t *testing.T) {
+	repo := testdb.Repo(t)
+	ctx := context.Background()
+	user := testdb.NewUser(t, repo, "")
+	other := testdb.NewUser(t, repo, "")
+	a := testdb.NewHabit(t, repo, user.ID, nil)
+	b := testdb.NewHabit(t, repo, user.ID, nil)
+	archived := testdb.NewHabit(t, repo, user.ID, nil)
+	check(t, repo.DeactivateHabit(ctx, archived.ID))
+	base := time.Date(2024, 3, 10, 9, 0, 0, 0, time.UTC)
+
+	var ids []int64
+	for i, h := range []*models.Habit{a, b, a, b} {
+		ids = append(ids, testdb.NewLog(t, repo, h.ID, base.Add(time.Duration(i)*time.Hour), 1).ID)
+	}
+	testdb.NewLog(t, repo, testdb.NewHabit(t, repo, other.ID, nil).ID, base.Add(10*time.Hour), 1)
+
+	boot, err := repo.GetBootstrap(ctx, user.ID, 3)
+	check(t, err)
+
+	if len(boot.Habits) != 2 {
+		t.Errorf("%d habits, want the 2 active ones", len(boot.Habits))
+	}
+	for _, h := range boot.Habits {
+		if h.ID == archived.ID {
+			t.Error("archived habit included")
+		}
+	}
+
+	// The 3 newest of the user's logs, newest first
+	want := []int64{ids[3], ids[2], ids[1]}
+	if len(boot.RecentLogs) != len(want) {
+		t.Fatalf("%d recent logs, want %d", len(boot.RecentLogs), len(want))
+	}
+	for i, l := range boot.RecentLogs {
+		if l.ID != want[i] {
+			t.Errorf("recent log %d = %d, want %d", i, l.ID, want[i])
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package models_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/noahjalex/epoch/internal/models"
+	"github.com/noahjalex/epoch/internal/testdb"
+	"github.com/shopspring/decimal"
+)
+
+func TestCompletionRateEmptyPeriods(t *testing.T) {
+	repo := testdb.Repo(t)
+	ctx := context.Background()
+	user := testdb.NewUser(t, repo, "")
+	start := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 0, 2).Add(12 * time.Hour)
+
+	// A zero target is reached by a zero value, so only the log count can
+	// tell an empty day apart from a logged one
+	for _, tc := range []struct {
+		agg     models.AggKind
+		wantMet int
+	}{
+		{models.AggSum, 3},
+		{models.AggCount, 1},
+		{models.AggBoolean, 1},
+		{models.AggAvg, 1},
+		{models.AggMin, 1},
+		{models.AggMax, 1},
+		{models.AggLast, 1},
+	} {
+		t.Run(string(tc.agg), func(t *testing.T) {
+			h := testdb.NewHabit(t, repo, user.ID, func(h *models.Habit) {
+				h.Agg = tc.agg
+				h.TargetPerPeriod = decimal.Zero
+			})
+			testdb.NewLog(t, repo, h.ID, start.Add(9*time.Hour), 3)
+
+			met, total, err := repo.CompletionRate(ctx, h.ID, start, end)
+			check(t, err)
+			if met != tc.wantMet || total != 3 {
+				t.Errorf("CompletionRate = %d/%d, want %d/3", met, total, tc.wantMet)
+			}
+		})
+	}
+}
+
+func TestRollupBucketsAggregates(t *testing.T) {
+	repo := testdb.Repo(t)
+	ctx := context.Background()
+	user := testdb.NewUser(t, repo, "")
+	day := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+
+	// The SQL aggregation must agree with the one streaks use
+	for _, tc := range []struct {
+		agg  models.AggKind
+		want int64
+	}{
+		{models.AggSum, 9},
+		{models.AggCount, 3},
+		{models.AggBoolean, 1},
+		{models.AggAvg, 3},
+		{models.AggMin, 2},
+		{models.AggMax, 5},
+		{models.AggLast, 2},
+	} {
+		t.Run(string(tc.agg), func(t *testing.T) {
+			h := testdb.NewHabit(t, repo, user.ID, func(h *models.Habit) { h.Agg = tc.agg })
+			for i, q := range []int64{5, 2, 2} {
+				testdb.NewLog(t, repo, h.ID, day.Add(time.Duration(8+i)*time.Hour), q)
+			}
+
+			buckets, err := repo.RollupBuckets(ctx, h.ID, day, day.Add(12*time.Hour))
+			check(t, err)
+			if len(buckets) != 1 {
+				t.Fatalf("%d buckets, want 1", len(buckets))
+			}
+			if b := buckets[0]; b.Value.IntPart() != tc.want || b.LogCount != 3 {
+				t.Errorf("bucket value %s from %d logs, want %d from 3", b.Value, b.LogCount, tc.want)
+			}
+		})
+	}
+}
+
+func TestRollupBucketsWeeklyCount(t *testing.T) {
+	repo := testdb.Repo(t)
+	ctx := context.Background()
+	user := testdb.NewUser(t, repo, "")
+	h := testdb.NewHabit(t, repo, user.ID, func(h *models.Habit) {
+		h.Agg = models.AggCount
+		h.Period = models.PeriodWeekly
+		h.TargetPerPeriod = decimal.NewFromInt(3)
+	})
+	monday := time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC)
+
+	// Three gym visits in one week, each counting once whatever its quantity
+	for _, d := range []int{0, 2, 4} {
+		testdb.NewLog(t, repo, h.ID, monday.AddDate(0, 0, d).Add(18*time.Hour), 2)
+	}
+	testdb.NewLog(t, repo, h.ID, monday.AddDate(0, 0, 7).Add(18*time.Hour), 1)
+
+	buckets, err := repo.RollupBuckets(ctx, h.ID, monday, monday.AddDate(0, 0, 14))
+	check(t, err)
+	if len(buckets) != 2 {
+		t.Fatalf("%d buckets, want 2", len(buckets))
+	}
+	for i, want := range []int64{3, 1} {
+		if b := buckets[i]; b.Value.IntPart() != want {
+			t.Errorf("week %d: count %s, want %d", i, b.Value, want)
+		}
+	}
+
+	met, total, err := repo.CompletionRate(ctx, h.ID, monday, monday.AddDate(0, 0, 14))
+	check(t, err)
+	if met != 1 || total != 2 {
+		t.Errorf("CompletionRate = %d/%d, want 1/2", met, total)
+	}
+}
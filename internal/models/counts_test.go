@@ -0,0 +1,50 @@
+package models_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/noahjalex/epoch/internal/testdb"
+)
+
+func TestCountsIncludeDeleted(t *testing.T) {
+	repo := testdb.Repo(t)
+	ctx := context.Background()
+	user := testdb.NewUser(t, repo, "")
+
+	// The counts are database-wide, so compare them before and after seeding
+	counts := func() [4]int {
+		t.Helper()
+		var c [4]int
+		var err error
+		c[0], err = repo.CountHabits(ctx, false)
+		check(t, err)
+		c[1], err = repo.CountHabits(ctx, true)
+		check(t, err)
+		c[2], err = repo.CountLogs(ctx, false)
+		check(t, err)
+		c[3], err = repo.CountLogs(ctx, true)
+		check(t, err)
+		return c
+	}
+	before := counts()
+
+	h := testdb.NewHabit(t, repo, user.ID, nil)
+	archived := testdb.NewHabit(t, repo, user.ID, nil)
+	check(t, repo.DeactivateHabit(ctx, archived.ID))
+	testdb.NewLog(t, repo, h.ID, time.Now(), 1)
+	testdb.NewLog(t, repo, h.ID, time.Now(), 1)
+	deleted := testdb.NewLog(t, repo, h.ID, time.Now(), 1)
+	_, err := repo.SoftDeleteLog(ctx, deleted.ID)
+	check(t, err)
+
+	after := counts()
+	names := [4]string{"habits", "habits with archived", "logs", "logs with deleted"}
+	want := [4]int{1, 2, 2, 3}
+	for i := range want {
+		if got := after[i] - before[i]; got != want[i] {
+			t.Errorf("%s grew by %d, want %d", names[i], got, want[i])
+		}
+	}
+}
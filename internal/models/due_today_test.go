@@ -0,0 +1,47 @@
+package models_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/noahjalex/epoch/internal/models"
+	"github.com/noahjalex/epoch/internal/testdb"
+	"github.com/shopspring/decimal"
+)
+
+func TestHabitsDueToday(t *testing.T) {
+	repo := testdb.Repo(t)
+	ctx := context.Background()
+	user := testdb.NewUser(t, repo, "")
+	other := testdb.NewUser(t, repo, "")
+	now := time.Now()
+	two := func(h *models.Habit) { h.TargetPerPeriod = decimal.NewFromInt(2) }
+
+	met := testdb.NewHabit(t, repo, user.ID, two)
+	testdb.NewLog(t, repo, met.ID, now, 2)
+	partial := testdb.NewHabit(t, repo, user.ID, two)
+	testdb.NewLog(t, repo, partial.ID, now, 1)
+	stale := testdb.NewHabit(t, repo, user.ID, two)
+	testdb.NewLog(t, repo, stale.ID, now.AddDate(0, 0, -2), 5) // an earlier period
+	untouched := testdb.NewHabit(t, repo, user.ID, nil)
+	archived := testdb.NewHabit(t, repo, user.ID, nil)
+	check(t, repo.DeactivateHabit(ctx, archived.ID))
+	testdb.NewHabit(t, repo, other.ID, nil)
+
+	due, err := repo.HabitsDueToday(ctx, user.ID)
+	check(t, err)
+	got := map[int64]bool{}
+	for _, h := range due {
+		got[h.ID] = true
+	}
+	want := map[int64]bool{partial.ID: true, stale.ID: true, untouched.ID: true}
+	if len(got) != len(want) {
+		t.Errorf("due = %v, want %v", got, want)
+	}
+	for id := range want {
+		if !got[id] {
+			t.Errorf("habit %d is not due", id)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package models_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/noahjalex/epoch/internal/models"
+	"github.com/noahjalex/epoch/internal/testdb"
+)
+
+func TestDeleteHabitModes(t *testing.T) {
+	repo := testdb.Repo(t)
+	ctx := context.Background()
+	at := time.Date(2024, 3, 10, 9, 0, 0, 0, time.UTC)
+
+	t.Run("cascade", func(t *testing.T) {
+		user := testdb.NewUser(t, repo, "")
+		h := testdb.NewHabit(t, repo, user.ID, nil)
+		l := testdb.NewLog(t, repo, h.ID, at, 1)
+
+		check(t, repo.DeleteHabit(ctx, h.ID, user.ID, models.HabitDeleteCascade))
+		if _, err := repo.GetHabit(ctx, h.ID); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("habit still there: %v", err)
+		}
+		if _, err := repo.GetLog(ctx, l.ID); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("log still there: %v", err)
+		}
+	})
+
+	t.Run("archive", func(t *testing.T) {
+		user := testdb.NewUser(t, repo, "")
+		a := testdb.NewHabit(t, repo, user.ID, nil)
+		b := testdb.NewHabit(t, repo, user.ID, nil)
+		la := testdb.NewLog(t, repo, a.ID, at, 1)
+		lb := testdb.NewLog(t, repo, b.ID, at, 2)
+
+		check(t, repo.DeleteHabit(ctx, a.ID, user.ID, models.HabitDeleteArchive))
+		check(t, repo.DeleteHabit(ctx, b.ID, user.ID, models.HabitDeleteArchive))
+
+		// Both habits' logs land in one inactive archive habit
+		got, err := repo.GetLog(ctx, la.ID)
+		check(t, err)
+		archive, err := repo.GetHabit(ctx, got.HabitID)
+		check(t, err)
+		if archive.Name != models.ArchiveHabitName || archive.IsActive || archive.UserID != user.ID {
+			t.Errorf("log moved to %+v, want the user's inactive archive habit", archive)
+		}
+		got, err = repo.GetLog(ctx, lb.ID)
+		check(t, err)
+		if got.HabitID != archive.ID {
+			t.Errorf("second habit's log moved to habit %d, want archive %d", got.HabitID, archive.ID)
+		}
+
+		// The archive habit itself cannot be archived into itself
+		if err := repo.DeleteHabit(ctx, archive.ID, user.ID, models.HabitDeleteArchive); !errors.Is(err, models.ErrHabitHasLogs) {
+			t.Errorf("archiving the archive habit: err = %v, want ErrHabitHasLogs", err)
+		}
+	})
+
+	t.Run("block", func(t *testing.T) {
+		user := testdb.NewUser(t, repo, "")
+		h := testdb.NewHabit(t, repo, user.ID, nil)
+		l := testdb.NewLog(t, repo, h.ID, at, 1)
+
+		if err := repo.DeleteHabit(ctx, h.ID, user.ID, models.HabitDeleteBlock); !errors.Is(err, models.ErrHabitHasLogs) {
+			t.Fatalf("err = %v, want ErrHabitHasLogs", err)
+		}
+		if _, err := repo.GetHabit(ctx, h.ID); err != nil {
+			t.Errorf("blocked delete removed the habit: %v", err)
+		}
+
+		check(t, repo.DeleteLog(ctx, l.ID))
+		check(t, repo.DeleteHabit(ctx, h.ID, user.ID, models.HabitDeleteBlock))
+	})
+
+	t.Run("soft-deleted logs", func(t *testing.T) {
+		user := testdb.NewUser(t, repo, "")
+		blocked := testdb.NewHabit(t, repo, user.ID, nil)
+		archived := testdb.NewHabit(t, repo, user.ID, nil)
+		lb := testdb.NewLog(t, repo, blocked.ID, at, 1)
+		la := testdb.NewLog(t, repo, archived.ID, at, 2)
+		_, err := repo.SoftDeleteLog(ctx, lb.ID)
+		check(t, err)
+		_, err = repo.SoftDeleteLog(ctx, la.ID)
+		check(t, err)
+
+		// A restorable log still blocks the delete
+		if err := repo.DeleteHabit(ctx, blocked.ID, user.ID, models.HabitDeleteBlock); !errors.Is(err, models.ErrHabitHasLogs) {
+			t.Errorf("block: err = %v, want ErrHabitHasLogs", err)
+		}
+		if _, err := repo.RestoreLog(ctx, lb.ID, user.ID); err != nil {
+			t.Errorf("block: deleted log cannot be restored: %v", err)
+		}
+
+		// and is moved to the archive rather than lost with the habit
+		check(t, repo.DeleteHabit(ctx, archived.ID, user.ID, models.HabitDeleteArchive))
+		restored, err := repo.RestoreLog(ctx, la.ID, user.ID)
+		check(t, err)
+		if restored.HabitID == archived.ID {
+			t.Errorf("archive: restored log still points at the deleted habit")
+		}
+	})
+
+	t.Run("other user", func(t *testing.T) {
+		owner := testdb.NewUser(t, repo, "")
+		other := testdb.NewUser(t, repo, "")
+		h := testdb.NewHabit(t, repo, owner.ID, nil)
+
+		if err := repo.DeleteHabit(ctx, h.ID, other.ID, models.HabitDeleteCascade); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("err = %v, want sql.ErrNoRows", err)
+		}
+	})
+}
+
+func TestToHabitDeleteMode(t *testing.T) {
+	for _, s := range []string{"cascade", "archive", "block"} {
+		if m, err := models.ToHabitDeleteMode(s); err != nil || string(m) != s {
+			t.Errorf("ToHabitDeleteMode(%q) = %q, %v", s, m, err)
+		}
+	}
+	if _, err := models.ToHabitDeleteMode("purge"); err == nil {
+		t.Error("ToHabitDeleteMode(purge) succeeded")
+	}
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// HabitStats is the habit_stats snapshot of a habit's lifetime analytics,
+// counted from the period of its first log through the current one. It is
+// served as-is by the API, so its JSON names are camelCase.
+type HabitStats struct {
+	HabitID         int64           `db:"habit_id"          json:"habitId"`
+	CurrentStreak   int             `db:"current_streak"    json:"currentStreak"`
+	LongestStreak   int             `db:"longest_streak"    json:"longestStreak"`
+	LastMetPeriod   *time.Time      `db:"last_met_period"   json:"lastMetPeriod,omitempty"` // nil if no period was met
+	PeriodsMet      int             `db:"periods_met"       json:"periodsMet"`
+	PeriodsTotal    int             `db:"periods_total"     json:"periodsTotal"`
+	BestPeriodStart *time.Time      `db:"best_period_start" json:"bestPeriodStart,omitempty"` // nil if nothing was logged
+	BestPeriodValue decimal.Decimal `db:"best_period_value" json:"bestPeriodValue"`
+	ComputedAt      time.Time       `db:"computed_at"       json:"computedAt"`
+}
+
+// computeHabitStats derives a snapshot from the habit's logs, ordered by
+// occurred_at. The best period is the one with the highest aggregated value;
+// ties keep the earliest.
+func computeHabitStats(h *Habit, logs []HabitLog, loc *time.Location, now time.Time, grace decimal.Decimal) HabitStats {
+	streak := computeStreak(h, logs, loc, now, grace)
+	st := HabitStats{
+		HabitID:         h.ID,
+		CurrentStreak:   streak.Current,
+		LongestStreak:   streak.Longest,
+		BestPeriodValue: decimal.Zero,
+	}
+	if !streak.LastMetPeriod.IsZero() {
+		st.LastMetPeriod = &streak.LastMetPeriod
+	}
+
+	eachPeriod(h, logs, loc, now, func(start time.Time, logs []HabitLog) {
+		st.PeriodsTotal++
+		if periodMet(h, logs, grace) {
+			st.PeriodsMet++
+		}
+		if len(logs) == 0 {
+			return
+		}
+		if v := periodValue(h.Agg, logs); st.BestPeriodStart == nil || v.GreaterThan(st.BestPeriodValue) {
+			st.BestPeriodStart = &start
+			st.BestPeriodValue = v
+		}
+	})
+	return st
+}
@@ -0,0 +1,141 @@
+package models_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/noahjalex/epoch/internal/models"
+	"github.com/noahjalex/epoch/internal/testdb"
+	"github.com/shopspring/decimal"
+)
+
+func TestHabitStatsInvalidatedByChanges(t *testing.T) {
+	repo := testdb.Repo(t)
+	ctx := context.Background()
+	user := testdb.NewUser(t, repo, "")
+	at := time.Now().Add(-time.Hour)
+
+	tests := []struct {
+		name   string
+		change func(t *testing.T, h *models.Habit)
+	}{
+		{"insert", func(t *testing.T, h *models.Habit) { testdb.NewLog(t, repo, h.ID, at, 1) }},
+		{"insert with progress", func(t *testing.T, h *models.Habit) {
+			_, _, _, err := repo.InsertLogWithProgress(ctx, &models.HabitLog{HabitID: h.ID, OccurredAt: at, Quantity: decimal.NewFromInt(1)})
+			check(t, err)
+		}},
+		{"import", func(t *testing.T, h *models.Habit) {
+			check(t, repo.InsertLogsBatch(ctx, []*models.HabitLog{{HabitID: h.ID, OccurredAt: at, Quantity: decimal.NewFromInt(1)}}))
+		}},
+		{"import each", func(t *testing.T, h *models.Habit) {
+			_, err := repo.InsertLogsEach(ctx, []*models.HabitLog{{HabitID: h.ID, OccurredAt: at, Quantity: decimal.NewFromInt(1)}})
+			check(t, err)
+		}},
+		{"increment", func(t *testing.T, h *models.Habit) {
+			_, _, _, err := repo.IncrementLog(ctx, h, "UTC", decimal.NewFromInt(1), at)
+			check(t, err)
+		}},
+		{"upsert", func(t *testing.T, h *models.Habit) {
+			_, _, _, err := repo.UpsertLogForBucket(ctx, h.ID, at, decimal.NewFromInt(2), sql.NullString{})
+			check(t, err)
+		}},
+		{"quick complete", func(t *testing.T, h *models.Habit) {
+			_, err := repo.QuickComplete(ctx, user.ID, "UTC", []int64{h.ID}, at)
+			check(t, err)
+		}},
+		{"update log", func(t *testing.T, h *models.Habit) {
+			l := testdb.NewLog(t, repo, h.ID, at, 1)
+			refresh(t, repo, h.ID)
+			l.Quantity = decimal.NewFromInt(4)
+			_, err := repo.UpdateLog(ctx, user.ID, l)
+			check(t, err)
+		}},
+		{"soft delete log", func(t *testing.T, h *models.Habit) {
+			l := testdb.NewLog(t, repo, h.ID, at, 1)
+			refresh(t, repo, h.ID)
+			_, err := repo.SoftDeleteLog(ctx, l.ID)
+			check(t, err)
+		}},
+		{"restore log", func(t *testing.T, h *models.Habit) {
+			l := testdb.NewLog(t, repo, h.ID, at, 1)
+			_, err := repo.SoftDeleteLog(ctx, l.ID)
+			check(t, err)
+			refresh(t, repo, h.ID)
+			_, err = repo.RestoreLog(ctx, l.ID, user.ID)
+			check(t, err)
+		}},
+		{"change target", func(t *testing.T, h *models.Habit) {
+			_, err := repo.UpdateHabitFields(ctx, h.ID, user.ID, map[string]any{"target_per_period": decimal.NewFromInt(5)})
+			check(t, err)
+		}},
+		{"change period", func(t *testing.T, h *models.Habit) {
+			_, err := repo.UpdateHabitFields(ctx, h.ID, user.ID, map[string]any{"period": models.PeriodWeekly})
+			check(t, err)
+		}},
+		{"update with cosmetic fields", func(t *testing.T, h *models.Habit) {
+			_, err := repo.UpdateHabitFields(ctx, h.ID, user.ID, map[string]any{"name": "Renamed", "target_per_period": decimal.NewFromInt(3)})
+			check(t, err)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := testdb.NewHabit(t, repo, user.ID, nil)
+			refresh(t, repo, h.ID)
+			tt.change(t, h)
+			if _, err := repo.GetHabitStats(ctx, h.ID); !errors.Is(err, sql.ErrNoRows) {
+				t.Errorf("stats still stored after %s (err %v)", tt.name, err)
+			}
+		})
+	}
+}
+
+func TestHabitStatsKeptByCosmeticChanges(t *testing.T) {
+	repo := testdb.Repo(t)
+	ctx := context.Background()
+	user := testdb.NewUser(t, repo, "")
+	h := testdb.NewHabit(t, repo, user.ID, nil)
+	refresh(t, repo, h.ID)
+
+	_, err := repo.UpdateHabitFields(ctx, h.ID, user.ID, map[string]any{"name": "Renamed", "color": "#abc"})
+	check(t, err)
+	if _, err := repo.GetHabitStats(ctx, h.ID); err != nil {
+		t.Errorf("renaming dropped the stats: %v", err)
+	}
+}
+
+func TestHabitStatsMovedLogInvalidatesBothHabits(t *testing.T) {
+	repo := testdb.Repo(t)
+	ctx := context.Background()
+	user := testdb.NewUser(t, repo, "")
+	from := testdb.NewHabit(t, repo, user.ID, nil)
+	to := testdb.NewHabit(t, repo, user.ID, nil)
+	l := testdb.NewLog(t, repo, from.ID, time.Now().Add(-time.Hour), 1)
+	refresh(t, repo, from.ID)
+	refresh(t, repo, to.ID)
+
+	l.HabitID = to.ID
+	_, err := repo.UpdateLog(ctx, user.ID, l)
+	check(t, err)
+	for _, id := range []int64{from.ID, to.ID} {
+		if _, err := repo.GetHabitStats(ctx, id); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("habit %d kept its stats (err %v)", id, err)
+		}
+	}
+}
+
+func refresh(t *testing.T, repo *models.Repo, habitID int64) {
+	t.Helper()
+	if _, err := repo.RefreshHabitStats(context.Background(), habitID); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func check(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
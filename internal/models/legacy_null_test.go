@@ -0,0 +1,46 @@
+package models_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/noahjalex/epoch/internal/testdb"
+)
+
+func TestHabitReadsTolerateNullDecimals(t *testing.T) {
+	db := testdb.Open(t)
+	repo := testdb.Repo(t)
+	ctx := context.Background()
+	user := testdb.NewUser(t, repo, "")
+	h := testdb.NewHabit(t, repo, user.ID, nil)
+
+	// Recreate a row from before the columns were NOT NULL, and restore the
+	// constraint once the row is gone
+	_, err := db.ExecContext(ctx, `ALTER TABLE habit
+		ALTER COLUMN target_per_period DROP NOT NULL,
+		ALTER COLUMN per_log_default_qty DROP NOT NULL`)
+	check(t, err)
+	t.Cleanup(func() {
+		if _, err := db.ExecContext(ctx, `DELETE FROM habit WHERE user_id = $1`, user.ID); err != nil {
+			t.Error(err)
+		}
+		if _, err := db.ExecContext(ctx, `ALTER TABLE habit
+			ALTER COLUMN target_per_period SET NOT NULL,
+			ALTER COLUMN per_log_default_qty SET NOT NULL`); err != nil {
+			t.Error(err)
+		}
+	})
+	_, err = db.ExecContext(ctx, `UPDATE habit SET target_per_period = NULL, per_log_default_qty = NULL WHERE id = $1`, h.ID)
+	check(t, err)
+
+	got, err := repo.GetHabit(ctx, h.ID)
+	check(t, err)
+	if !got.TargetPerPeriod.IsZero() || !got.PerLogDefaultQty.IsZero() {
+		t.Errorf("GetHabit decimals = %s, %s, want 0, 0", got.TargetPerPeriod, got.PerLogDefaultQty)
+	}
+	list, err := repo.ListHabitsByUser(ctx, user.ID, false)
+	check(t, err)
+	if len(list) != 1 || !list[0].TargetPerPeriod.IsZero() || !list[0].PerLogDefaultQty.IsZero() {
+		t.Errorf("ListHabitsByUser = %+v, want one habit with zero decimals", list)
+	}
+}
@@ -0,0 +1,70 @@
+package models_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/noahjalex/epoch/internal/models"
+	"github.com/noahjalex/epoch/internal/testdb"
+)
+
+func TestLogCursorRoundTrip(t *testing.T) {
+	c := models.LogCursor{OccurredAt: time.Date(2024, 3, 10, 9, 30, 0, 123456000, time.FixedZone("", 3600)), ID: 42}
+	got, err := models.ParseLogCursor(c.String())
+	check(t, err)
+	if !got.OccurredAt.Equal(c.OccurredAt) || got.ID != c.ID {
+		t.Errorf("ParseLogCursor(%q) = %+v, want %+v", c.String(), got, c)
+	}
+
+	for _, s := range []string{"", "42", "yesterday,42", "2024-03-10T09:30:00Z,x"} {
+		if _, err := models.ParseLogCursor(s); err == nil {
+			t.Errorf("ParseLogCursor(%q) succeeded", s)
+		}
+	}
+}
+
+func TestListLogsAfterInsertBetweenPages(t *testing.T) {
+	repo := testdb.Repo(t)
+	ctx := context.Background()
+	user := testdb.NewUser(t, repo, "")
+	h := testdb.NewHabit(t, repo, user.ID, nil)
+	base := time.Date(2024, 3, 10, 9, 0, 0, 0, time.UTC)
+
+	// Two logs share a time so the id breaks the tie
+	want := map[int64]bool{}
+	for _, at := range []time.Time{base, base.Add(time.Hour), base.Add(time.Hour), base.Add(2 * time.Hour), base.Add(3 * time.Hour)} {
+		want[testdb.NewLog(t, repo, h.ID, at, 1).ID] = true
+	}
+
+	seen := map[int64]int{}
+	var after *models.LogCursor
+	for page := 0; ; page++ {
+		logs, next, err := repo.ListLogsAfter(ctx, user.ID, models.LogFilter{}, after, 2)
+		check(t, err)
+		for _, l := range logs {
+			seen[l.ID]++
+		}
+		if page == 0 {
+			// A newer log lands before the page already read and one older
+			// than the cursor lands ahead of it
+			testdb.NewLog(t, repo, h.ID, base.Add(4*time.Hour), 1)
+			want[testdb.NewLog(t, repo, h.ID, base.Add(-time.Hour), 1).ID] = true
+		}
+		if next == nil {
+			break
+		}
+		after = next
+	}
+
+	for id := range want {
+		if seen[id] != 1 {
+			t.Errorf("log %d seen %d times, want once", id, seen[id])
+		}
+	}
+	for id, n := range seen {
+		if n > 1 {
+			t.Errorf("log %d repeated %d times", id, n)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package models_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/noahjalex/epoch/internal/models"
+	"github.com/noahjalex/epoch/internal/testdb"
+	"github.com/shopspring/decimal"
+)
+
+func TestToLogPolicy(t *testing.T) {
+	for _, s := range []string{"multiple", "single", "replace"} {
+		if p, err := models.ToLogPolicy(s); err != nil || string(p) != s {
+			t.Errorf("ToLogPolicy(%q) = %q, %v", s, p, err)
+		}
+	}
+	for _, s := range []string{"", "Single", "once"} {
+		if _, err := models.ToLogPolicy(s); err == nil {
+			t.Errorf("ToLogPolicy(%q) succeeded", s)
+		}
+	}
+}
+
+func TestInsertLogPolicies(t *testing.T) {
+	repo := testdb.Repo(t)
+	ctx := context.Background()
+	user := testdb.NewUser(t, repo, "")
+	day := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		policy   models.LogPolicy
+		wantErr  error
+		wantLogs int   // live logs in the first day after the second insert
+		wantQty  int64 // their total
+	}{
+		{models.LogPolicyMultiple, nil, 2, 3},
+		{models.LogPolicySingle, models.ErrPeriodAlreadyLogged, 1, 1},
+		{models.LogPolicyReplace, nil, 1, 2},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			h := testdb.NewHabit(t, repo, user.ID, func(h *models.Habit) { h.LogPolicy = tt.policy })
+			insert := func(at time.Time, qty int64) error {
+				_, _, _, err := repo.InsertLogWithProgress(ctx, &models.HabitLog{HabitID: h.ID, OccurredAt: at, Quantity: decimal.NewFromInt(qty)})
+				return err
+			}
+
+			check(t, insert(day.Add(9*time.Hour), 1))
+			if err := insert(day.Add(18*time.Hour), 2); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("second insert: err = %v, want %v", err, tt.wantErr)
+			}
+			// The next day is another period under every policy
+			check(t, insert(day.AddDate(0, 0, 1), 5))
+
+			logs, err := repo.ListLogsWithin(ctx, h.ID, day, day.AddDate(0, 0, 1))
+			check(t, err)
+			total := decimal.Zero
+			for _, l := range logs {
+				total = total.Add(l.Quantity)
+			}
+			if len(logs) != tt.wantLogs || total.IntPart() != tt.wantQty {
+				t.Errorf("first day has %d logs totalling %s, want %d totalling %d", len(logs), total, tt.wantLogs, tt.wantQty)
+			}
+		})
+	}
+}
+
+func TestInsertLogOnDuplicate(t *testing.T) {
+	repo := testdb.Repo(t)
+	ctx := context.Background()
+	user := testdb.NewUser(t, repo, "")
+	at := time.Date(2024, 3, 10, 9, 0, 0, 0, time.UTC)
+	// The same instant entered from another timezone is still a duplicate
+	again := at.In(time.FixedZone("EST", -5*3600))
+
+	tests := []struct {
+		onDuplicate models.OnDuplicate
+		wantErr     error
+		wantQtys    []int64
+	}{
+		{models.OnDuplicateAllow, nil, []int64{1, 2}},
+		{models.OnDuplicateReject, models.ErrDuplicateLog, []int64{1}},
+		{models.OnDuplicateReplace, nil, []int64{2}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.onDuplicate), func(t *testing.T) {
+			h := testdb.NewHabit(t, repo, user.ID, func(h *models.Habit) { h.OnDuplicate = tt.onDuplicate })
+			insert := func(at time.Time, qty int64) (*models.HabitLog, error) {
+				l, _, _, err := repo.InsertLogWithProgress(ctx, &models.HabitLog{HabitID: h.ID, OccurredAt: at, Quantity: decimal.NewFromInt(qty)})
+				return l, err
+			}
+
+			first, err := insert(at, 1)
+			check(t, err)
+			second, err := insert(again, 2)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("second insert: err = %v, want %v", err, tt.wantErr)
+			}
+			if tt.onDuplicate == models.OnDuplicateReplace && second.ID != first.ID {
+				t.Errorf("replace returned log %d, want the existing log %d", second.ID, first.ID)
+			}
+
+			logs, err := repo.ListLogs(ctx, h.ID)
+			check(t, err)
+			var qtys []int64
+			for _, l := range logs {
+				qtys = append(qtys, l.Quantity.IntPart())
+			}
+			if !slices.Equal(qtys, tt.wantQtys) {
+				t.Errorf("quantities = %v, want %v", qtys, tt.wantQtys)
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package models_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/noahjalex/epoch/internal/models"
+	"github.com/noahjalex/epoch/internal/testdb"
+	"github.com/shopspring/decimal"
+)
+
+func TestInsertLogWithProgress(t *testing.T) {
+	repo := testdb.Repo(t)
+	ctx := context.Background()
+	user := testdb.NewUser(t, repo, "")
+	h := testdb.NewHabit(t, repo, user.ID, func(h *models.Habit) { h.TargetPerPeriod = decimal.NewFromInt(5) })
+	day := time.Date(2024, 3, 10, 9, 0, 0, 0, time.UTC)
+	testdb.NewLog(t, repo, h.ID, day, 2)
+	testdb.NewLog(t, repo, h.ID, day.AddDate(0, 0, -1), 4) // the previous period
+
+	l, value, target, err := repo.InsertLogWithProgress(ctx, &models.HabitLog{
+		HabitID:    h.ID,
+		OccurredAt: day.Add(3 * time.Hour),
+		Quantity:   decimal.NewFromInt(1),
+	})
+	check(t, err)
+	if l.ID == 0 || !l.Quantity.Equal(decimal.NewFromInt(1)) {
+		t.Errorf("inserted log = %+v", l)
+	}
+	if !value.Equal(decimal.NewFromInt(3)) || !target.Equal(decimal.NewFromInt(5)) {
+		t.Errorf("progress = %s/%s, want 3/5", value, target)
+	}
+}
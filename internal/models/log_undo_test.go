@@ -0,0 +1,78 @@
+package models_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/noahjalex/epoch/internal/testdb"
+)
+
+func TestSoftDeleteAndRestoreLog(t *testing.T) {
+	repo := testdb.Repo(t)
+	ctx := context.Background()
+	user := testdb.NewUser(t, repo, "")
+	other := testdb.NewUser(t, repo, "")
+	h := testdb.NewHabit(t, repo, user.ID, nil)
+	l := testdb.NewLog(t, repo, h.ID, time.Now().Add(-time.Hour), 3)
+
+	deleted, err := repo.SoftDeleteLog(ctx, l.ID)
+	check(t, err)
+	if deleted.ID != l.ID || !deleted.Quantity.Equal(l.Quantity) {
+		t.Errorf("SoftDeleteLog returned %+v, want the deleted log", deleted)
+	}
+	logs, err := repo.ListLogs(ctx, h.ID)
+	check(t, err)
+	if len(logs) != 0 {
+		t.Errorf("ListLogs after delete = %d logs, want 0", len(logs))
+	}
+	if _, err := repo.SoftDeleteLog(ctx, l.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("second delete: err = %v, want sql.ErrNoRows", err)
+	}
+
+	if _, err := repo.RestoreLog(ctx, l.ID, other.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("restore by another user: err = %v, want sql.ErrNoRows", err)
+	}
+	restored, err := repo.RestoreLog(ctx, l.ID, user.ID)
+	check(t, err)
+	if restored.ID != l.ID {
+		t.Errorf("RestoreLog returned %+v", restored)
+	}
+	logs, err = repo.ListLogs(ctx, h.ID)
+	check(t, err)
+	if len(logs) != 1 {
+		t.Errorf("ListLogs after restore = %d logs, want 1", len(logs))
+	}
+}
+
+func TestPurgeDeletedLogs(t *testing.T) {
+	db := testdb.Open(t)
+	repo := testdb.Repo(t)
+	ctx := context.Background()
+	user := testdb.NewUser(t, repo, "")
+	h := testdb.NewHabit(t, repo, user.ID, nil)
+	old := testdb.NewLog(t, repo, h.ID, time.Now().AddDate(0, 0, -40), 1)
+	recent := testdb.NewLog(t, repo, h.ID, time.Now().AddDate(0, 0, -1), 1)
+	live := testdb.NewLog(t, repo, h.ID, time.Now(), 1)
+
+	for _, l := range []int64{old.ID, recent.ID} {
+		_, err := repo.SoftDeleteLog(ctx, l)
+		check(t, err)
+	}
+	_, err := db.ExecContext(ctx, `UPDATE habit_log SET deleted_at = NOW() - INTERVAL '40 days' WHERE id = $1`, old.ID)
+	check(t, err)
+
+	n, err := repo.PurgeDeletedLogs(ctx, time.Now().AddDate(0, 0, -30))
+	check(t, err)
+	if n < 1 {
+		t.Errorf("purged %d logs, want at least 1", n)
+	}
+
+	var ids []int64
+	check(t, db.SelectContext(ctx, &ids, `SELECT id FROM habit_log WHERE habit_id = $1 ORDER BY id`, h.ID))
+	if len(ids) != 2 || ids[0] != recent.ID || ids[1] != live.ID {
+		t.Errorf("remaining logs = %v, want [%d %d]", ids, recent.ID, live.ID)
+	}
+}
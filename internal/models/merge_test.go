@@ -0,0 +1,54 @@
+package models_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/noahjalex/epoch/internal/models"
+	"github.com/noahjalex/epoch/internal/testdb"
+)
+
+func TestMergeHabits(t *testing.T) {
+	repo := testdb.Repo(t)
+	ctx := context.Background()
+	user := testdb.NewUser(t, repo, "")
+	other := testdb.NewUser(t, repo, "")
+	day := time.Date(2024, 3, 10, 9, 0, 0, 0, time.UTC)
+
+	keep := testdb.NewHabit(t, repo, user.ID, nil)
+	merge := testdb.NewHabit(t, repo, user.ID, nil)
+	testdb.NewLog(t, repo, keep.ID, day, 1)
+	testdb.NewLog(t, repo, merge.ID, day.Add(time.Hour), 2)
+	testdb.NewLog(t, repo, merge.ID, day.AddDate(0, 0, 1), 3)
+	foreign := testdb.NewHabit(t, repo, other.ID, nil)
+	testdb.NewLog(t, repo, foreign.ID, day, 4)
+
+	// Another user's habit on either side leaves both untouched
+	for _, ids := range [][2]int64{{keep.ID, foreign.ID}, {foreign.ID, merge.ID}} {
+		if err := repo.MergeHabits(ctx, ids[0], ids[1], user.ID); !errors.Is(err, models.ErrHabitNotOwned) {
+			t.Errorf("merge %d into %d: err = %v, want ErrHabitNotOwned", ids[1], ids[0], err)
+		}
+	}
+	if err := repo.MergeHabits(ctx, keep.ID, keep.ID, user.ID); err == nil {
+		t.Error("merging a habit into itself succeeded")
+	}
+	if logs, err := repo.ListLogs(ctx, foreign.ID); err != nil || len(logs) != 1 {
+		t.Errorf("foreign habit logs = %d, %v; want 1 untouched", len(logs), err)
+	}
+
+	check(t, repo.MergeHabits(ctx, keep.ID, merge.ID, user.ID))
+	logs, err := repo.ListLogs(ctx, keep.ID)
+	check(t, err)
+	var total int64
+	for _, l := range logs {
+		total += l.Quantity.IntPart()
+	}
+	if len(logs) != 3 || total != 6 {
+		t.Errorf("kept habit has %d logs totalling %d, want 3 totalling 6", len(logs), total)
+	}
+	if _, err := repo.GetHabit(ctx, merge.ID); err == nil {
+		t.Error("merged habit still exists")
+	}
+}
@@ -39,11 +39,15 @@ const (
 	AggSum     AggKind = "sum"
 	AggBoolean AggKind = "boolean"
 	AggCount   AggKind = "count"
+	AggAvg     AggKind = "avg"
+	AggMin     AggKind = "min"
+	AggMax     AggKind = "max"
+	AggLast    AggKind = "last" // quantity of the latest log in the period
 )
 
 func ToAggKind(s string) (AggKind, error) {
 	switch AggKind(s) {
-	case AggSum, AggBoolean, AggCount:
+	case AggSum, AggBoolean, AggCount, AggAvg, AggMin, AggMax, AggLast:
 		return AggKind(s), nil
 	default:
 		return "", fmt.Errorf("unrecognized aggregate kind %s", s)
@@ -68,6 +72,106 @@ func ToPeriodType(s string) (PeriodType, error) {
 	}
 }
 
+// LogPolicy controls how many logs a habit accepts per period
+type LogPolicy string
+
+const (
+	LogPolicyMultiple LogPolicy = "multiple" // any number of logs per period
+	LogPolicySingle   LogPolicy = "single"   // reject a second log in the same period
+	LogPolicyReplace  LogPolicy = "replace"  // a new log replaces the period's existing logs
+)
+
+func ToLogPolicy(s string) (LogPolicy, error) {
+	switch LogPolicy(s) {
+	case LogPolicyMultiple, LogPolicySingle, LogPolicyReplace:
+		return LogPolicy(s), nil
+	default:
+		return "", fmt.Errorf("unrecognized log policy %s", s)
+	}
+}
+
+// OnDuplicate controls what a new log at the same instant as a live log does.
+// It is checked before LogPolicy: a replaced duplicate never reaches the
+// period check, and a rejected one is refused as a duplicate rather than as a
+// second log in the period. Logs at other instants only see LogPolicy.
+type OnDuplicate string
+
+const (
+	OnDuplicateAllow   OnDuplicate = "allow"   // insert another log
+	OnDuplicateReject  OnDuplicate = "reject"  // refuse the new log
+	OnDuplicateReplace OnDuplicate = "replace" // update the existing log instead
+)
+
+func ToOnDuplicate(s string) (OnDuplicate, error) {
+	switch OnDuplicate(s) {
+	case OnDuplicateAllow, OnDuplicateReject, OnDuplicateReplace:
+		return OnDuplicate(s), nil
+	default:
+		return "", fmt.Errorf("unrecognized duplicate policy %s", s)
+	}
+}
+
+// LogSnap controls where a new log's occurred_at is placed within its period
+type LogSnap string
+
+const (
+	LogSnapNone  LogSnap = "none"  // keep the submitted time
+	LogSnapStart LogSnap = "start" // the first instant of the period
+	LogSnapMid   LogSnap = "mid"   // halfway through the period
+)
+
+func ToLogSnap(s string) (LogSnap, error) {
+	switch LogSnap(s) {
+	case LogSnapNone, LogSnapStart, LogSnapMid:
+		return LogSnap(s), nil
+	default:
+		return "", fmt.Errorf("unrecognized log snap %s", s)
+	}
+}
+
+// HabitDeleteMode controls what happens to a habit's logs when it is deleted
+type HabitDeleteMode string
+
+const (
+	HabitDeleteCascade HabitDeleteMode = "cascade" // delete the logs with the habit
+	HabitDeleteArchive HabitDeleteMode = "archive" // move the logs to the user's archive habit
+	HabitDeleteBlock   HabitDeleteMode = "block"   // refuse to delete a habit that has logs
+)
+
+// ArchiveHabitName names the inactive habit that archived logs are moved to
+const ArchiveHabitName = "Archived logs"
+
+func ToHabitDeleteMode(s string) (HabitDeleteMode, error) {
+	switch HabitDeleteMode(s) {
+	case HabitDeleteCascade, HabitDeleteArchive, HabitDeleteBlock:
+		return HabitDeleteMode(s), nil
+	default:
+		return "", fmt.Errorf("unrecognized habit delete mode %s", s)
+	}
+}
+
+// NumericLimit is the exclusive magnitude bound of NUMERIC(12,2) columns:
+// 12 digits with 2 after the point leaves 10 before it.
+var NumericLimit = decimal.New(1, 10)
+
+// CheckNumeric returns an error when d would overflow a NUMERIC(12,2) column
+// once Postgres rounds it to two decimal places
+func CheckNumeric(field string, d decimal.Decimal) error {
+	if d.Round(2).Abs().GreaterThanOrEqual(NumericLimit) {
+		return fmt.Errorf("%s must be less than %s in magnitude", field, NumericLimit.String())
+	}
+	return nil
+}
+
+// CheckQuantity returns an error when a log quantity is negative, which the
+// habit_log CHECK rejects, or would overflow its column
+func CheckQuantity(field string, d decimal.Decimal) error {
+	if d.IsNegative() {
+		return fmt.Errorf("%s must be >= 0", field)
+	}
+	return CheckNumeric(field, d)
+}
+
 const (
 	HumanDateFormat  = "Jan 1, 2006 at 3:04pm"
 	ToFrontEndFormat = "2006-01-02T15:04"
@@ -89,6 +193,7 @@ type UserSession struct {
 	UserID       int64     `db:"user_id"       json:"user_id"`
 	SessionToken string    `db:"session_token" json:"session_token"`
 	ExpiresAt    time.Time `db:"expires_at"    json:"expires_at"`
+	Remember     bool      `db:"remember"      json:"remember"` // false: short session, cookie without Expires
 	CreatedAt    time.Time `db:"created_at"    json:"created_at"`
 	UpdatedAt    time.Time `db:"updated_at"    json:"updated_at"`
 }
@@ -100,8 +205,8 @@ type Habit struct {
 	Name             string          `db:"name"                 json:"name"`
 	UnitLabel        sql.NullString  `db:"unit_label"           json:"unit_label,omitempty"`       // nullable
 	Agg              AggKind         `db:"agg"                  json:"agg"`                        // NOT NULL, default 'sum'
-	TargetPerPeriod  decimal.Decimal `db:"target_per_period"    json:"target_per_period"`          // NUMERIC(12,2)
-	PerLogDefaultQty decimal.Decimal `db:"per_log_default_qty"  json:"per_log_default_qty"`        // NUMERIC(12,2)
+	TargetPerPeriod  decimal.Decimal `db:"target_per_period"    json:"target_per_period"`          // NUMERIC(12,2); reads COALESCE legacy NULLs to 0
+	PerLogDefaultQty decimal.Decimal `db:"per_log_default_qty"  json:"per_log_default_qty"`        // NUMERIC(12,2); reads COALESCE legacy NULLs to 0
 	Period           PeriodType      `db:"period"               json:"period"`                     // NOT NULL, default 'daily'
 	WeekStartDOW     int32           `db:"week_start_dow"       json:"week_start_dow"`             // 0..6
 	MonthAnchorDay   int32           `db:"month_anchor_day"     json:"month_anchor_day"`           // 1..28
@@ -109,6 +214,10 @@ type Habit struct {
 	AnchorDate       time.Time       `db:"anchor_date"          json:"anchor_date"`                // DATE (use time.Date w/ midnight)
 	TZOverride       sql.NullString  `db:"tz"                   json:"tz_override,omitempty"`      // nullable override
 	IsActive         bool            `db:"is_active"            json:"is_active"`
+	LogPolicy        LogPolicy       `db:"log_policy"           json:"log_policy"`      // NOT NULL, default 'multiple'
+	OnDuplicate      OnDuplicate     `db:"on_duplicate"         json:"on_duplicate"`    // NOT NULL, default 'allow'
+	Color            sql.NullString  `db:"color"                json:"color,omitempty"` // nullable, #rgb or #rrggbb
+	Icon             sql.NullString  `db:"icon"                 json:"icon,omitempty"`  // nullable, display only
 	CreatedAt        time.Time       `db:"created_at"           json:"created_at"`
 }
 
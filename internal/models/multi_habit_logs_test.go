@@ -0,0 +1,60 @@
+package models_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/noahjalex/epoch/internal/models"
+	"github.com/noahjalex/epoch/internal/testdb"
+)
+
+func TestListLogsForHabitsWithinMatchesPerHabit(t *testing.T) {
+	repo := testdb.Repo(t)
+	ctx := context.Background()
+	user := testdb.NewUser(t, repo, "")
+	start := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 0, 3)
+
+	a := testdb.NewHabit(t, repo, user.ID, nil)
+	b := testdb.NewHabit(t, repo, user.ID, nil)
+	left := testdb.NewHabit(t, repo, user.ID, nil) // not asked for
+	for i, h := range []*models.Habit{a, b, a, b, left} {
+		testdb.NewLog(t, repo, h.ID, start.Add(time.Duration(10*i)*time.Hour), int64(i+1))
+	}
+	testdb.NewLog(t, repo, a.ID, start.Add(-time.Minute), 9) // before the window
+	testdb.NewLog(t, repo, b.ID, end, 9)                     // end is exclusive
+	deleted := testdb.NewLog(t, repo, b.ID, start.Add(time.Hour), 9)
+	_, err := repo.SoftDeleteLog(ctx, deleted.ID)
+	check(t, err)
+
+	got, err := repo.ListLogsForHabitsWithin(ctx, []int64{a.ID, b.ID}, start, end)
+	check(t, err)
+
+	// The same logs as one query per habit, merged oldest first
+	want := map[int64]bool{}
+	for _, id := range []int64{a.ID, b.ID} {
+		logs, err := repo.ListLogsWithin(ctx, id, start, end)
+		check(t, err)
+		for _, l := range logs {
+			want[l.ID] = true
+		}
+	}
+	if len(got) != len(want) || len(got) != 4 {
+		t.Fatalf("got %d logs, per-habit queries give %d, want 4", len(got), len(want))
+	}
+	for i, l := range got {
+		if !want[l.ID] {
+			t.Errorf("log %d is not in the per-habit results", l.ID)
+		}
+		if i > 0 && l.OccurredAt.Before(got[i-1].OccurredAt) {
+			t.Errorf("log %d is out of order", l.ID)
+		}
+	}
+
+	none, err := repo.ListLogsForHabitsWithin(ctx, nil, start, end)
+	check(t, err)
+	if len(none) != 0 {
+		t.Errorf("no habits gave %d logs", len(none))
+	}
+}
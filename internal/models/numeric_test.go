@@ -0,0 +1,48 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestCheckNumeric(t *testing.T) {
+	tests := []struct {
+		in string
+		ok bool
+	}{
+		{"0", true},
+		{"9999999999.99", true},
+		{"-9999999999.99", true},
+		{"9999999999.994", true},  // rounds down to fit
+		{"9999999999.995", false}, // rounds up to 10^10
+		{"10000000000", false},
+		{"-10000000000", false},
+		{"1e20", false},
+	}
+	for _, tt := range tests {
+		d := decimal.RequireFromString(tt.in)
+		if err := CheckNumeric("goal", d); (err == nil) != tt.ok {
+			t.Errorf("CheckNumeric(%s) = %v, want ok %v", tt.in, err, tt.ok)
+		}
+	}
+}
+
+func TestCheckQuantity(t *testing.T) {
+	tests := []struct {
+		in string
+		ok bool
+	}{
+		{"0", true},
+		{"0.01", true},
+		{"9999999999.99", true},
+		{"-0.01", false},
+		{"-1", false},
+		{"10000000000", false},
+	}
+	for _, tt := range tests {
+		if err := CheckQuantity("qty", decimal.RequireFromString(tt.in)); (err == nil) != tt.ok {
+			t.Errorf("CheckQuantity(%s) = %v, want ok %v", tt.in, err, tt.ok)
+		}
+	}
+}
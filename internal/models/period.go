@@ -0,0 +1,104 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/noahjalex/epoch/internal/tzcache"
+)
+
+// ValidateSchedule checks the habit's scheduling fields against the ranges the
+// habit table enforces, plus that rolling habits have a length and that any
+// timezone override is a known zone
+func (h *Habit) ValidateSchedule() error {
+	if _, err := ToPeriodType(string(h.Period)); err != nil {
+		return err
+	}
+	if h.WeekStartDOW < 0 || h.WeekStartDOW > 6 {
+		return fmt.Errorf("weekStartDow must be between 0 and 6")
+	}
+	if h.MonthAnchorDay < 1 || h.MonthAnchorDay > 28 {
+		return fmt.Errorf("monthAnchorDay must be between 1 and 28")
+	}
+	if h.RollingLenDays.Valid && h.RollingLenDays.Int32 < 1 {
+		return fmt.Errorf("rollingLenDays must be at least 1")
+	}
+	if h.Period == PeriodRolling && !h.RollingLenDays.Valid {
+		return fmt.Errorf("rollingLenDays is required for rolling habits")
+	}
+	if h.TZOverride.Valid {
+		if _, err := tzcache.Load(h.TZOverride.String); err != nil {
+			return fmt.Errorf("unknown timezone %s", h.TZOverride.String)
+		}
+	}
+	return nil
+}
+
+// Location returns the habit's bucketing timezone: its override if set,
+// otherwise the owner's timezone. Falls back to UTC on unknown names.
+func (h *Habit) Location(userTZ string) *time.Location {
+	name := userTZ
+	if h.TZOverride.Valid && h.TZOverride.String != "" {
+		name = h.TZOverride.String
+	}
+	loc, err := tzcache.Load(name)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// PeriodBounds returns the [start, end) window of the habit period containing t,
+// evaluated in loc. Bucketing mirrors RollupBuckets: weeks start on
+// WeekStartDOW, months on the 1st, and rolling windows count from AnchorDate.
+func (h *Habit) PeriodBounds(t time.Time, loc *time.Location) (time.Time, time.Time) {
+	lt := t.In(loc)
+	day := time.Date(lt.Year(), lt.Month(), lt.Day(), 0, 0, 0, 0, loc)
+
+	switch h.Period {
+	case PeriodWeekly:
+		return WeekBounds(t, loc, h.WeekStartDOW)
+	case PeriodMonthly:
+		start := time.Date(lt.Year(), lt.Month(), 1, 0, 0, 0, 0, loc)
+		return start, start.AddDate(0, 1, 0)
+	case PeriodRolling:
+		n := 1
+		if h.RollingLenDays.Valid && h.RollingLenDays.Int32 > 0 {
+			n = int(h.RollingLenDays.Int32)
+		}
+		// Count whole calendar days in UTC so DST shifts don't skew the offset
+		anchor := time.Date(h.AnchorDate.Year(), h.AnchorDate.Month(), h.AnchorDate.Day(), 0, 0, 0, 0, time.UTC)
+		today := time.Date(lt.Year(), lt.Month(), lt.Day(), 0, 0, 0, 0, time.UTC)
+		days := int(today.Sub(anchor).Hours() / 24)
+		offset := ((days % n) + n) % n
+		start := day.AddDate(0, 0, -offset)
+		return start, start.AddDate(0, 0, n)
+	default:
+		return day, day.AddDate(0, 0, 1)
+	}
+}
+
+// SnapToPeriod moves t to the representative time of the habit period that
+// contains it, evaluated in loc. Snapping to the start or midpoint of the
+// window keeps retroactive logs clear of period edges.
+func (h *Habit) SnapToPeriod(t time.Time, loc *time.Location, snap LogSnap) time.Time {
+	start, end := h.PeriodBounds(t, loc)
+	switch snap {
+	case LogSnapStart:
+		return start
+	case LogSnapMid:
+		return start.Add(end.Sub(start) / 2)
+	default:
+		return t
+	}
+}
+
+// WeekBounds returns the [start, end) week containing t in loc, with weeks
+// starting on weekStartDOW (0 = Sunday .. 6 = Saturday)
+func WeekBounds(t time.Time, loc *time.Location, weekStartDOW int32) (time.Time, time.Time) {
+	lt := t.In(loc)
+	day := time.Date(lt.Year(), lt.Month(), lt.Day(), 0, 0, 0, 0, loc)
+	offset := (int(lt.Weekday()) - int(weekStartDOW) + 7) % 7
+	start := day.AddDate(0, 0, -offset)
+	return start, start.AddDate(0, 0, 7)
+}
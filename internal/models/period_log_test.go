@@ -0,0 +1,73 @@
+package models_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/noahjalex/epoch/internal/models"
+	"github.com/noahjalex/epoch/internal/testdb"
+	"github.com/shopspring/decimal"
+)
+
+func TestUpsertLogForBucket(t *testing.T) {
+	repo := testdb.Repo(t)
+	ctx := context.Background()
+	user := testdb.NewUser(t, repo, "")
+	h := testdb.NewHabit(t, repo, user.ID, nil)
+	day := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+
+	l, created, value, err := repo.UpsertLogForBucket(ctx, h.ID, day.Add(9*time.Hour), decimal.NewFromInt(2), sql.NullString{String: "first", Valid: true})
+	check(t, err)
+	if !created || !value.Equal(decimal.NewFromInt(2)) {
+		t.Fatalf("first upsert: created %v value %s, want true 2", created, value)
+	}
+
+	// A later upsert in the same day updates that log and keeps its time
+	l2, created, value, err := repo.UpsertLogForBucket(ctx, h.ID, day.Add(20*time.Hour), decimal.NewFromInt(5), sql.NullString{})
+	check(t, err)
+	if created || l2.ID != l.ID || !l2.OccurredAt.Equal(l.OccurredAt) {
+		t.Errorf("second upsert: created %v log %d at %v, want update of %d", created, l2.ID, l2.OccurredAt, l.ID)
+	}
+	if !value.Equal(decimal.NewFromInt(5)) || l2.Note.String != "first" {
+		t.Errorf("second upsert: value %s note %q, want 5 and the kept note", value, l2.Note.String)
+	}
+
+	// The next day is a new period
+	_, created, _, err = repo.UpsertLogForBucket(ctx, h.ID, day.AddDate(0, 0, 1), decimal.NewFromInt(1), sql.NullString{})
+	check(t, err)
+	if !created {
+		t.Error("next day's upsert updated an earlier period's log")
+	}
+}
+
+func TestIncrementLog(t *testing.T) {
+	repo := testdb.Repo(t)
+	ctx := context.Background()
+	user := testdb.NewUser(t, repo, "")
+	h := testdb.NewHabit(t, repo, user.ID, nil)
+	at := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	if _, _, _, err := repo.IncrementLog(ctx, h, "UTC", decimal.NewFromInt(-1), at); !errors.Is(err, models.ErrNothingToDecrement) {
+		t.Fatalf("decrement of an empty period: err = %v, want ErrNothingToDecrement", err)
+	}
+
+	steps := []struct {
+		delta   int64
+		created bool
+		value   int64
+	}{
+		{3, true, 3},
+		{2, false, 5},
+		{-10, false, 0}, // clamped at zero
+	}
+	for i, s := range steps {
+		_, created, value, err := repo.IncrementLog(ctx, h, "UTC", decimal.NewFromInt(s.delta), at)
+		check(t, err)
+		if created != s.created || !value.Equal(decimal.NewFromInt(s.value)) {
+			t.Errorf("step %d: created %v value %s, want %v %d", i, created, value, s.created, s.value)
+		}
+	}
+}
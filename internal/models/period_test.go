@@ -0,0 +1,83 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWeekBoundsFollowWeekStart(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	// Wednesday evening in New York is already Thursday in UTC
+	at := time.Date(2024, 3, 14, 2, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		dow     int32
+		start   string
+		lastDay string
+	}{
+		{"sunday start", 0, "2024-03-10", "2024-03-16"},
+		{"monday start", 1, "2024-03-11", "2024-03-17"},
+		{"wednesday start", 3, "2024-03-13", "2024-03-19"},
+	}
+	for _, tt := range tests {
+		start, end := WeekBounds(at, ny, tt.dow)
+		if got := start.Format(time.DateOnly); got != tt.start {
+			t.Errorf("%s: week starts %s, want %s", tt.name, got, tt.start)
+		}
+		if got := end.AddDate(0, 0, -1).Format(time.DateOnly); got != tt.lastDay {
+			t.Errorf("%s: week ends %s, want %s", tt.name, got, tt.lastDay)
+		}
+		if start.Hour() != 0 || end.Hour() != 0 {
+			t.Errorf("%s: [%v, %v) is not local midnight to midnight", tt.name, start, end)
+		}
+
+		// A weekly habit's period is the same week
+		h := &Habit{Period: PeriodWeekly, WeekStartDOW: tt.dow}
+		if s, e := h.PeriodBounds(at, ny); !s.Equal(start) || !e.Equal(end) {
+			t.Errorf("%s: PeriodBounds = [%v, %v), want [%v, %v)", tt.name, s, e, start, end)
+		}
+	}
+}
+
+func TestSnapToPeriod(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	// The last evening of March in New York, already April in UTC
+	at := time.Date(2024, 4, 1, 1, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		period PeriodType
+		snap   LogSnap
+		want   string
+	}{
+		{PeriodDaily, LogSnapStart, "2024-03-31 00:00"},
+		{PeriodDaily, LogSnapMid, "2024-03-31 12:00"},
+		{PeriodWeekly, LogSnapStart, "2024-03-25 00:00"},
+		{PeriodWeekly, LogSnapMid, "2024-03-28 12:00"},
+		{PeriodMonthly, LogSnapStart, "2024-03-01 00:00"},
+		{PeriodMonthly, LogSnapMid, "2024-03-16 12:30"}, // clocks jump forward an hour on Mar 10
+		{PeriodMonthly, LogSnapNone, "2024-03-31 21:00"},
+	}
+	for _, tt := range tests {
+		h := &Habit{Period: tt.period, WeekStartDOW: 1}
+		got := h.SnapToPeriod(at, ny, tt.snap)
+		if s := got.In(ny).Format("2006-01-02 15:04"); s != tt.want {
+			t.Errorf("%s/%s: snapped to %s, want %s", tt.period, tt.snap, s, tt.want)
+		}
+		// The snapped time stays in the submitted time's bucket
+		wantStart, _ := h.PeriodBounds(at, ny)
+		if start, _ := h.PeriodBounds(got, ny); !start.Equal(wantStart) {
+			t.Errorf("%s/%s: snapped into the period from %v, want %v", tt.period, tt.snap, start, wantStart)
+		}
+	}
+
+	if _, err := ToLogSnap("end"); err == nil {
+		t.Error("ToLogSnap(end) succeeded")
+	}
+}
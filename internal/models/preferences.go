@@ -0,0 +1,79 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Preference keys stored in app_user.preferences. Keys use the API's camelCase.
+const (
+	PrefDateFormat   = "dateFormat"   // string, Go time layout for display
+	PrefLocale       = "locale"       // string, BCP 47 tag such as "en-CA"
+	PrefDefaultAgg   = "defaultAgg"   // string, AggKind for new habits
+	PrefWeekStartDOW = "weekStartDow" // number, 0 (Sunday) .. 6
+)
+
+// DefaultWeekStartDOW is the week start (Monday) used without a preference
+const DefaultWeekStartDOW int32 = 1
+
+// WeekStartDOW returns the user's preferred first weekday, or the default
+func (p JSONB) WeekStartDOW() int32 {
+	if f, ok := p[PrefWeekStartDOW].(float64); ok && f >= 0 && f <= 6 {
+		return int32(f)
+	}
+	return DefaultWeekStartDOW
+}
+
+// preferenceValidators checks the value of each known preference key.
+// A nil value is always allowed and clears the key.
+var preferenceValidators = map[string]func(any) error{
+	PrefDateFormat: nonEmptyString,
+	PrefLocale:     nonEmptyString,
+	PrefDefaultAgg: func(v any) error {
+		s, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("must be a string")
+		}
+		_, err := ToAggKind(s)
+		return err
+	},
+	PrefWeekStartDOW: func(v any) error {
+		f, ok := v.(float64)
+		if !ok || f != float64(int(f)) || f < 0 || f > 6 {
+			return fmt.Errorf("must be an integer from 0 to 6")
+		}
+		return nil
+	},
+}
+
+func nonEmptyString(v any) error {
+	s, ok := v.(string)
+	if !ok || strings.TrimSpace(s) == "" {
+		return fmt.Errorf("must be a non-empty string")
+	}
+	return nil
+}
+
+// ValidatePreferences rejects unknown keys and ill-typed values
+func ValidatePreferences(p JSONB) error {
+	keys := make([]string, 0, len(p))
+	for k := range p {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		validate, ok := preferenceValidators[k]
+		if !ok {
+			return fmt.Errorf("unknown preference %q", k)
+		}
+		if p[k] == nil {
+			continue
+		}
+		if err := validate(p[k]); err != nil {
+			return fmt.Errorf("preference %q %v", k, err)
+		}
+	}
+	return nil
+}
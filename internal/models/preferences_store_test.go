@@ -0,0 +1,46 @@
+package models_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/noahjalex/epoch/internal/models"
+	"github.com/noahjalex/epoch/internal/testdb"
+)
+
+func TestUpdatePreferences(t *testing.T) {
+	repo := testdb.Repo(t)
+	ctx := context.Background()
+	user := testdb.NewUser(t, repo, "")
+	other := testdb.NewUser(t, repo, "")
+
+	p, err := repo.GetPreferences(ctx, user.ID)
+	check(t, err)
+	if len(p) != 0 {
+		t.Fatalf("new user has preferences %v", p)
+	}
+
+	_, err = repo.UpdatePreferences(ctx, user.ID, models.JSONB{models.PrefLocale: "en-CA", models.PrefDefaultAgg: "count"})
+	check(t, err)
+
+	// Later changes merge into the stored keys, and nil removes one
+	p, err = repo.UpdatePreferences(ctx, user.ID, models.JSONB{models.PrefDefaultAgg: nil, models.PrefWeekStartDOW: float64(0)})
+	check(t, err)
+	want := models.JSONB{models.PrefLocale: "en-CA", models.PrefWeekStartDOW: float64(0)}
+	if !reflect.DeepEqual(p, want) {
+		t.Errorf("UpdatePreferences = %v, want %v", p, want)
+	}
+	got, err := repo.GetPreferences(ctx, user.ID)
+	check(t, err)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetPreferences = %v, want %v", got, want)
+	}
+
+	// Other users are untouched
+	p, err = repo.GetPreferences(ctx, other.ID)
+	check(t, err)
+	if len(p) != 0 {
+		t.Errorf("other user's preferences = %v, want none", p)
+	}
+}
@@ -0,0 +1,65 @@
+package models
+
+import "testing"
+
+func TestWeekStartDOW(t *testing.T) {
+	tests := []struct {
+		name  string
+		prefs JSONB
+		want  int32
+	}{
+		{"unset", JSONB{}, DefaultWeekStartDOW},
+		{"sunday", JSONB{PrefWeekStartDOW: float64(0)}, 0},
+		{"saturday", JSONB{PrefWeekStartDOW: float64(6)}, 6},
+		{"out of range", JSONB{PrefWeekStartDOW: float64(7)}, DefaultWeekStartDOW},
+		{"wrong type", JSONB{PrefWeekStartDOW: "0"}, DefaultWeekStartDOW},
+		{"locale does not pick the week start", JSONB{PrefLocale: "en-US"}, DefaultWeekStartDOW},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.prefs.WeekStartDOW(); got != tt.want {
+				t.Errorf("WeekStartDOW() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidatePreferencesWeekStart(t *testing.T) {
+	for _, v := range []any{float64(0), float64(6), nil} {
+		if err := ValidatePreferences(JSONB{PrefWeekStartDOW: v}); err != nil {
+			t.Errorf("%v: unexpected error %v", v, err)
+		}
+	}
+	for _, v := range []any{float64(-1), float64(7), float64(1.5), "1"} {
+		if err := ValidatePreferences(JSONB{PrefWeekStartDOW: v}); err == nil {
+			t.Errorf("%v: expected an error", v)
+		}
+	}
+}
+
+func TestValidatePreferences(t *testing.T) {
+	valid := []JSONB{
+		{},
+		{PrefDateFormat: "2006-01-02", PrefLocale: "en-CA", PrefDefaultAgg: "count"},
+		{PrefLocale: nil, PrefDefaultAgg: nil},
+	}
+	for _, p := range valid {
+		if err := ValidatePreferences(p); err != nil {
+			t.Errorf("%v: unexpected error %v", p, err)
+		}
+	}
+
+	invalid := []JSONB{
+		{"theme": "dark"},
+		{PrefLocale: ""},
+		{PrefLocale: float64(1)},
+		{PrefDateFormat: "   "},
+		{PrefDefaultAgg: "median"},
+		{PrefDefaultAgg: true},
+	}
+	for _, p := range invalid {
+		if err := ValidatePreferences(p); err == nil {
+			t.Errorf("%v: expected an error", p)
+		}
+	}
+}
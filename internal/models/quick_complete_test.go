@@ -0,0 +1,63 @@
+package models_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/noahjalex/epoch/internal/models"
+	"github.com/noahjalex/epoch/internal/testdb"
+	"github.com/shopspring/decimal"
+)
+
+func TestQuickComplete(t *testing.T) {
+	repo := testdb.Repo(t)
+	ctx := context.Background()
+	user := testdb.NewUser(t, repo, "")
+	a := testdb.NewHabit(t, repo, user.ID, func(h *models.Habit) {
+		h.Agg = models.AggBoolean
+		h.PerLogDefaultQty = decimal.NewFromInt(2)
+	})
+	b := testdb.NewHabit(t, repo, user.ID, func(h *models.Habit) { h.Agg = models.AggBoolean })
+	at := time.Date(2024, 3, 10, 18, 0, 0, 0, time.UTC)
+	done := testdb.NewLog(t, repo, b.ID, at.Add(-8*time.Hour), 1)
+
+	// b is already done today and a repeated id is only logged once
+	logs, err := repo.QuickComplete(ctx, user.ID, user.TZ, []int64{a.ID, b.ID, a.ID}, at)
+	check(t, err)
+	if len(logs) != 1 || logs[0].HabitID != a.ID || !logs[0].Quantity.Equal(decimal.NewFromInt(2)) {
+		t.Fatalf("created %+v, want one log of 2 for habit %d", logs, a.ID)
+	}
+	bLogs, err := repo.ListLogs(ctx, b.ID)
+	check(t, err)
+	if len(bLogs) != 1 || bLogs[0].ID != done.ID {
+		t.Errorf("habit already done today got %d logs, want only %d", len(bLogs), done.ID)
+	}
+
+	// Running it again the same day creates nothing
+	logs, err = repo.QuickComplete(ctx, user.ID, user.TZ, []int64{a.ID, b.ID}, at.Add(time.Hour))
+	check(t, err)
+	if len(logs) != 0 {
+		t.Errorf("second run created %d logs, want 0", len(logs))
+	}
+}
+
+func TestQuickCompleteRejectsOtherUsersHabits(t *testing.T) {
+	repo := testdb.Repo(t)
+	ctx := context.Background()
+	user := testdb.NewUser(t, repo, "")
+	other := testdb.NewUser(t, repo, "")
+	own := testdb.NewHabit(t, repo, user.ID, nil)
+	theirs := testdb.NewHabit(t, repo, other.ID, nil)
+
+	_, err := repo.QuickComplete(ctx, user.ID, user.TZ, []int64{own.ID, theirs.ID}, time.Now())
+	if !errors.Is(err, models.ErrHabitNotOwned) {
+		t.Fatalf("err = %v, want ErrHabitNotOwned", err)
+	}
+	logs, err := repo.ListLogs(ctx, own.ID)
+	check(t, err)
+	if len(logs) != 0 {
+		t.Errorf("own habit got %d logs from a rejected batch, want 0", len(logs))
+	}
+}
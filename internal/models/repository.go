@@ -3,11 +3,17 @@ package models
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/jmoiron/sqlx"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
+	"github.com/noahjalex/epoch/internal/auth"
 	"github.com/shopspring/decimal"
 
 	"github.com/sirupsen/logrus"
@@ -15,16 +21,75 @@ import (
 
 var log = logrus.New()
 
+var (
+	// ErrHabitNotOwned is returned when a habit does not exist or belongs to another user
+	ErrHabitNotOwned = errors.New("habit not found for user")
+	// ErrHabitHasLogs is returned when deleting a habit that still has logs is blocked
+	ErrHabitHasLogs = errors.New("habit has logs")
+	// ErrPeriodAlreadyLogged is returned when a single-log habit already has a log in the period
+	ErrPeriodAlreadyLogged = errors.New("habit already logged for this period")
+	// ErrDuplicateLog is returned when a reject-duplicates habit already has a live log at the same instant
+	ErrDuplicateLog = errors.New("habit already has a log at this time")
+	// ErrUserExists is returned when a new user's username or email is already registered
+	ErrUserExists = errors.New("username or email already registered")
+	// ErrNothingToDecrement is returned when a negative increment finds no log in the period
+	ErrNothingToDecrement = errors.New("no log in this period to decrement")
+)
+
 type Repo struct {
-	db *sqlx.DB
+	db       *sqlx.DB
+	metGrace decimal.Decimal
+	sessions *sessionCache // nil when session caching is disabled
 }
 
 func NewRepository(db *sqlx.DB) *Repo {
-	return &Repo{db: db}
+	return &Repo{db: db, metGrace: DefaultMetGrace}
+}
+
+// SetMetGrace sets how far below target a quantity aggregate may fall and
+// still count as met in streak and due checks. Negative values are ignored.
+func (r *Repo) SetMetGrace(grace decimal.Decimal) {
+	if grace.IsNegative() {
+		return
+	}
+	r.metGrace = grace
+}
+
+// EnableSessionCache caches GetSessionWithUser results in memory for ttl,
+// holding at most size sessions. Session changes made through the Repo
+// invalidate affected entries. A zero ttl or size leaves caching off.
+func (r *Repo) EnableSessionCache(ttl time.Duration, size int) {
+	if ttl <= 0 || size <= 0 {
+		r.sessions = nil
+		return
+	}
+	r.sessions = newSessionCache(ttl, size)
+}
+
+// forgetSession drops a token from the session cache, if enabled
+func (r *Repo) forgetSession(token string) {
+	if r.sessions != nil {
+		r.sessions.deleteToken(token)
+	}
+}
+
+// forgetUserSessions drops a user's cached sessions except keepToken, if enabled
+func (r *Repo) forgetUserSessions(userID int64, keepToken string) {
+	if r.sessions != nil {
+		r.sessions.deleteUser(userID, keepToken)
+	}
+}
+
+// Ping checks that the database is reachable
+func (r *Repo) Ping(ctx context.Context) error {
+	return r.db.PingContext(ctx)
 }
 
 // -------------------- USERS --------------------
 
+// CreateUser inserts a user. Uniqueness of username and email is left to the
+// table's unique indexes; a clash returns an error wrapping ErrUserExists
+// that names the violated constraint.
 func (r *Repo) CreateUser(ctx context.Context, username, email, passwordHash, tz string) (*AppUser, error) {
 	var u AppUser
 	err := r.db.GetContext(ctx, &u, `
@@ -32,7 +97,14 @@ func (r *Repo) CreateUser(ctx context.Context, username, email, passwordHash, tz
 		VALUES ($1, $2, $3, COALESCE(NULLIF($4,''), 'America/Toronto'))
 		RETURNING id, username, email, password_hash, tz, created_at
 	`, username, email, passwordHash, tz)
-	return &u, err
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" { // unique_violation
+		return nil, fmt.Errorf("%w: %s", ErrUserExists, pqErr.Constraint)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
 }
 
 func (r *Repo) GetUserByUsername(ctx context.Context, username string) (*AppUser, error) {
@@ -74,22 +146,73 @@ func (r *Repo) GetUser(ctx context.Context, userID int64) (*AppUser, error) {
 	return &u, nil
 }
 
+// UpdatePasswordHash replaces the user's password hash
+func (r *Repo) UpdatePasswordHash(ctx context.Context, userID int64, passwordHash string) error {
+	defer r.forgetUserSessions(userID, "")
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE app_user SET password_hash = $2 WHERE id = $1
+	`, userID, passwordHash)
+	return err
+}
+
+// UpdateUserTimezone sets the user's timezone. Cached sessions carry a copy of
+// the user, so they are dropped and the next request reloads it.
+func (r *Repo) UpdateUserTimezone(ctx context.Context, userID int64, tz string) error {
+	defer r.forgetUserSessions(userID, "")
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE app_user SET tz = $2 WHERE id = $1
+	`, userID, tz)
+	return err
+}
+
+// GetPreferences returns the user's stored preferences
+func (r *Repo) GetPreferences(ctx context.Context, userID int64) (JSONB, error) {
+	var p JSONB
+	err := r.db.GetContext(ctx, &p, `SELECT preferences FROM app_user WHERE id = $1`, userID)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// UpdatePreferences merges changes into the user's preferences and returns the
+// result. Keys set to nil are removed. Callers validate with ValidatePreferences.
+func (r *Repo) UpdatePreferences(ctx context.Context, userID int64, changes JSONB) (JSONB, error) {
+	b, err := json.Marshal(changes)
+	if err != nil {
+		return nil, err
+	}
+	var p JSONB
+	err = r.db.GetContext(ctx, &p, `
+		UPDATE app_user
+		SET preferences = jsonb_strip_nulls(preferences || $2::jsonb)
+		WHERE id = $1
+		RETURNING preferences
+	`, userID, string(b))
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
 // -------------------- SESSIONS --------------------
 
-func (r *Repo) CreateSession(ctx context.Context, userID int64, sessionToken string, expiresAt time.Time) (*UserSession, error) {
+// CreateSession stores a new session; remember records whether it is a
+// long-lived "remember me" session so refreshes keep its kind
+func (r *Repo) CreateSession(ctx context.Context, userID int64, sessionToken string, expiresAt time.Time, remember bool) (*UserSession, error) {
 	var s UserSession
 	err := r.db.GetContext(ctx, &s, `
-		INSERT INTO user_sessions (user_id, session_token, expires_at)
-		VALUES ($1, $2, $3)
-		RETURNING id, user_id, session_token, expires_at, created_at, updated_at
-	`, userID, sessionToken, expiresAt)
+		INSERT INTO user_sessions (user_id, session_token, expires_at, remember)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, user_id, session_token, expires_at, remember, created_at, updated_at
+	`, userID, sessionToken, expiresAt, remember)
 	return &s, err
 }
 
 func (r *Repo) GetSessionByToken(ctx context.Context, sessionToken string) (*UserSession, error) {
 	var s UserSession
 	err := r.db.GetContext(ctx, &s, `
-		SELECT id, user_id, session_token, expires_at, created_at, updated_at
+		SELECT id, user_id, session_token, expires_at, remember, created_at, updated_at
 		FROM user_sessions
 		WHERE session_token = $1
 	`, sessionToken)
@@ -99,21 +222,144 @@ func (r *Repo) GetSessionByToken(ctx context.Context, sessionToken string) (*Use
 	return &s, nil
 }
 
+// GetSessionWithUser returns the session and its user in one query.
+// A missing session or user is reported as sql.ErrNoRows.
+func (r *Repo) GetSessionWithUser(ctx context.Context, sessionToken string) (*UserSession, *AppUser, error) {
+	if r.sessions != nil {
+		if s, u, ok := r.sessions.get(sessionToken); ok {
+			return s, u, nil
+		}
+	}
+
+	var row struct {
+		Session UserSession `db:"s"`
+		User    AppUser     `db:"u"`
+	}
+	err := r.db.GetContext(ctx, &row, `
+		SELECT s.id AS "s.id", s.user_id AS "s.user_id", s.session_token AS "s.session_token",
+		       s.expires_at AS "s.expires_at", s.remember AS "s.remember",
+		       s.created_at AS "s.created_at", s.updated_at AS "s.updated_at",
+		       u.id AS "u.id", u.username AS "u.username", u.email AS "u.email",
+		       u.password_hash AS "u.password_hash", u.tz AS "u.tz", u.created_at AS "u.created_at"
+		FROM user_sessions s
+		JOIN app_user u ON u.id = s.user_id
+		WHERE s.session_token = $1
+	`, sessionToken)
+	if err != nil {
+		return nil, nil, err
+	}
+	if r.sessions != nil {
+		r.sessions.put(&row.Session, &row.User)
+	}
+	return &row.Session, &row.User, nil
+}
+
 func (r *Repo) DeleteSession(ctx context.Context, sessionToken string) error {
+	defer r.forgetSession(sessionToken)
 	_, err := r.db.ExecContext(ctx, `
 		DELETE FROM user_sessions WHERE session_token = $1
 	`, sessionToken)
 	return err
 }
 
-func (r *Repo) DeleteExpiredSessions(ctx context.Context) error {
+// RotateSession replaces a session's token with a freshly generated one,
+// keeping its user and expiry. The old token stops working immediately.
+func (r *Repo) RotateSession(ctx context.Context, oldToken string) (string, error) {
+	newToken, err := auth.GenerateSessionToken()
+	if err != nil {
+		return "", err
+	}
+	defer r.forgetSession(oldToken)
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE user_sessions
+		SET session_token = $2, updated_at = NOW()
+		WHERE session_token = $1
+	`, oldToken, newToken)
+	if err != nil {
+		return "", err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return "", err
+	} else if n == 0 {
+		return "", sql.ErrNoRows
+	}
+	return newToken, nil
+}
+
+// TouchSession moves a session's expiry to newExpiry. Only sessions expiring
+// earlier are updated, so concurrent refreshes of the same session write once.
+func (r *Repo) TouchSession(ctx context.Context, sessionToken string, newExpiry time.Time) error {
+	defer r.forgetSession(sessionToken)
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE user_sessions
+		SET expires_at = $2, updated_at = NOW()
+		WHERE session_token = $1 AND expires_at < $2
+	`, sessionToken, newExpiry)
+	return err
+}
+
+// DeleteOtherUserSessions deletes every session of the user except keepToken
+func (r *Repo) DeleteOtherUserSessions(ctx context.Context, userID int64, keepToken string) error {
+	defer r.forgetUserSessions(userID, keepToken)
 	_, err := r.db.ExecContext(ctx, `
+		DELETE FROM user_sessions WHERE user_id = $1 AND session_token <> $2
+	`, userID, keepToken)
+	return err
+}
+
+// DeleteExpiredSessions deletes every expired session and returns how many
+func (r *Repo) DeleteExpiredSessions(ctx context.Context) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `
 		DELETE FROM user_sessions WHERE expires_at < NOW()
 	`)
-	return err
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// CountActiveSessions counts sessions that have not yet expired
+func (r *Repo) CountActiveSessions(ctx context.Context) (int, error) {
+	var n int
+	err := r.db.GetContext(ctx, &n, `
+		SELECT COUNT(*) FROM user_sessions WHERE expires_at > NOW()
+	`)
+	return n, err
+}
+
+// CountUsers counts all registered users
+func (r *Repo) CountUsers(ctx context.Context) (int, error) {
+	var n int
+	err := r.db.GetContext(ctx, &n, `SELECT COUNT(*) FROM app_user`)
+	return n, err
+}
+
+// CountHabits counts active habits, or every habit including archived ones
+// when includeArchived is set
+func (r *Repo) CountHabits(ctx context.Context, includeArchived bool) (int, error) {
+	q := `SELECT COUNT(*) FROM habit`
+	if !includeArchived {
+		q += ` WHERE is_active = TRUE`
+	}
+	var n int
+	err := r.db.GetContext(ctx, &n, q)
+	return n, err
+}
+
+// CountLogs counts live logs, or every log including soft-deleted ones when
+// includeDeleted is set
+func (r *Repo) CountLogs(ctx context.Context, includeDeleted bool) (int, error) {
+	q := `SELECT COUNT(*) FROM habit_log`
+	if !includeDeleted {
+		q += ` WHERE deleted_at IS NULL`
+	}
+	var n int
+	err := r.db.GetContext(ctx, &n, q)
+	return n, err
 }
 
 func (r *Repo) DeleteUserSessions(ctx context.Context, userID int64) error {
+	defer r.forgetUserSessions(userID, "")
 	_, err := r.db.ExecContext(ctx, `
 		DELETE FROM user_sessions WHERE user_id = $1
 	`, userID)
@@ -122,18 +368,22 @@ func (r *Repo) DeleteUserSessions(ctx context.Context, userID int64) error {
 
 // -------------------- HABITS --------------------
 
+// habitColumns is the column list every habit read scans into a Habit
+const habitColumns = `id, user_id, name, unit_label, agg,
+	COALESCE(target_per_period, 0) AS target_per_period, COALESCE(per_log_default_qty, 0) AS per_log_default_qty,
+	period, week_start_dow, month_anchor_day, rolling_len_days, anchor_date, tz, is_active, log_policy, on_duplicate, color, icon, created_at`
+
 func (r *Repo) CreateHabit(ctx context.Context, h *Habit) (*Habit, error) {
 	// Let DB defaults apply when zero-values are passed (e.g., agg, period)
 	query := `
 		INSERT INTO habit (
 			user_id, name, unit_label, agg, target_per_period, per_log_default_qty,
-			period, week_start_dow, month_anchor_day, rolling_len_days, anchor_date, tz, is_active
+			period, week_start_dow, month_anchor_day, rolling_len_days, anchor_date, tz, is_active, log_policy, on_duplicate, color, icon
 		) VALUES (
 			:user_id, :name, :unit_label, :agg, :target_per_period, :per_log_default_qty,
-			:period, :week_start_dow, :month_anchor_day, :rolling_len_days, :anchor_date, :tz, :is_active
+			:period, :week_start_dow, :month_anchor_day, :rolling_len_days, :anchor_date, :tz, :is_active, :log_policy, :on_duplicate, :color, :icon
 		)
-		RETURNING id, user_id, name, unit_label, agg, target_per_period, per_log_default_qty,
-		          period, week_start_dow, month_anchor_day, rolling_len_days, anchor_date, tz, is_active, created_at
+		RETURNING ` + habitColumns + `
 	`
 	// sqlx.NamedExec/Query requires named params; we can pass the struct directly.
 	rows, err := r.db.NamedQueryContext(ctx, query, h)
@@ -154,8 +404,7 @@ func (r *Repo) CreateHabit(ctx context.Context, h *Habit) (*Habit, error) {
 func (r *Repo) GetHabit(ctx context.Context, habitID int64) (*Habit, error) {
 	var h Habit
 	err := r.db.GetContext(ctx, &h, `
-		SELECT id, user_id, name, unit_label, agg, target_per_period, per_log_default_qty,
-		       period, week_start_dow, month_anchor_day, rolling_len_days, anchor_date, tz, is_active, created_at
+		SELECT `+habitColumns+`
 		FROM habit
 		WHERE id = $1
 	`, habitID)
@@ -167,8 +416,7 @@ func (r *Repo) GetHabit(ctx context.Context, habitID int64) (*Habit, error) {
 
 func (r *Repo) ListHabitsByUser(ctx context.Context, userID int64, activeOnly bool) ([]Habit, error) {
 	q := `
-		SELECT id, user_id, name, unit_label, agg, target_per_period, per_log_default_qty,
-		       period, week_start_dow, month_anchor_day, rolling_len_days, anchor_date, tz, is_active, created_at
+		SELECT ` + habitColumns + `
 		FROM habit
 		WHERE user_id = $1
 	`
@@ -184,146 +432,1331 @@ func (r *Repo) ListHabitsByUser(ctx context.Context, userID int64, activeOnly bo
 	return hs, nil
 }
 
+// ListHabitsByUserPage is ListHabitsByUser limited to one limit/offset page
+func (r *Repo) ListHabitsByUserPage(ctx context.Context, userID int64, activeOnly bool, limit, offset int) ([]Habit, error) {
+	q := `
+		SELECT ` + habitColumns + `
+		FROM habit
+		WHERE user_id = $1
+	`
+	if activeOnly {
+		q += " AND is_active = TRUE"
+	}
+	q += " ORDER BY created_at DESC, id DESC LIMIT $2 OFFSET $3"
+
+	var hs []Habit
+	if err := r.db.SelectContext(ctx, &hs, q, userID, limit, offset); err != nil {
+		return nil, err
+	}
+	return hs, nil
+}
+
+// DeactivateHabit archives a habit. An already archived habit keeps its
+// original archive time, so archiving it again cannot postpone its purge.
 func (r *Repo) DeactivateHabit(ctx context.Context, habitID int64) error {
 	_, err := r.db.ExecContext(ctx, `
-		UPDATE habit SET is_active = FALSE WHERE id = $1
+		UPDATE habit SET is_active = FALSE, archived_at = COALESCE(archived_at, NOW()) WHERE id = $1
 	`, habitID)
 	return err
 }
 
-func (r *Repo) UpdateHabit(ctx context.Context, h *Habit) error {
+// ReactivateHabit restores a habit archived by DeactivateHabit
+func (r *Repo) ReactivateHabit(ctx context.Context, habitID int64) error {
 	_, err := r.db.ExecContext(ctx, `
-		UPDATE habit
-		SET
-			name = $1,
-			unit_label = $2,
-			agg = $3,
-			target_per_period = $4,
-			per_log_default_qty = $5,
-			period = $6,
-			week_start_dow = $7,
-			month_anchor_day = $8,
-			rolling_len_days = $9,
-			anchor_date = $10,
-			tz = $11,
-			is_active = $12
-		WHERE id = $13
-			AND user_id = $14
-	`, h.Name,
-		h.UnitLabel,
-		h.Agg,
-		h.TargetPerPeriod,
-		h.PerLogDefaultQty,
-		h.Period,
-		h.WeekStartDOW,
-		h.MonthAnchorDay,
-		h.RollingLenDays,
-		h.AnchorDate,
-		h.TZOverride,
-		h.IsActive,
-		h.ID,
-		h.UserID,
-	)
+		UPDATE habit SET is_active = TRUE, archived_at = NULL WHERE id = $1
+	`, habitID)
 	return err
+}
+
+// updatableHabitColumns whitelists the columns UpdateHabitFields may write
+var updatableHabitColumns = map[string]struct{}{
+	"name":                {},
+	"unit_label":          {},
+	"agg":                 {},
+	"target_per_period":   {},
+	"per_log_default_qty": {},
+	"period":              {},
+	"week_start_dow":      {},
+	"month_anchor_day":    {},
+	"rolling_len_days":    {},
+	"anchor_date":         {},
+	"tz":                  {},
+	"is_active":           {},
+	"log_policy":          {},
+	"on_duplicate":        {},
+	"color":               {},
+	"icon":                {},
+}
+
+// UpdateHabitFields writes only the given columns of the user's habit and
+// returns the updated row. Keys must be column names from updatableHabitColumns.
+func (r *Repo) UpdateHabitFields(ctx context.Context, habitID, userID int64, fields map[string]any) (*Habit, error) {
+	if len(fields) == 0 {
+		return nil, errors.New("no fields to update")
+	}
+
+	cols := make([]string, 0, len(fields))
+	for col := range fields {
+		if _, ok := updatableHabitColumns[col]; !ok {
+			return nil, fmt.Errorf("habit column %q is not updatable", col)
+		}
+		cols = append(cols, col)
+	}
+	sort.Strings(cols)
+
+	sets := make([]string, len(cols))
+	args := make([]any, 0, len(cols)+1)
+	for i, col := range cols {
+		sets[i] = fmt.Sprintf("%s = $%d", col, i+1)
+		args = append(args, fields[col])
+	}
+	args = append(args, habitID, userID)
+
+	q := fmt.Sprintf(`
+		UPDATE habit
+		SET %s
+		WHERE id = $%d AND user_id = $%d
+		RETURNING `+habitColumns+`
+	`, strings.Join(sets, ", "), len(args)-1, len(args))
+
+	var h Habit
+	if err := r.db.GetContext(ctx, &h, q, args...); err != nil {
+		return nil, err
+	}
+	for _, col := range statsHabitColumns {
+		if _, ok := fields[col]; ok {
+			if err := forgetHabitStats(ctx, r.db, h.ID); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+	return &h, nil
+}
+
+// statsHabitColumns are the habit columns that change how its periods are
+// cut or judged, so writing any of them makes the stats snapshot stale
+var statsHabitColumns = []string{
+	"agg", "target_per_period", "period", "week_start_dow", "month_anchor_day",
+	"rolling_len_days", "anchor_date", "tz",
+}
 
+// forgetHabitStats drops the stats snapshots of the given habits so the next
+// read recomputes them, as MergeHabits does. ex is the Repo's db or a tx.
+func forgetHabitStats(ctx context.Context, ex sqlx.ExecerContext, habitIDs ...int64) error {
+	if len(habitIDs) == 0 {
+		return nil
+	}
+	_, err := ex.ExecContext(ctx, `DELETE FROM habit_stats WHERE habit_id = ANY($1)`, pq.Array(habitIDs))
+	return err
 }
 
 // -------------------- LOGS --------------------
 
 func (r *Repo) InsertLog(ctx context.Context, l *HabitLog) (*HabitLog, error) {
+	// Logs are stored in UTC regardless of the offset the caller used
+	in := *l
+	in.OccurredAt = in.OccurredAt.UTC()
 
 	query := `
 		INSERT INTO habit_log (habit_id, occurred_at, quantity, note)
 		VALUES (:habit_id, :occurred_at, :quantity, :note)
 		RETURNING id, habit_id, occurred_at, quantity, note, created_at
 	`
-	rows, err := r.db.NamedQueryContext(ctx, query, l)
+	rows, err := r.db.NamedQueryContext(ctx, query, &in)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	if rows.Next() {
-		var out HabitLog
-		if err := rows.StructScan(&out); err != nil {
-			return nil, err
-		}
-		return &out, nil
+	if !rows.Next() {
+		return nil, errors.New("no row returned")
 	}
-	return nil, errors.New("no row returned")
+	var out HabitLog
+	if err := rows.StructScan(&out); err != nil {
+		return nil, err
+	}
+	if err := forgetHabitStats(ctx, r.db, out.HabitID); err != nil {
+		return nil, err
+	}
+	return &out, nil
 }
 
-func (r *Repo) ListLogsWithin(ctx context.Context, habitID int64, start, end time.Time) ([]HabitLog, error) {
-	var ls []HabitLog
-	err := r.db.SelectContext(ctx, &ls, `
-		SELECT id, habit_id, occurred_at, quantity, note, created_at
-		FROM habit_log
-		WHERE habit_id = $1
-		  AND occurred_at >= $2
-		  AND occurred_at <  $3
-		ORDER BY occurred_at ASC, id ASC
-	`, habitID, start, end)
-	return ls, err
+// InsertLogsBatch inserts all logs in a single transaction; if any insert
+// fails, none are kept. Callers must check habit ownership first. Log
+// policies are not enforced, so imported history is stored as given.
+func (r *Repo) InsertLogsBatch(ctx context.Context, logs []*HabitLog) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PreparexContext(ctx, `
+		INSERT INTO habit_log (habit_id, occurred_at, quantity, note)
+		VALUES ($1, $2, $3, $4)
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for i, l := range logs {
+		if _, err := stmt.ExecContext(ctx, l.HabitID, l.OccurredAt.UTC(), l.Quantity, l.Note); err != nil {
+			return fmt.Errorf("log %d: %w", i, err)
+		}
+	}
+	if err := forgetHabitStats(ctx, tx, logHabitIDs(logs, nil)...); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-func (r *Repo) ListLogs(ctx context.Context, habitID int64) ([]HabitLog, error) {
-	var ls []HabitLog
-	err := r.db.SelectContext(ctx, &ls, `
-		SELECT id, habit_id, occurred_at, quantity, note, created_at
-		FROM habit_log
-		WHERE habit_id = $1
-		ORDER BY occurred_at ASC, id ASC
-	`, habitID)
-	return ls, err
+// InsertLogsEach inserts logs in one transaction, isolating each row in a
+// savepoint so a row the database rejects is skipped rather than aborting the
+// rest. rowErrs[i] holds the error for logs[i], or nil if it was inserted;
+// err is only set when the transaction itself fails.
+func (r *Repo) InsertLogsEach(ctx context.Context, logs []*HabitLog) (rowErrs []error, err error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PreparexContext(ctx, `
+		INSERT INTO habit_log (habit_id, occurred_at, quantity, note)
+		VALUES ($1, $2, $3, $4)
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	rowErrs = make([]error, len(logs))
+	for i, l := range logs {
+		if _, err := tx.ExecContext(ctx, `SAVEPOINT import_row`); err != nil {
+			return nil, err
+		}
+		if _, err := stmt.ExecContext(ctx, l.HabitID, l.OccurredAt.UTC(), l.Quantity, l.Note); err != nil {
+			rowErrs[i] = err
+			if _, err := tx.ExecContext(ctx, `ROLLBACK TO SAVEPOINT import_row`); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, `RELEASE SAVEPOINT import_row`); err != nil {
+			return nil, err
+		}
+	}
+	if err := forgetHabitStats(ctx, tx, logHabitIDs(logs, rowErrs)...); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return rowErrs, nil
 }
 
-func (r *Repo) DeleteLog(ctx context.Context, logID int64) error {
-	_, err := r.db.ExecContext(ctx, `DELETE FROM habit_log WHERE id = $1`, logID)
-	return err
+// logHabitIDs returns the distinct habits of logs, skipping logs[i] when
+// rowErrs[i] is set
+func logHabitIDs(logs []*HabitLog, rowErrs []error) []int64 {
+	seen := make(map[int64]struct{}, len(logs))
+	ids := make([]int64, 0, len(logs))
+	for i, l := range logs {
+		if rowErrs != nil && rowErrs[i] != nil {
+			continue
+		}
+		if _, ok := seen[l.HabitID]; ok {
+			continue
+		}
+		seen[l.HabitID] = struct{}{}
+		ids = append(ids, l.HabitID)
+	}
+	return ids
 }
 
-func (r *Repo) UpdateLog(ctx context.Context, l *HabitLog) error {
-	_, err := r.db.ExecContext(ctx, `
-		UPDATE habit_log
-		SET habit_id = $1, occurred_at = $2, quantity = $3, note = $4
-		WHERE id = $5
-	`, l.HabitID, l.OccurredAt, l.Quantity, l.Note, l.ID)
-	return err
+// insertLogTx applies the habit's log policy to [start,end) and inserts l within
+// tx. A live log at the same instant is first updated by replace-duplicates
+// habits and refused with ErrDuplicateLog by reject-duplicates ones, before
+// the log policy is consulted. Instants compare in UTC, so the same moment
+// entered from different timezones is a duplicate.
+func insertLogTx(ctx context.Context, tx *sqlx.Tx, h *Habit, l *HabitLog, start, end time.Time) (*HabitLog, error) {
+	// Lock the habit row so concurrent inserts for the same period serialize
+	if _, err := tx.ExecContext(ctx, `SELECT 1 FROM habit WHERE id = $1 FOR UPDATE`, h.ID); err != nil {
+		return nil, err
+	}
+
+	switch h.OnDuplicate {
+	case OnDuplicateReplace:
+		var out HabitLog
+		err := tx.GetContext(ctx, &out, `
+			UPDATE habit_log SET quantity = $3, note = $4
+			WHERE id = (
+				SELECT id FROM habit_log
+				WHERE habit_id = $1
+				  AND occurred_at = $2
+				  AND deleted_at IS NULL
+				ORDER BY id
+				LIMIT 1
+			)
+			RETURNING id, habit_id, occurred_at, quantity, note, created_at
+		`, l.HabitID, l.OccurredAt.UTC(), l.Quantity, l.Note)
+		if err == nil {
+			if err := forgetHabitStats(ctx, tx, h.ID); err != nil {
+				return nil, err
+			}
+			return &out, nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+	case OnDuplicateReject:
+		var exists bool
+		err := tx.GetContext(ctx, &exists, `
+			SELECT EXISTS (
+				SELECT 1 FROM habit_log
+				WHERE habit_id = $1
+				  AND occurred_at = $2
+				  AND deleted_at IS NULL
+			)
+		`, l.HabitID, l.OccurredAt.UTC())
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			return nil, ErrDuplicateLog
+		}
+	}
+
+	switch h.LogPolicy {
+	case LogPolicySingle:
+		var exists bool
+		err := tx.GetContext(ctx, &exists, `
+			SELECT EXISTS (
+				SELECT 1 FROM habit_log
+				WHERE habit_id = $1
+				  AND deleted_at IS NULL
+				  AND occurred_at >= $2
+				  AND occurred_at <  $3
+			)
+		`, h.ID, start, end)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			return nil, ErrPeriodAlreadyLogged
+		}
+	case LogPolicyReplace:
+		_, err := tx.ExecContext(ctx, `
+			DELETE FROM habit_log
+			WHERE habit_id = $1
+			  AND deleted_at IS NULL
+			  AND occurred_at >= $2
+			  AND occurred_at <  $3
+		`, h.ID, start, end)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	out, err := insertLogRowTx(ctx, tx, l)
+	if err != nil {
+		return nil, err
+	}
+	if err := forgetHabitStats(ctx, tx, h.ID); err != nil {
+		return nil, err
+	}
+	return out, nil
 }
 
-func (r *Repo) DeleteHabit(ctx context.Context, habitID int64) error {
-	// Delete logs first due to foreign key constraint
-	_, err := r.db.ExecContext(ctx, `DELETE FROM habit_log WHERE habit_id = $1`, habitID)
+// InsertLogWithProgress inserts l under its habit's policies and, in the same
+// transaction, aggregates the period containing the log. A live log at the
+// same instant is updated by replace-duplicates habits and refused with
+// ErrDuplicateLog by reject-duplicates ones; then single-log habits refuse a
+// second log in the period with ErrPeriodAlreadyLogged and replace habits
+// drop the period's logs first. It returns the new log with the period's
+// value and the habit's target, so clients can update progress without a
+// second request. Periods use the habit's own timezone.
+func (r *Repo) InsertLogWithProgress(ctx context.Context, l *HabitLog) (*HabitLog, decimal.Decimal, decimal.Decimal, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
 	if err != nil {
-		return err
+		return nil, decimal.Zero, decimal.Zero, err
 	}
+	defer tx.Rollback()
 
-	// Delete the habit
-	_, err = r.db.ExecContext(ctx, `DELETE FROM habit WHERE id = $1`, habitID)
-	return err
+	h, userTZ, err := lockHabitTx(ctx, tx, l.HabitID)
+	if err != nil {
+		return nil, decimal.Zero, decimal.Zero, err
+	}
+
+	start, end := h.PeriodBounds(l.OccurredAt, h.Location(userTZ))
+	out, err := insertLogTx(ctx, tx, h, l, start, end)
+	if err != nil {
+		return nil, decimal.Zero, decimal.Zero, err
+	}
+
+	logs, err := periodLogsTx(ctx, tx, h.ID, start, end)
+	if err != nil {
+		return nil, decimal.Zero, decimal.Zero, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, decimal.Zero, decimal.Zero, err
+	}
+	return out, periodValue(h.Agg, logs), h.TargetPerPeriod, nil
 }
 
-// -------------------- ROLLUP / BUCKETS (for charts) --------------------
+// IncrementLog adds delta to the latest live log in the period containing at,
+// creating a log of delta when the period has none. Quantities are clamped at
+// zero, matching the habit_log CHECK. A negative delta with no log to adjust
+// returns ErrNothingToDecrement. It returns the log, whether it was created,
+// and the period's aggregated value afterwards.
+func (r *Repo) IncrementLog(ctx context.Context, h *Habit, userTZ string, delta decimal.Decimal, at time.Time) (*HabitLog, bool, decimal.Decimal, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, false, decimal.Zero, err
+	}
+	defer tx.Rollback()
 
-type BucketRow struct {
-	BucketStart   time.Time       `db:"bucket_start"    json:"bucket_start"`
-	BucketEnd     time.Time       `db:"bucket_end"      json:"bucket_end"`
-	Value         decimal.Decimal `db:"value"           json:"value"`
-	Target        decimal.Decimal `db:"target_per_period" json:"target"`
-	ProgressRatio sql.NullFloat64 `db:"progress_ratio"  json:"progress_ratio,omitempty"`
+	// Lock the habit row so concurrent increments serialize
+	if _, err := tx.ExecContext(ctx, `SELECT 1 FROM habit WHERE id = $1 FOR UPDATE`, h.ID); err != nil {
+		return nil, false, decimal.Zero, err
+	}
+
+	start, end := h.PeriodBounds(at, h.Location(userTZ))
+
+	out, err := latestLogInPeriodTx(ctx, tx, h.ID, start, end)
+	created := false
+	switch {
+	case err == nil:
+		qty := decimal.Max(out.Quantity.Add(delta), decimal.Zero)
+		if err := CheckNumeric("quantity", qty); err != nil {
+			return nil, false, decimal.Zero, err
+		}
+		out, err = setLogQuantityTx(ctx, tx, out.ID, qty, sql.NullString{})
+	case errors.Is(err, sql.ErrNoRows):
+		if !delta.IsPositive() {
+			return nil, false, decimal.Zero, ErrNothingToDecrement
+		}
+		if err := CheckNumeric("quantity", delta); err != nil {
+			return nil, false, decimal.Zero, err
+		}
+		out, err = insertLogRowTx(ctx, tx, &HabitLog{HabitID: h.ID, OccurredAt: at, Quantity: delta})
+		created = true
+	}
+	if err != nil {
+		return nil, false, decimal.Zero, err
+	}
+	if err := forgetHabitStats(ctx, tx, h.ID); err != nil {
+		return nil, false, decimal.Zero, err
+	}
+
+	logs, err := periodLogsTx(ctx, tx, h.ID, start, end)
+	if err != nil {
+		return nil, false, decimal.Zero, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, false, decimal.Zero, err
+	}
+	return out, created, periodValue(h.Agg, logs), nil
 }
 
-// RollupBuckets emits continuous buckets in [start,end] for the given habit,
-// computing aggregated value, target, and progress ratio. Aligns to habit/user tz,
-// handles daily/weekly/monthly/rolling and fills gaps (0 values).
+// UpsertLogForBucket sets the latest live log in the habit period containing
+// occurredAt to qty, or inserts a log at occurredAt when the period has none.
+// Periods follow the habit's schedule in its own timezone, falling back to its
+// owner's. A valid note replaces the updated log's note. It returns the log,
+// whether it was created, and the period's aggregated value afterwards.
+func (r *Repo) UpsertLogForBucket(ctx context.Context, habitID int64, occurredAt time.Time, qty decimal.Decimal, note sql.NullString) (*HabitLog, bool, decimal.Decimal, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, false, decimal.Zero, err
+	}
+	defer tx.Rollback()
+
+	// Lock the habit row so concurrent upserts for the same period serialize
+	h, userTZ, err := lockHabitTx(ctx, tx, habitID)
+	if err != nil {
+		return nil, false, decimal.Zero, err
+	}
+
+	start, end := h.PeriodBounds(occurredAt, h.Location(userTZ))
+
+	out, err := latestLogInPeriodTx(ctx, tx, h.ID, start, end)
+	created := false
+	switch {
+	case err == nil:
+		out, err = setLogQuantityTx(ctx, tx, out.ID, qty, note)
+	case errors.Is(err, sql.ErrNoRows):
+		out, err = insertLogRowTx(ctx, tx, &HabitLog{HabitID: h.ID, OccurredAt: occurredAt, Quantity: qty, Note: note})
+		created = true
+	}
+	if err != nil {
+		return nil, false, decimal.Zero, err
+	}
+	if err := forgetHabitStats(ctx, tx, h.ID); err != nil {
+		return nil, false, decimal.Zero, err
+	}
+
+	logs, err := periodLogsTx(ctx, tx, h.ID, start, end)
+	if err != nil {
+		return nil, false, decimal.Zero, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, false, decimal.Zero, err
+	}
+	return out, created, periodValue(h.Agg, logs), nil
+}
+
+// lockHabitTx loads a habit FOR UPDATE, so writes to its periods serialize,
+// along with its owner's timezone
+func lockHabitTx(ctx context.Context, tx *sqlx.Tx, habitID int64) (*Habit, string, error) {
+	var h Habit
+	err := tx.GetContext(ctx, &h, `SELECT `+habitColumns+` FROM habit WHERE id = $1 FOR UPDATE`, habitID)
+	if err != nil {
+		return nil, "", err
+	}
+	var userTZ string
+	if err := tx.GetContext(ctx, &userTZ, `SELECT tz FROM app_user WHERE id = $1`, h.UserID); err != nil {
+		return nil, "", err
+	}
+	return &h, userTZ, nil
+}
+
+// latestLogInPeriodTx returns the habit's newest live log in [start, end), or
+// sql.ErrNoRows when the period has none
+func latestLogInPeriodTx(ctx context.Context, tx *sqlx.Tx, habitID int64, start, end time.Time) (*HabitLog, error) {
+	var l HabitLog
+	err := tx.GetContext(ctx, &l, `
+		SELECT id, habit_id, occurred_at, quantity, note, created_at
+		FROM habit_log
+		WHERE habit_id = $1
+		  AND deleted_at IS NULL
+		  AND occurred_at >= $2
+		  AND occurred_at <  $3
+		ORDER BY occurred_at DESC, id DESC
+		LIMIT 1
+	`, habitID, start, end)
+	if err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+// periodLogsTx returns the habit's live logs in [start, end), oldest first
+func periodLogsTx(ctx context.Context, tx *sqlx.Tx, habitID int64, start, end time.Time) ([]HabitLog, error) {
+	var logs []HabitLog
+	err := tx.SelectContext(ctx, &logs, `
+		SELECT id, habit_id, occurred_at, quantity, note, created_at
+		FROM habit_log
+		WHERE habit_id = $1
+		  AND deleted_at IS NULL
+		  AND occurred_at >= $2
+		  AND occurred_at <  $3
+		ORDER BY occurred_at ASC, id ASC
+	`, habitID, start, end)
+	return logs, err
+}
+
+// setLogQuantityTx sets a log's quantity, and its note when note is valid
+func setLogQuantityTx(ctx context.Context, tx *sqlx.Tx, logID int64, qty decimal.Decimal, note sql.NullString) (*HabitLog, error) {
+	var l HabitLog
+	err := tx.GetContext(ctx, &l, `
+		UPDATE habit_log SET quantity = $2, note = COALESCE($3, note)
+		WHERE id = $1
+		RETURNING id, habit_id, occurred_at, quantity, note, created_at
+	`, logID, qty, note)
+	if err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+// insertLogRowTx inserts l as given, in UTC, without applying any policy
+func insertLogRowTx(ctx context.Context, tx *sqlx.Tx, l *HabitLog) (*HabitLog, error) {
+	var out HabitLog
+	err := tx.GetContext(ctx, &out, `
+		INSERT INTO habit_log (habit_id, occurred_at, quantity, note)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, habit_id, occurred_at, quantity, note, created_at
+	`, l.HabitID, l.OccurredAt.UTC(), l.Quantity, l.Note)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// QuickComplete inserts one log of each habit's default quantity at the given time,
+// skipping habits that already have a log in the period containing at.
+// Every habit must belong to userID or nothing is written (ErrHabitNotOwned).
+// The batch runs in a single transaction and returns only the logs it created.
+func (r *Repo) QuickComplete(ctx context.Context, userID int64, userTZ string, habitIDs []int64, at time.Time) ([]HabitLog, error) {
+	ids := make([]int64, 0, len(habitIDs))
+	seen := make(map[int64]struct{}, len(habitIDs))
+	for _, id := range habitIDs {
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		ids = append(ids, id)
+	}
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var habits []Habit
+	err = tx.SelectContext(ctx, &habits, `
+		SELECT `+habitColumns+`
+		FROM habit
+		WHERE id = ANY($1) AND user_id = $2
+		ORDER BY id
+		FOR UPDATE
+	`, pq.Array(ids), userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(habits) != len(ids) {
+		return nil, ErrHabitNotOwned
+	}
+
+	created := make([]HabitLog, 0, len(habits))
+	for _, h := range habits {
+		start, end := h.PeriodBounds(at, h.Location(userTZ))
+
+		var exists bool
+		err := tx.GetContext(ctx, &exists, `
+			SELECT EXISTS (
+				SELECT 1 FROM habit_log
+				WHERE habit_id = $1
+				  AND deleted_at IS NULL
+				  AND occurred_at >= $2
+				  AND occurred_at <  $3
+			)
+		`, h.ID, start, end)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			continue
+		}
+
+		l, err := insertLogRowTx(ctx, tx, &HabitLog{HabitID: h.ID, OccurredAt: at, Quantity: h.PerLogDefaultQty})
+		if err != nil {
+			return nil, err
+		}
+		created = append(created, *l)
+		if err := forgetHabitStats(ctx, tx, h.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+func (r *Repo) GetLog(ctx context.Context, logID int64) (*HabitLog, error) {
+	var l HabitLog
+	err := r.db.GetContext(ctx, &l, `
+		SELECT id, habit_id, occurred_at, quantity, note, created_at
+		FROM habit_log
+		WHERE id = $1
+		  AND deleted_at IS NULL
+	`, logID)
+	if err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+func (r *Repo) ListLogsWithin(ctx context.Context, habitID int64, start, end time.Time) ([]HabitLog, error) {
+	var ls []HabitLog
+	err := r.db.SelectContext(ctx, &ls, `
+		SELECT id, habit_id, occurred_at, quantity, note, created_at
+		FROM habit_log
+		WHERE habit_id = $1
+		  AND deleted_at IS NULL
+		  AND occurred_at >= $2
+		  AND occurred_at <  $3
+		ORDER BY occurred_at ASC, id ASC
+	`, habitID, start, end)
+	return ls, err
+}
+
+// ListLogsForHabitsWithin returns the live logs of all habitIDs in
+// [start, end) in one query, oldest first. Callers must check habit ownership.
+func (r *Repo) ListLogsForHabitsWithin(ctx context.Context, habitIDs []int64, start, end time.Time) ([]HabitLog, error) {
+	var ls []HabitLog
+	err := r.db.SelectContext(ctx, &ls, `
+		SELECT id, habit_id, occurred_at, quantity, note, created_at
+		FROM habit_log
+		WHERE habit_id = ANY($1)
+		  AND deleted_at IS NULL
+		  AND occurred_at >= $2
+		  AND occurred_at <  $3
+		ORDER BY occurred_at ASC, id ASC
+	`, pq.Array(habitIDs), start, end)
+	return ls, err
+}
+
+// LogExportRow is one log joined with its habit's name and unit, for export
+type LogExportRow struct {
+	HabitID    int64           `db:"habit_id"`
+	HabitName  string          `db:"habit_name"`
+	UnitLabel  sql.NullString  `db:"unit_label"`
+	OccurredAt time.Time       `db:"occurred_at"`
+	Quantity   decimal.Decimal `db:"quantity"`
+	Note       sql.NullString  `db:"note"`
+}
+
+// EachLogExportRow streams the user's logs, oldest first, calling fn for each
+// row as it is read so large exports are never held in memory. An error from
+// fn stops the iteration and is returned.
+func (r *Repo) EachLogExportRow(ctx context.Context, userID int64, fn func(LogExportRow) error) error {
+	rows, err := r.db.QueryxContext(ctx, `
+		SELECT h.id AS habit_id, h.name AS habit_name, h.unit_label, l.occurred_at, l.quantity, l.note
+		FROM habit_log l
+		JOIN habit h ON h.id = l.habit_id
+		WHERE h.user_id = $1
+		  AND l.deleted_at IS NULL
+		ORDER BY l.occurred_at ASC, l.id ASC
+	`, userID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var row LogExportRow
+		if err := rows.StructScan(&row); err != nil {
+			return err
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// StreamLogsForUser calls fn for each of the user's live logs, oldest first,
+// scanning one row at a time so the full set is never held in memory. An
+// error from fn stops the iteration and is returned.
+func (r *Repo) StreamLogsForUser(ctx context.Context, userID int64, fn func(HabitLog) error) error {
+	rows, err := r.db.QueryxContext(ctx, `
+		SELECT l.id, l.habit_id, l.occurred_at, l.quantity, l.note, l.created_at
+		FROM habit_log l
+		JOIN habit h ON h.id = l.habit_id
+		WHERE h.user_id = $1
+		  AND l.deleted_at IS NULL
+		ORDER BY l.occurred_at ASC, l.id ASC
+	`, userID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var l HabitLog
+		if err := rows.StructScan(&l); err != nil {
+			return err
+		}
+		if err := fn(l); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// ListRecentLogsByUser returns the user's most recent logs across all habits,
+// newest first, in a single query
+func (r *Repo) ListRecentLogsByUser(ctx context.Context, userID int64, limit int) ([]HabitLog, error) {
+	var ls []HabitLog
+	err := r.db.SelectContext(ctx, &ls, `
+		SELECT l.id, l.habit_id, l.occurred_at, l.quantity, l.note, l.created_at
+		FROM habit_log l
+		JOIN habit h ON h.id = l.habit_id
+		WHERE h.user_id = $1
+		  AND l.deleted_at IS NULL
+		ORDER BY l.occurred_at DESC, l.id DESC
+		LIMIT $2
+	`, userID, limit)
+	return ls, err
+}
+
+// Bootstrap holds everything the client needs on initial load
+type Bootstrap struct {
+	Habits      []Habit
+	RecentLogs  []HabitLog
+	Preferences JSONB
+}
+
+// GetBootstrap loads a user's active habits, most recent logs and
+// preferences with three queries instead of one per habit
+func (r *Repo) GetBootstrap(ctx context.Context, userID int64, logLimit int) (*Bootstrap, error) {
+	habits, err := r.ListHabitsByUser(ctx, userID, true)
+	if err != nil {
+		return nil, err
+	}
+	logs, err := r.ListRecentLogsByUser(ctx, userID, logLimit)
+	if err != nil {
+		return nil, err
+	}
+	prefs, err := r.GetPreferences(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &Bootstrap{Habits: habits, RecentLogs: logs, Preferences: prefs}, nil
+}
+
+// HabitStreak computes the current and longest run of consecutive periods in
+// which the habit met its target, bucketed in the habit's (or owner's) timezone
+func (r *Repo) HabitStreak(ctx context.Context, habitID int64) (*Streak, error) {
+	h, err := r.GetHabit(ctx, habitID)
+	if err != nil {
+		return nil, err
+	}
+
+	var userTZ string
+	if err := r.db.GetContext(ctx, &userTZ, `SELECT tz FROM app_user WHERE id = $1`, h.UserID); err != nil {
+		return nil, err
+	}
+
+	logs, err := r.ListLogs(ctx, habitID)
+	if err != nil {
+		return nil, err
+	}
+
+	s := computeStreak(h, logs, h.Location(userTZ), time.Now(), r.metGrace)
+	return &s, nil
+}
+
+// RefreshHabitStats recomputes the habit's stats snapshot from its live logs
+// and upserts it into habit_stats with a new computed_at
+func (r *Repo) RefreshHabitStats(ctx context.Context, habitID int64) (*HabitStats, error) {
+	h, err := r.GetHabit(ctx, habitID)
+	if err != nil {
+		return nil, err
+	}
+
+	var userTZ string
+	if err := r.db.GetContext(ctx, &userTZ, `SELECT tz FROM app_user WHERE id = $1`, h.UserID); err != nil {
+		return nil, err
+	}
+
+	logs, err := r.ListLogs(ctx, habitID)
+	if err != nil {
+		return nil, err
+	}
+
+	st := computeHabitStats(h, logs, h.Location(userTZ), time.Now(), r.metGrace)
+	var out HabitStats
+	err = r.db.GetContext(ctx, &out, `
+		INSERT INTO habit_stats (
+			habit_id, current_streak, longest_streak, last_met_period,
+			periods_met, periods_total, best_period_start, best_period_value, computed_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW())
+		ON CONFLICT (habit_id) DO UPDATE SET
+			current_streak    = EXCLUDED.current_streak,
+			longest_streak    = EXCLUDED.longest_streak,
+			last_met_period   = EXCLUDED.last_met_period,
+			periods_met       = EXCLUDED.periods_met,
+			periods_total     = EXCLUDED.periods_total,
+			best_period_start = EXCLUDED.best_period_start,
+			best_period_value = EXCLUDED.best_period_value,
+			computed_at       = EXCLUDED.computed_at
+		RETURNING habit_id, current_streak, longest_streak, last_met_period,
+		          periods_met, periods_total, best_period_start, best_period_value, computed_at
+	`, st.HabitID, st.CurrentStreak, st.LongestStreak, st.LastMetPeriod,
+		st.PeriodsMet, st.PeriodsTotal, st.BestPeriodStart, st.BestPeriodValue)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetHabitStats returns the habit's stored stats snapshot, or sql.ErrNoRows
+// if it has not been computed since the habit or its logs last changed
+func (r *Repo) GetHabitStats(ctx context.Context, habitID int64) (*HabitStats, error) {
+	var st HabitStats
+	err := r.db.GetContext(ctx, &st, `
+		SELECT habit_id, current_streak, longest_streak, last_met_period,
+		       periods_met, periods_total, best_period_start, best_period_value, computed_at
+		FROM habit_stats
+		WHERE habit_id = $1
+	`, habitID)
+	if err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+// HabitsDueToday returns the user's active habits whose current period has
+// not met its target yet, as judged by TodayProgress
+func (r *Repo) HabitsDueToday(ctx context.Context, userID int64) ([]Habit, error) {
+	progress, err := r.TodayProgress(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	met := make(map[int64]bool, len(progress))
+	for _, p := range progress {
+		met[p.HabitID] = p.Met
+	}
+
+	habits, err := r.ListHabitsByUser(ctx, userID, true)
+	if err != nil {
+		return nil, err
+	}
+	due := make([]Habit, 0, len(habits))
+	for _, h := range habits {
+		// A habit added since TodayProgress ran has no logs yet
+		if !met[h.ID] {
+			due = append(due, h)
+		}
+	}
+	return due, nil
+}
+
+// LogFilter narrows a user-scoped log listing. Nil fields are not applied;
+// From is inclusive and To is exclusive, matching ListLogsWithin.
+type LogFilter struct {
+	HabitID *int64
+	From    *time.Time
+	To      *time.Time
+}
+
+// LogCursor is a keyset position in a newest-first log listing: the
+// (occurred_at, id) of the last log on the previous page
+type LogCursor struct {
+	OccurredAt time.Time
+	ID         int64
+}
+
+// String encodes the cursor as "<RFC3339 occurred_at>,<id>"
+func (c LogCursor) String() string {
+	return c.OccurredAt.UTC().Format(time.RFC3339Nano) + "," + strconv.FormatInt(c.ID, 10)
+}
+
+// ParseLogCursor decodes a cursor produced by LogCursor.String
+func ParseLogCursor(s string) (LogCursor, error) {
+	at, id, ok := strings.Cut(s, ",")
+	if !ok {
+		return LogCursor{}, errors.New("cursor must be <occurred_at>,<id>")
+	}
+	t, err := time.Parse(time.RFC3339Nano, at)
+	if err != nil {
+		return LogCursor{}, errors.New("cursor occurred_at must be an RFC 3339 time")
+	}
+	n, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return LogCursor{}, errors.New("cursor id must be an integer")
+	}
+	return LogCursor{OccurredAt: t, ID: n}, nil
+}
+
+// ListLogsAfter returns up to limit of the user's logs matching f, newest
+// first by (occurred_at, id), starting after the cursor (from the newest when
+// nil). Unlike offsets, the position does not shift when logs are added, so
+// pages never repeat or skip a row. The returned cursor is nil on the last page.
+func (r *Repo) ListLogsAfter(ctx context.Context, userID int64, f LogFilter, after *LogCursor, limit int) ([]HabitLog, *LogCursor, error) {
+	var from, to, afterAt *time.Time
+	if f.From != nil {
+		t := f.From.UTC()
+		from = &t
+	}
+	if f.To != nil {
+		t := f.To.UTC()
+		to = &t
+	}
+	var afterID int64
+	if after != nil {
+		t := after.OccurredAt.UTC()
+		afterAt, afterID = &t, after.ID
+	}
+
+	// One extra row tells whether another page follows
+	var ls []HabitLog
+	err := r.db.SelectContext(ctx, &ls, `
+		SELECT l.id, l.habit_id, l.occurred_at, l.quantity, l.note, l.created_at
+		FROM habit_log l
+		JOIN habit h ON h.id = l.habit_id
+		WHERE h.user_id = $1
+		  AND l.deleted_at IS NULL
+		  AND ($2::bigint IS NULL OR l.habit_id = $2)
+		  AND ($3::timestamptz IS NULL OR l.occurred_at >= $3)
+		  AND ($4::timestamptz IS NULL OR l.occurred_at <  $4)
+		  AND ($5::timestamptz IS NULL OR (l.occurred_at, l.id) < ($5, $6))
+		ORDER BY l.occurred_at DESC, l.id DESC
+		LIMIT $7
+	`, userID, f.HabitID, from, to, afterAt, afterID, limit+1)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(ls) <= limit {
+		return ls, nil, nil
+	}
+	ls = ls[:limit]
+	last := ls[len(ls)-1]
+	return ls, &LogCursor{OccurredAt: last.OccurredAt, ID: last.ID}, nil
+}
+
+// ListLogsPaged returns one page of the user's logs matching f, along with the
+// total number of matching logs. Logs are scoped to the user through a join
+// on habit, in one query.
+func (r *Repo) ListLogsPaged(ctx context.Context, userID int64, f LogFilter, limit, offset int) ([]HabitLog, int, error) {
+	var from, to *time.Time
+	if f.From != nil {
+		t := f.From.UTC()
+		from = &t
+	}
+	if f.To != nil {
+		t := f.To.UTC()
+		to = &t
+	}
+
+	var rows []struct {
+		HabitLog
+		Total int `db:"total"`
+	}
+	err := r.db.SelectContext(ctx, &rows, `
+		SELECT l.id, l.habit_id, l.occurred_at, l.quantity, l.note, l.created_at,
+		       COUNT(*) OVER () AS total
+		FROM habit_log l
+		JOIN habit h ON h.id = l.habit_id
+		WHERE h.user_id = $1
+		  AND l.deleted_at IS NULL
+		  AND ($2::bigint IS NULL OR l.habit_id = $2)
+		  AND ($3::timestamptz IS NULL OR l.occurred_at >= $3)
+		  AND ($4::timestamptz IS NULL OR l.occurred_at <  $4)
+		ORDER BY l.occurred_at ASC, l.id ASC
+		LIMIT $5 OFFSET $6
+	`, userID, f.HabitID, from, to, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	ls := make([]HabitLog, len(rows))
+	total := 0
+	for i, row := range rows {
+		ls[i] = row.HabitLog
+		total = row.Total
+	}
+	// An offset past the end returns no rows, so count separately
+	if len(rows) == 0 && offset > 0 {
+		err = r.db.GetContext(ctx, &total, `
+			SELECT COUNT(*)
+			FROM habit_log l
+			JOIN habit h ON h.id = l.habit_id
+			WHERE h.user_id = $1
+			  AND l.deleted_at IS NULL
+			  AND ($2::bigint IS NULL OR l.habit_id = $2)
+			  AND ($3::timestamptz IS NULL OR l.occurred_at >= $3)
+			  AND ($4::timestamptz IS NULL OR l.occurred_at <  $4)
+		`, userID, f.HabitID, from, to)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+	return ls, total, nil
+}
+
+func (r *Repo) ListLogs(ctx context.Context, habitID int64) ([]HabitLog, error) {
+	var ls []HabitLog
+	err := r.db.SelectContext(ctx, &ls, `
+		SELECT id, habit_id, occurred_at, quantity, note, created_at
+		FROM habit_log
+		WHERE habit_id = $1
+		  AND deleted_at IS NULL
+		ORDER BY occurred_at ASC, id ASC
+	`, habitID)
+	return ls, err
+}
+
+func (r *Repo) DeleteLog(ctx context.Context, logID int64) error {
+	var habitID int64
+	err := r.db.GetContext(ctx, &habitID, `DELETE FROM habit_log WHERE id = $1 RETURNING habit_id`, logID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return forgetHabitStats(ctx, r.db, habitID)
+}
+
+// SoftDeleteLog marks a log deleted and returns it. Soft-deleted logs are
+// hidden from every read until RestoreLog, and PurgeDeletedLogs removes them.
+func (r *Repo) SoftDeleteLog(ctx context.Context, logID int64) (*HabitLog, error) {
+	var l HabitLog
+	err := r.db.GetContext(ctx, &l, `
+		UPDATE habit_log SET deleted_at = NOW()
+		WHERE id = $1
+		  AND deleted_at IS NULL
+		RETURNING id, habit_id, occurred_at, quantity, note, created_at
+	`, logID)
+	if err != nil {
+		return nil, err
+	}
+	if err := forgetHabitStats(ctx, r.db, l.HabitID); err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+// RestoreLog undoes SoftDeleteLog for a log of one of userID's habits
+func (r *Repo) RestoreLog(ctx context.Context, logID, userID int64) (*HabitLog, error) {
+	var l HabitLog
+	err := r.db.GetContext(ctx, &l, `
+		UPDATE habit_log l SET deleted_at = NULL
+		FROM habit h
+		WHERE l.id = $1
+		  AND h.id = l.habit_id
+		  AND h.user_id = $2
+		  AND l.deleted_at IS NOT NULL
+		RETURNING l.id, l.habit_id, l.occurred_at, l.quantity, l.note, l.created_at
+	`, logID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := forgetHabitStats(ctx, r.db, l.HabitID); err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+// PurgeDeletedLogs permanently removes logs soft-deleted before cutoff and
+// returns how many were removed
+func (r *Repo) PurgeDeletedLogs(ctx context.Context, cutoff time.Time) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `
+		DELETE FROM habit_log WHERE deleted_at < $1
+	`, cutoff.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// PurgeArchivedHabits permanently deletes habits archived before cutoff,
+// along with their logs, and returns how many habits were removed. Active
+// habits and habits without an archive time are never touched.
+func (r *Repo) PurgeArchivedHabits(ctx context.Context, cutoff time.Time) (int64, error) {
+	// habit_log rows go with the habit through ON DELETE CASCADE
+	res, err := r.db.ExecContext(ctx, `
+		DELETE FROM habit
+		WHERE is_active = FALSE
+		  AND archived_at < $1
+	`, cutoff.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// UpdateLog replaces a live log's habit, time, quantity and note and returns
+// the stored row. Both the log's current habit and l.HabitID must belong to
+// userID; otherwise nothing changes and sql.ErrNoRows is returned.
+func (r *Repo) UpdateLog(ctx context.Context, userID int64, l *HabitLog) (*HabitLog, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var fromID int64
+	err = tx.GetContext(ctx, &fromID, `
+		SELECT l.habit_id
+		FROM habit_log l
+		JOIN habit h ON h.id = l.habit_id
+		WHERE l.id = $1
+		  AND l.deleted_at IS NULL
+		  AND h.user_id = $2
+		FOR UPDATE OF l
+	`, l.ID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var out HabitLog
+	err = tx.GetContext(ctx, &out, `
+		UPDATE habit_log l
+		SET habit_id = $1, occurred_at = $2, quantity = $3, note = $4
+		FROM habit dst
+		WHERE l.id = $5
+		  AND dst.id = $1 AND dst.user_id = $6
+		RETURNING l.id, l.habit_id, l.occurred_at, l.quantity, l.note, l.created_at
+	`, l.HabitID, l.OccurredAt.UTC(), l.Quantity, l.Note, l.ID, userID)
+	if err != nil {
+		return nil, err
+	}
+	// A moved log changes both habits' stats
+	if err := forgetHabitStats(ctx, tx, fromID, out.HabitID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeleteHabit deletes a habit, handling its logs according to mode:
+// cascade deletes them, archive moves them to the user's archive habit,
+// and block refuses with ErrHabitHasLogs when any exist. Soft-deleted logs
+// count as logs in every mode, since they can still be restored.
+func (r *Repo) DeleteHabit(ctx context.Context, habitID, userID int64, mode HabitDeleteMode) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Scope to the owner; another user's habit reads as sql.ErrNoRows
+	var lockedID int64
+	err = tx.GetContext(ctx, &lockedID, `SELECT id FROM habit WHERE id = $1 AND user_id = $2 FOR UPDATE`, habitID, userID)
+	if err != nil {
+		return err
+	}
+
+	// Deleted logs included: the habit's row going would cascade them away
+	var hasLogs bool
+	err = tx.GetContext(ctx, &hasLogs, `SELECT EXISTS (SELECT 1 FROM habit_log WHERE habit_id = $1)`, habitID)
+	if err != nil {
+		return err
+	}
+
+	switch mode {
+	case HabitDeleteBlock:
+		if hasLogs {
+			return ErrHabitHasLogs
+		}
+	case HabitDeleteArchive:
+		if hasLogs {
+			archiveID, err := archiveHabitID(ctx, tx, userID)
+			if err != nil {
+				return err
+			}
+			// The archive habit has nowhere to move its own logs
+			if archiveID == habitID {
+				return ErrHabitHasLogs
+			}
+			_, err = tx.ExecContext(ctx, `UPDATE habit_log SET habit_id = $1 WHERE habit_id = $2`, archiveID, habitID)
+			if err != nil {
+				return err
+			}
+			if err := forgetHabitStats(ctx, tx, archiveID); err != nil {
+				return err
+			}
+		}
+	default:
+		// Delete logs first due to foreign key constraint
+		_, err = tx.ExecContext(ctx, `DELETE FROM habit_log WHERE habit_id = $1`, habitID)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Delete the habit
+	_, err = tx.ExecContext(ctx, `DELETE FROM habit WHERE id = $1 AND user_id = $2`, habitID, userID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// MergeHabits moves every log of mergeID, soft-deleted ones included, onto
+// keepID and deletes mergeID, in one transaction. Both habits must belong to
+// userID or nothing changes (ErrHabitNotOwned). keepID's stats snapshot is
+// dropped so the next read recomputes it with the combined logs.
+func (r *Repo) MergeHabits(ctx context.Context, keepID, mergeID, userID int64) error {
+	if keepID == mergeID {
+		return errors.New("cannot merge a habit into itself")
+	}
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Lock both rows in id order so concurrent merges cannot deadlock
+	var locked []int64
+	err = tx.SelectContext(ctx, &locked, `
+		SELECT id FROM habit
+		WHERE id = ANY($1) AND user_id = $2
+		ORDER BY id
+		FOR UPDATE
+	`, pq.Array([]int64{keepID, mergeID}), userID)
+	if err != nil {
+		return err
+	}
+	if len(locked) != 2 {
+		return ErrHabitNotOwned
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE habit_log SET habit_id = $1 WHERE habit_id = $2`, keepID, mergeID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM habit WHERE id = $1 AND user_id = $2`, mergeID, userID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM habit_stats WHERE habit_id = $1`, keepID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// archiveHabitID returns the user's inactive archive habit, creating it on first use
+func archiveHabitID(ctx context.Context, tx *sqlx.Tx, userID int64) (int64, error) {
+	var id int64
+	err := tx.GetContext(ctx, &id, `
+		SELECT id FROM habit
+		WHERE user_id = $1 AND name = $2 AND is_active = FALSE
+		ORDER BY id
+		LIMIT 1
+	`, userID, ArchiveHabitName)
+	if err == nil {
+		return id, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+
+	err = tx.GetContext(ctx, &id, `
+		INSERT INTO habit (user_id, name, is_active)
+		VALUES ($1, $2, FALSE)
+		RETURNING id
+	`, userID, ArchiveHabitName)
+	return id, err
+}
+
+// -------------------- ROLLUP / BUCKETS (for charts) --------------------
+
+// BucketRow is one rollup bucket; its JSON names are camelCase to match the API
+type BucketRow struct {
+	BucketStart   time.Time       `db:"bucket_start"      json:"bucketStart"`
+	BucketEnd     time.Time       `db:"bucket_end"        json:"bucketEnd"`
+	Value         decimal.Decimal `db:"value"             json:"value"`
+	Target        decimal.Decimal `db:"target_per_period" json:"target"`
+	ProgressRatio *float64        `db:"progress_ratio"    json:"progressRatio,omitempty"` // nil when target is 0
+	LogCount      int             `db:"log_count"         json:"logCount"`
+}
+
+// RollupBuckets emits continuous buckets in [start,end] for the given habit,
+// computing aggregated value, target, and progress ratio. Aligns to habit/user tz,
+// handles daily/weekly/monthly/rolling and fills gaps (0 values).
 func (r *Repo) RollupBuckets(ctx context.Context, habitID int64, start, end time.Time) ([]BucketRow, error) {
-	// NOTE: This SQL mirrors the earlier design. If you extend agg_kind beyond sum/count/boolean,
+	// NOTE: This SQL mirrors the earlier design. If you extend agg_kind further,
 	// add additional WHEN branches in values_in_bucket CASE below.
 	sql := `
 WITH params AS (
   SELECT
     h.id,
     h.agg,
-    h.target_per_period,
+    COALESCE(h.target_per_period, 0) AS target_per_period,
     h.period,
     h.week_start_dow,
     h.rolling_len_days,
@@ -375,19 +1808,23 @@ values_in_bucket AS (
     a.bucket_start,
     CASE p.agg
       WHEN 'sum'     THEN COALESCE(SUM(l.quantity), 0)
-      WHEN 'count'   THEN COALESCE(COUNT(*), 0)
-      WHEN 'boolean' THEN CASE WHEN COUNT(*) > 0 THEN 1 ELSE 0 END
-      -- WHEN 'avg'   THEN COALESCE(AVG(l.quantity), 0)        -- if you add to enum
-      -- WHEN 'min'   THEN COALESCE(MIN(l.quantity), 0)
-      -- WHEN 'max'   THEN COALESCE(MAX(l.quantity), 0)
-      -- WHEN 'last'  THEN COALESCE((ARRAY_AGG(l.quantity ORDER BY l.occurred_at DESC))[1], 0)
-    END AS value
+      WHEN 'count'   THEN COUNT(l.id)
+      WHEN 'boolean' THEN CASE WHEN COUNT(l.id) > 0 THEN 1 ELSE 0 END
+      WHEN 'avg'     THEN COALESCE(AVG(l.quantity), 0)
+      WHEN 'min'     THEN COALESCE(MIN(l.quantity), 0)
+      WHEN 'max'     THEN COALESCE(MAX(l.quantity), 0)
+      WHEN 'last'    THEN COALESCE((ARRAY_AGG(l.quantity ORDER BY l.occurred_at DESC, l.id DESC)
+                                    FILTER (WHERE l.id IS NOT NULL))[1], 0)
+    END AS value,
+    COUNT(l.id) AS log_count
   FROM agg_logs a
   JOIN params p ON TRUE
+  -- Buckets are local wall times in p.tz; convert them back to instants
   LEFT JOIN habit_log l
     ON l.habit_id = p.id
-   AND l.occurred_at >= a.bucket_start
-   AND l.occurred_at <  a.bucket_end
+   AND l.deleted_at IS NULL
+   AND l.occurred_at >= (a.bucket_start AT TIME ZONE p.tz)
+   AND l.occurred_at <  (a.bucket_end   AT TIME ZONE p.tz)
   GROUP BY a.bucket_start, p.agg
 )
 SELECT
@@ -397,7 +1834,8 @@ SELECT
   a.target_per_period,
   CASE WHEN a.target_per_period = 0 THEN NULL
        ELSE (v.value / a.target_per_period)
-  END AS progress_ratio
+  END AS progress_ratio,
+  v.log_count
 FROM agg_logs a
 JOIN values_in_bucket v USING (bucket_start)
 ORDER BY a.bucket_start;
@@ -408,3 +1846,219 @@ ORDER BY a.bucket_start;
 	}
 	return rows, nil
 }
+
+// CompletionRate counts the periods in [start,end] whose aggregated value met
+// the habit target, out of all periods in the window. Periods come from
+// RollupBuckets, so they follow the habit's schedule and timezone. As with
+// streaks, a period without logs only counts for a sum habit.
+func (r *Repo) CompletionRate(ctx context.Context, habitID int64, start, end time.Time) (met int, total int, err error) {
+	h, err := r.GetHabit(ctx, habitID)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	buckets, err := r.RollupBuckets(ctx, habitID, start, end)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for _, b := range buckets {
+		if b.LogCount == 0 && h.Agg != AggSum {
+			continue
+		}
+		if valueMet(h.Agg, b.Value, b.Target, r.metGrace) {
+			met++
+		}
+	}
+	return met, len(buckets), nil
+}
+
+// TodayProgress is one active habit's progress over its current period, so
+// a weekly habit counts the whole week's logs against its weekly target
+type TodayProgress struct {
+	HabitID     int64           `json:"habitId"`
+	Agg         AggKind         `json:"agg"`
+	Period      PeriodType      `json:"period"`
+	PeriodStart time.Time       `json:"periodStart"`
+	PeriodEnd   time.Time       `json:"periodEnd"`
+	Value       decimal.Decimal `json:"value"`
+	Target      decimal.Decimal `json:"target"`
+	LogCount    int             `json:"logCount"`
+	Met         bool            `json:"met"`
+}
+
+// TodayProgress aggregates the current period of each of the user's active
+// habits, newest habit first, in one query. Periods are taken in the habit's
+// timezone, falling back to its owner's, with the same bounds as
+// Habit.PeriodBounds; a period without logs is only met by a sum habit, as
+// with streaks.
+func (r *Repo) TodayProgress(ctx context.Context, userID int64) ([]TodayProgress, error) {
+	var rows []struct {
+		HabitID     int64           `db:"habit_id"`
+		Agg         AggKind         `db:"agg"`
+		Period      PeriodType      `db:"period"`
+		PeriodStart time.Time       `db:"period_start"`
+		PeriodEnd   time.Time       `db:"period_end"`
+		Value       decimal.Decimal `db:"value"`
+		Target      decimal.Decimal `db:"target"`
+		LogCount    int             `db:"log_count"`
+	}
+	// Each window is computed as local wall time in the habit's timezone and
+	// converted back to instants, mirroring PeriodBounds branch for branch
+	err := r.db.SelectContext(ctx, &rows, `
+WITH params AS (
+  SELECT
+    h.id,
+    h.agg,
+    h.period,
+    COALESCE(h.target_per_period, 0) AS target,
+    h.week_start_dow,
+    GREATEST(COALESCE(h.rolling_len_days, 1), 1) AS rolling_len,
+    h.anchor_date,
+    h.created_at,
+    COALESCE(h.tz, u.tz) AS tz,
+    $2::timestamptz AT TIME ZONE COALESCE(h.tz, u.tz) AS local_now
+  FROM habit h
+  JOIN app_user u ON u.id = h.user_id
+  WHERE h.user_id = $1
+    AND h.is_active
+),
+windows AS (
+  SELECT
+    p.*,
+    CASE p.period
+      WHEN 'weekly'  THEN date_trunc('day', p.local_now)
+                          - ((EXTRACT(DOW FROM p.local_now)::int - p.week_start_dow + 7) % 7) * INTERVAL '1 day'
+      WHEN 'monthly' THEN date_trunc('month', p.local_now)
+      WHEN 'rolling' THEN (DATE (p.local_now)
+                           - (((DATE (p.local_now) - p.anchor_date) % p.rolling_len) + p.rolling_len) % p.rolling_len)::timestamp
+      ELSE date_trunc('day', p.local_now)
+    END AS local_start
+  FROM params p
+),
+bounds AS (
+  SELECT
+    w.*,
+    w.local_start AT TIME ZONE w.tz AS period_start,
+    (w.local_start + CASE w.period
+                       WHEN 'weekly'  THEN INTERVAL '7 days'
+                       WHEN 'monthly' THEN INTERVAL '1 month'
+                       WHEN 'rolling' THEN w.rolling_len * INTERVAL '1 day'
+                       ELSE INTERVAL '1 day'
+                     END) AT TIME ZONE w.tz AS period_end
+  FROM windows w
+)
+SELECT
+  b.id AS habit_id,
+  b.agg,
+  b.period,
+  b.period_start,
+  b.period_end,
+  CASE b.agg
+    WHEN 'count'   THEN COUNT(l.id)
+    WHEN 'boolean' THEN CASE WHEN COUNT(l.id) > 0 THEN 1 ELSE 0 END
+    WHEN 'avg'     THEN COALESCE(AVG(l.quantity), 0)
+    WHEN 'min'     THEN COALESCE(MIN(l.quantity), 0)
+    WHEN 'max'     THEN COALESCE(MAX(l.quantity), 0)
+    WHEN 'last'    THEN COALESCE((ARRAY_AGG(l.quantity ORDER BY l.occurred_at DESC, l.id DESC)
+                                  FILTER (WHERE l.id IS NOT NULL))[1], 0)
+    ELSE COALESCE(SUM(l.quantity), 0)
+  END AS value,
+  b.target,
+  COUNT(l.id) AS log_count
+FROM bounds b
+LEFT JOIN habit_log l
+  ON l.habit_id = b.id
+ AND l.deleted_at IS NULL
+ AND l.occurred_at >= b.period_start
+ AND l.occurred_at <  b.period_end
+GROUP BY b.id, b.agg, b.period, b.period_start, b.period_end, b.target, b.created_at
+ORDER BY b.created_at DESC, b.id DESC
+`, userID, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]TodayProgress, len(rows))
+	for i, row := range rows {
+		out[i] = TodayProgress{
+			HabitID:     row.HabitID,
+			Agg:         row.Agg,
+			Period:      row.Period,
+			PeriodStart: row.PeriodStart,
+			PeriodEnd:   row.PeriodEnd,
+			Value:       row.Value,
+			Target:      row.Target,
+			LogCount:    row.LogCount,
+			Met:         (row.LogCount > 0 || row.Agg == AggSum) && valueMet(row.Agg, row.Value, row.Target, r.metGrace),
+		}
+	}
+	return out, nil
+}
+
+// TotalsByDayOfWeek sums a habit's logged quantities in [from, to) by local
+// weekday, indexed 0 = Sunday .. 6 = Saturday. Weekdays are taken in the
+// habit's timezone, falling back to its owner's, as RollupBuckets does.
+func (r *Repo) TotalsByDayOfWeek(ctx context.Context, habitID int64, from, to time.Time) ([7]decimal.Decimal, error) {
+	var totals [7]decimal.Decimal
+	for i := range totals {
+		totals[i] = decimal.Zero
+	}
+
+	var rows []struct {
+		DOW   int             `db:"dow"`
+		Total decimal.Decimal `db:"total"`
+	}
+	err := r.db.SelectContext(ctx, &rows, `
+		SELECT EXTRACT(DOW FROM l.occurred_at AT TIME ZONE COALESCE(h.tz, u.tz))::int AS dow,
+		       SUM(l.quantity) AS total
+		FROM habit_log l
+		JOIN habit h ON h.id = l.habit_id
+		JOIN app_user u ON u.id = h.user_id
+		WHERE l.habit_id = $1
+		  AND l.deleted_at IS NULL
+		  AND l.occurred_at >= $2
+		  AND l.occurred_at <  $3
+		GROUP BY dow
+	`, habitID, from.UTC(), to.UTC())
+	if err != nil {
+		return totals, err
+	}
+	for _, row := range rows {
+		totals[row.DOW] = row.Total
+	}
+	return totals, nil
+}
+
+// TotalsByHour sums a habit's logged quantities in [from, to) by local hour of
+// day (0..23), in the habit's timezone falling back to its owner's
+func (r *Repo) TotalsByHour(ctx context.Context, habitID int64, from, to time.Time) ([24]decimal.Decimal, error) {
+	var totals [24]decimal.Decimal
+	for i := range totals {
+		totals[i] = decimal.Zero
+	}
+
+	var rows []struct {
+		Hour  int             `db:"hour"`
+		Total decimal.Decimal `db:"total"`
+	}
+	err := r.db.SelectContext(ctx, &rows, `
+		SELECT EXTRACT(HOUR FROM l.occurred_at AT TIME ZONE COALESCE(h.tz, u.tz))::int AS hour,
+		       SUM(l.quantity) AS total
+		FROM habit_log l
+		JOIN habit h ON h.id = l.habit_id
+		JOIN app_user u ON u.id = h.user_id
+		WHERE l.habit_id = $1
+		  AND l.deleted_at IS NULL
+		  AND l.occurred_at >= $2
+		  AND l.occurred_at <  $3
+		GROUP BY hour
+	`, habitID, from.UTC(), to.UTC())
+	if err != nil {
+		return totals, err
+	}
+	for _, row := range rows {
+		totals[row.Hour] = row.Total
+	}
+	return totals, nil
+}
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"sync"
+	"time"
+)
+
+// sessionCache holds recent session lookups keyed by token for a short TTL.
+// It is bounded to max entries and safe for concurrent use.
+type sessionCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	max     int
+	entries map[string]sessionCacheEntry
+}
+
+type sessionCacheEntry struct {
+	session  UserSession
+	user     AppUser
+	cachedAt time.Time
+}
+
+func newSessionCache(ttl time.Duration, max int) *sessionCache {
+	return &sessionCache{ttl: ttl, max: max, entries: make(map[string]sessionCacheEntry)}
+}
+
+// get returns copies of the cached session and user, if fresh
+func (c *sessionCache) get(token string) (*UserSession, *AppUser, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[token]
+	if !ok {
+		return nil, nil, false
+	}
+	if time.Since(e.cachedAt) >= c.ttl {
+		delete(c.entries, token)
+		return nil, nil, false
+	}
+	s, u := e.session, e.user
+	return &s, &u, true
+}
+
+func (c *sessionCache) put(s *UserSession, u *AppUser) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[s.SessionToken]; !ok && len(c.entries) >= c.max {
+		c.evictLocked()
+	}
+	c.entries[s.SessionToken] = sessionCacheEntry{session: *s, user: *u, cachedAt: time.Now()}
+}
+
+// evictLocked drops stale entries, or an arbitrary one if none are stale
+func (c *sessionCache) evictLocked() {
+	for token, e := range c.entries {
+		if time.Since(e.cachedAt) >= c.ttl {
+			delete(c.entries, token)
+		}
+	}
+	if len(c.entries) < c.max {
+		return
+	}
+	for token := range c.entries {
+		delete(c.entries, token)
+		return
+	}
+}
+
+func (c *sessionCache) deleteToken(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, token)
+}
+
+// deleteUser drops every cached session of userID except keepToken
+func (c *sessionCache) deleteUser(userID int64, keepToken string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for token, e := range c.entries {
+		if e.session.UserID == userID && token != keepToken {
+			delete(c.entries, token)
+		}
+	}
+}
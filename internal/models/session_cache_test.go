@@ -0,0 +1,73 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func cachedSession(token string, userID int64) (*UserSession, *AppUser) {
+	return &UserSession{SessionToken: token, UserID: userID}, &AppUser{ID: userID}
+}
+
+func TestSessionCacheTTL(t *testing.T) {
+	c := newSessionCache(20*time.Millisecond, 10)
+	c.put(cachedSession("a", 1))
+
+	s, u, ok := c.get("a")
+	if !ok || s.SessionToken != "a" || u.ID != 1 {
+		t.Fatalf("get(a) = %+v, %+v, %v", s, u, ok)
+	}
+	// Callers get copies, so changing them leaves the cache alone
+	s.UserID = 2
+	if s, _, _ := c.get("a"); s.UserID != 1 {
+		t.Errorf("cached session changed through a returned copy")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if _, _, ok := c.get("a"); ok {
+		t.Error("stale entry returned")
+	}
+	if len(c.entries) != 0 {
+		t.Errorf("%d entries left after expiry", len(c.entries))
+	}
+}
+
+func TestSessionCacheBounded(t *testing.T) {
+	c := newSessionCache(time.Minute, 2)
+	c.put(cachedSession("a", 1))
+	c.put(cachedSession("b", 1))
+	c.put(cachedSession("a", 1)) // replacing an entry evicts nothing
+	if len(c.entries) != 2 {
+		t.Fatalf("%d entries, want 2", len(c.entries))
+	}
+	c.put(cachedSession("c", 1))
+	if len(c.entries) != 2 {
+		t.Errorf("%d entries, want at most 2", len(c.entries))
+	}
+	if _, _, ok := c.get("c"); !ok {
+		t.Error("newest entry was evicted")
+	}
+}
+
+func TestSessionCacheInvalidation(t *testing.T) {
+	c := newSessionCache(time.Minute, 10)
+	c.put(cachedSession("a", 1))
+	c.put(cachedSession("b", 1))
+	c.put(cachedSession("c", 1))
+	c.put(cachedSession("d", 2))
+
+	c.deleteToken("a")
+	if _, _, ok := c.get("a"); ok {
+		t.Error("deleted token still cached")
+	}
+	c.deleteUser(1, "b")
+	if _, _, ok := c.get("b"); !ok {
+		t.Error("kept token was dropped")
+	}
+	if _, _, ok := c.get("c"); ok {
+		t.Error("other session of the user still cached")
+	}
+	if _, _, ok := c.get("d"); !ok {
+		t.Error("another user's session was dropped")
+	}
+}
@@ -0,0 +1,62 @@
+package models_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/noahjalex/epoch/internal/auth"
+	"github.com/noahjalex/epoch/internal/models"
+	"github.com/noahjalex/epoch/internal/testdb"
+)
+
+// newSession stores a session for userID expiring at expiresAt
+func newSession(t *testing.T, repo *models.Repo, userID int64, expiresAt time.Time) string {
+	t.Helper()
+	token, err := auth.GenerateSessionToken()
+	check(t, err)
+	_, err = repo.CreateSession(context.Background(), userID, token, expiresAt, false)
+	check(t, err)
+	return token
+}
+
+func TestGetSessionWithUser(t *testing.T) {
+	repo := testdb.Repo(t)
+	ctx := context.Background()
+	user := testdb.NewUser(t, repo, "Europe/Paris")
+	token := newSession(t, repo, user.ID, time.Now().Add(time.Hour))
+
+	s, u, err := repo.GetSessionWithUser(ctx, token)
+	check(t, err)
+	if s.SessionToken != token || s.UserID != user.ID || s.Remember {
+		t.Errorf("session = %+v, want token %q of user %d", s, token, user.ID)
+	}
+	if u.ID != user.ID || u.Username != user.Username || u.TZ != "Europe/Paris" {
+		t.Errorf("user = %+v, want %+v", u, user)
+	}
+
+	if _, _, err := repo.GetSessionWithUser(ctx, "no-such-token"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("unknown token: err = %v, want sql.ErrNoRows", err)
+	}
+}
+
+func TestCountActiveSessions(t *testing.T) {
+	repo := testdb.Repo(t)
+	ctx := context.Background()
+	user := testdb.NewUser(t, repo, "")
+
+	// The count is database-wide, so compare it before and after seeding
+	before, err := repo.CountActiveSessions(ctx)
+	check(t, err)
+	newSession(t, repo, user.ID, time.Now().Add(time.Hour))
+	newSession(t, repo, user.ID, time.Now().Add(24*time.Hour))
+	newSession(t, repo, user.ID, time.Now().Add(-time.Minute))
+
+	after, err := repo.CountActiveSessions(ctx)
+	check(t, err)
+	if after-before != 2 {
+		t.Errorf("active sessions grew by %d, want 2", after-before)
+	}
+}
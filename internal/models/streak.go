@@ -0,0 +1,133 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Streak summarizes consecutive periods in which a habit met its target.
+// It is served as-is by the API, so its JSON names are camelCase.
+type Streak struct {
+	Current       int       `json:"current"`
+	Longest       int       `json:"longest"`
+	LastMetPeriod time.Time `json:"lastMetPeriod"` // start of the most recent met period; zero if none
+}
+
+// periodValue aggregates the logs of one period according to the habit's AggKind
+func periodValue(agg AggKind, logs []HabitLog) decimal.Decimal {
+	switch agg {
+	case AggCount:
+		return decimal.NewFromInt(int64(len(logs)))
+	case AggBoolean:
+		if len(logs) > 0 {
+			return decimal.NewFromInt(1)
+		}
+		return decimal.Zero
+	case AggAvg:
+		if len(logs) == 0 {
+			return decimal.Zero
+		}
+		qs := make([]decimal.Decimal, len(logs))
+		for i, l := range logs {
+			qs[i] = l.Quantity
+		}
+		return decimal.Avg(qs[0], qs[1:]...)
+	case AggMin, AggMax:
+		if len(logs) == 0 {
+			return decimal.Zero
+		}
+		v := logs[0].Quantity
+		for _, l := range logs[1:] {
+			if agg == AggMin {
+				v = decimal.Min(v, l.Quantity)
+			} else {
+				v = decimal.Max(v, l.Quantity)
+			}
+		}
+		return v
+	case AggLast:
+		// logs are ordered by occurred_at
+		if len(logs) == 0 {
+			return decimal.Zero
+		}
+		return logs[len(logs)-1].Quantity
+	default:
+		sum := decimal.Zero
+		for _, l := range logs {
+			sum = sum.Add(l.Quantity)
+		}
+		return sum
+	}
+}
+
+// DefaultMetGrace is the tolerance applied to "met" checks unless configured
+var DefaultMetGrace = decimal.New(1, -3) // 0.001
+
+// periodMet reports whether a period's logs reach the habit target.
+// A period without logs never counts unless the habit sums quantities; a sum habit
+// only misses it when the (zero) total is below target.
+// Count and boolean values are whole numbers and compare exactly; quantity
+// aggregates may fall short by up to grace and still count as met.
+func periodMet(h *Habit, logs []HabitLog, grace decimal.Decimal) bool {
+	if len(logs) == 0 && h.Agg != AggSum {
+		return false
+	}
+	return valueMet(h.Agg, periodValue(h.Agg, logs), h.TargetPerPeriod, grace)
+}
+
+// valueMet compares an aggregated period value against target, applying
+// grace to quantity aggregates only
+func valueMet(agg AggKind, v, target, grace decimal.Decimal) bool {
+	if agg != AggCount && agg != AggBoolean {
+		v = v.Add(grace)
+	}
+	return v.GreaterThanOrEqual(target)
+}
+
+// eachPeriod walks the habit's periods from the one holding its first log up
+// to the period containing now, calling fn with each period's start and logs.
+// logs must be ordered by occurred_at.
+func eachPeriod(h *Habit, logs []HabitLog, loc *time.Location, now time.Time, fn func(start time.Time, logs []HabitLog)) {
+	if len(logs) == 0 {
+		return
+	}
+
+	nowStart, _ := h.PeriodBounds(now, loc)
+	start, end := h.PeriodBounds(logs[0].OccurredAt, loc)
+
+	i := 0
+	for !start.After(nowStart) {
+		j := i
+		for j < len(logs) && logs[j].OccurredAt.Before(end) {
+			j++
+		}
+		fn(start, logs[i:j])
+		i = j
+
+		start, end = h.PeriodBounds(end, loc)
+	}
+}
+
+// computeStreak walks the habit's periods from its first log up to the period
+// containing now. logs must be ordered by occurred_at. The current period does
+// not break the streak while it is still in progress.
+func computeStreak(h *Habit, logs []HabitLog, loc *time.Location, now time.Time, grace decimal.Decimal) Streak {
+	var s Streak
+	nowStart, _ := h.PeriodBounds(now, loc)
+
+	run := 0
+	eachPeriod(h, logs, loc, now, func(start time.Time, logs []HabitLog) {
+		if periodMet(h, logs, grace) {
+			run++
+			s.LastMetPeriod = start
+			if run > s.Longest {
+				s.Longest = run
+			}
+		} else if !start.Equal(nowStart) {
+			run = 0
+		}
+	})
+	s.Current = run
+	return s
+}
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// logsOf builds logs an hour apart, in order, with the given quantities
+func logsOf(qtys ...string) []HabitLog {
+	base := time.Date(2024, 3, 10, 9, 0, 0, 0, time.UTC)
+	logs := make([]HabitLog, len(qtys))
+	for i, q := range qtys {
+		logs[i] = HabitLog{OccurredAt: base.Add(time.Duration(i) * time.Hour), Quantity: decimal.RequireFromString(q)}
+	}
+	return logs
+}
+
+func TestPeriodValue(t *testing.T) {
+	logs := logsOf("3", "1.5", "4", "2")
+	tests := []struct {
+		agg  AggKind
+		want string
+	}{
+		{AggSum, "10.5"},
+		{AggCount, "4"},
+		{AggBoolean, "1"},
+		{AggAvg, "2.625"},
+		{AggMin, "1.5"},
+		{AggMax, "4"},
+		{AggLast, "2"},
+	}
+	for _, tt := range tests {
+		if got := periodValue(tt.agg, logs); !got.Equal(decimal.RequireFromString(tt.want)) {
+			t.Errorf("periodValue(%s) = %s, want %s", tt.agg, got, tt.want)
+		}
+		if got := periodValue(tt.agg, nil); !got.IsZero() {
+			t.Errorf("periodValue(%s) of no logs = %s, want 0", tt.agg, got)
+		}
+	}
+}
+
+func TestToAggKind(t *testing.T) {
+	for _, s := range []string{"sum", "boolean", "count", "avg", "min", "max", "last"} {
+		if k, err := ToAggKind(s); err != nil || string(k) != s {
+			t.Errorf("ToAggKind(%q) = %q, %v", s, k, err)
+		}
+	}
+	if _, err := ToAggKind("median"); err == nil {
+		t.Error("ToAggKind(median) succeeded")
+	}
+}
+
+func TestPeriodMetGrace(t *testing.T) {
+	grace := decimal.RequireFromString("0.01")
+	tests := []struct {
+		name   string
+		agg    AggKind
+		target string
+		logs   []HabitLog
+		want   bool
+	}{
+		{"sum at target", AggSum, "1", logsOf("0.4", "0.6"), true},
+		{"sum within grace", AggSum, "1", logsOf("0.33", "0.33", "0.33"), true},
+		{"sum beyond grace", AggSum, "1", logsOf("0.98"), false},
+		{"avg within grace", AggAvg, "2", logsOf("1.995"), true},
+		{"count is exact", AggCount, "3", logsOf("1", "1"), false},
+		{"boolean is exact", AggBoolean, "1", logsOf("0"), true},
+		{"zero-target sum without logs", AggSum, "0", nil, true},
+		{"zero-target max without logs", AggMax, "0", nil, false},
+	}
+	for _, tt := range tests {
+		h := &Habit{Agg: tt.agg, TargetPerPeriod: decimal.RequireFromString(tt.target)}
+		if got := periodMet(h, tt.logs, grace); got != tt.want {
+			t.Errorf("%s: periodMet = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSetMetGrace(t *testing.T) {
+	r := NewRepository(nil)
+	r.SetMetGrace(decimal.RequireFromString("-0.5"))
+	if !r.metGrace.Equal(DefaultMetGrace) {
+		t.Errorf("negative grace applied: %s", r.metGrace)
+	}
+	r.SetMetGrace(decimal.Zero)
+	if !r.metGrace.IsZero() {
+		t.Errorf("grace = %s, want 0", r.metGrace)
+	}
+}
@@ -0,0 +1,83 @@
+package models_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/noahjalex/epoch/internal/models"
+	"github.com/noahjalex/epoch/internal/testdb"
+)
+
+func TestStreamLogsForUser(t *testing.T) {
+	repo := testdb.Repo(t)
+	ctx := context.Background()
+	user := testdb.NewUser(t, repo, "")
+	other := testdb.NewUser(t, repo, "")
+	a := testdb.NewHabit(t, repo, user.ID, nil)
+	b := testdb.NewHabit(t, repo, user.ID, nil)
+	base := time.Date(2024, 3, 10, 9, 0, 0, 0, time.UTC)
+
+	var want []int64
+	for i, h := range []*models.Habit{a, b, a, b, a} {
+		want = append(want, testdb.NewLog(t, repo, h.ID, base.Add(time.Duration(i)*time.Hour), 1).ID)
+	}
+	deleted := testdb.NewLog(t, repo, a.ID, base.Add(-time.Hour), 1)
+	_, err := repo.SoftDeleteLog(ctx, deleted.ID)
+	check(t, err)
+	testdb.NewLog(t, repo, testdb.NewHabit(t, repo, other.ID, nil).ID, base, 1)
+
+	var got []int64
+	check(t, repo.StreamLogsForUser(ctx, user.ID, func(l models.HabitLog) error {
+		got = append(got, l.ID)
+		return nil
+	}))
+	if len(got) != len(want) {
+		t.Fatalf("streamed %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("streamed %v, want %v oldest first", got, want)
+		}
+	}
+
+	var rows int
+	check(t, repo.EachLogExportRow(ctx, user.ID, func(models.LogExportRow) error {
+		rows++
+		return nil
+	}))
+	if rows != len(want) {
+		t.Errorf("export rows = %d, want %d", rows, len(want))
+	}
+}
+
+func TestStreamLogsForUserStopsOnError(t *testing.T) {
+	repo := testdb.Repo(t)
+	ctx := context.Background()
+	user := testdb.NewUser(t, repo, "")
+	h := testdb.NewHabit(t, repo, user.ID, nil)
+	base := time.Date(2024, 3, 10, 9, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		testdb.NewLog(t, repo, h.ID, base.Add(time.Duration(i)*time.Hour), 1)
+	}
+
+	stop := errors.New("stop")
+	calls := 0
+	err := repo.StreamLogsForUser(ctx, user.ID, func(models.HabitLog) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Errorf("StreamLogsForUser: err %v after %d calls, want %v after 1", err, calls, stop)
+	}
+
+	calls = 0
+	err = repo.EachLogExportRow(ctx, user.ID, func(models.LogExportRow) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Errorf("EachLogExportRow: err %v after %d calls, want %v after 1", err, calls, stop)
+	}
+}
@@ -0,0 +1,104 @@
+package models_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/noahjalex/epoch/internal/models"
+	"github.com/noahjalex/epoch/internal/testdb"
+	"github.com/shopspring/decimal"
+)
+
+func TestTodayProgressMatchesPeriodBounds(t *testing.T) {
+	repo := testdb.Repo(t)
+	ctx := context.Background()
+	user := testdb.NewUser(t, repo, "America/New_York")
+
+	// Each schedule's window is computed in SQL; it must agree with the Go bounds
+	habits := []*models.Habit{
+		testdb.NewHabit(t, repo, user.ID, nil),
+		testdb.NewHabit(t, repo, user.ID, func(h *models.Habit) { h.Period = models.PeriodWeekly; h.WeekStartDOW = 0 }),
+		testdb.NewHabit(t, repo, user.ID, func(h *models.Habit) { h.Period = models.PeriodWeekly; h.WeekStartDOW = 3 }),
+		testdb.NewHabit(t, repo, user.ID, func(h *models.Habit) { h.Period = models.PeriodMonthly }),
+		testdb.NewHabit(t, repo, user.ID, func(h *models.Habit) {
+			h.Period = models.PeriodRolling
+			h.RollingLenDays = sql.NullInt32{Int32: 3, Valid: true}
+			h.AnchorDate = time.Now().AddDate(0, 0, 1).UTC().Truncate(24 * time.Hour) // after today
+		}),
+		testdb.NewHabit(t, repo, user.ID, func(h *models.Habit) { h.TZOverride = sql.NullString{String: "Asia/Kolkata", Valid: true} }),
+	}
+
+	rows, err := repo.TodayProgress(ctx, user.ID)
+	check(t, err)
+	got := make(map[int64]models.TodayProgress, len(rows))
+	for _, p := range rows {
+		got[p.HabitID] = p
+	}
+	now := time.Now()
+	for _, h := range habits {
+		start, end := h.PeriodBounds(now, h.Location(user.TZ))
+		p, ok := got[h.ID]
+		if !ok {
+			t.Errorf("habit %d (%s) missing", h.ID, h.Period)
+			continue
+		}
+		if !p.PeriodStart.Equal(start) || !p.PeriodEnd.Equal(end) {
+			t.Errorf("habit %d (%s): window %v..%v, want %v..%v", h.ID, h.Period, p.PeriodStart, p.PeriodEnd, start, end)
+		}
+	}
+}
+
+func TestTodayProgressAggregates(t *testing.T) {
+	repo := testdb.Repo(t)
+	ctx := context.Background()
+	user := testdb.NewUser(t, repo, "")
+	other := testdb.NewUser(t, repo, "")
+	now := time.Now()
+	start, _ := (&models.Habit{Period: models.PeriodDaily}).PeriodBounds(now, time.UTC)
+	earlier := start.Add(now.Sub(start) / 2)
+
+	target := func(agg models.AggKind, n int64) func(*models.Habit) {
+		return func(h *models.Habit) { h.Agg = agg; h.TargetPerPeriod = decimal.NewFromInt(n) }
+	}
+	sum := testdb.NewHabit(t, repo, user.ID, target(models.AggSum, 5))
+	count := testdb.NewHabit(t, repo, user.ID, target(models.AggCount, 2))
+	last := testdb.NewHabit(t, repo, user.ID, target(models.AggLast, 3))
+	empty := testdb.NewHabit(t, repo, user.ID, target(models.AggMin, 1))
+	for _, h := range []*models.Habit{sum, count, last} {
+		testdb.NewLog(t, repo, h.ID, earlier, 4)
+		testdb.NewLog(t, repo, h.ID, now, 2)
+		testdb.NewLog(t, repo, h.ID, start.Add(-time.Minute), 9) // yesterday
+	}
+	deleted := testdb.NewLog(t, repo, sum.ID, now, 7)
+	_, err := repo.SoftDeleteLog(ctx, deleted.ID)
+	check(t, err)
+	archived := testdb.NewHabit(t, repo, user.ID, nil)
+	check(t, repo.DeactivateHabit(ctx, archived.ID))
+	testdb.NewHabit(t, repo, other.ID, nil)
+
+	rows, err := repo.TodayProgress(ctx, user.ID)
+	check(t, err)
+	want := []struct {
+		id       int64
+		value    int64
+		logCount int
+		met      bool
+	}{
+		// Newest habit first
+		{empty.ID, 0, 0, false},
+		{last.ID, 2, 2, false},
+		{count.ID, 2, 2, true},
+		{sum.ID, 6, 2, true},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("%d rows, want %d: %+v", len(rows), len(want), rows)
+	}
+	for i, w := range want {
+		p := rows[i]
+		if p.HabitID != w.id || !p.Value.Equal(decimal.NewFromInt(w.value)) || p.LogCount != w.logCount || p.Met != w.met {
+			t.Errorf("row %d = %+v, want habit %d value %d over %d logs, met %v", i, p, w.id, w.value, w.logCount, w.met)
+		}
+	}
+}
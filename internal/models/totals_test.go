@@ -0,0 +1,45 @@
+package models_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/noahjalex/epoch/internal/models"
+	"github.com/noahjalex/epoch/internal/testdb"
+)
+
+func TestTotalsByDayOfWeek(t *testing.T) {
+	repo := testdb.Repo(t)
+	ctx := context.Background()
+	user := testdb.NewUser(t, repo, "Asia/Tokyo")
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+
+	h := testdb.NewHabit(t, repo, user.ID, nil)
+	testdb.NewLog(t, repo, h.ID, time.Date(2024, 3, 10, 20, 0, 0, 0, time.UTC), 2) // Sunday in UTC, Monday in Tokyo
+	testdb.NewLog(t, repo, h.ID, time.Date(2024, 3, 11, 10, 0, 0, 0, time.UTC), 3) // Monday in both
+	testdb.NewLog(t, repo, h.ID, time.Date(2024, 3, 9, 16, 0, 0, 0, time.UTC), 4)  // Saturday in UTC, Sunday in Tokyo
+	testdb.NewLog(t, repo, h.ID, time.Date(2024, 2, 26, 10, 0, 0, 0, time.UTC), 9) // before the range
+
+	totals, err := repo.TotalsByDayOfWeek(ctx, h.ID, from, to)
+	check(t, err)
+	want := [7]int64{time.Sunday: 4, time.Monday: 5}
+	for dow, total := range totals {
+		if total.IntPart() != want[dow] {
+			t.Errorf("%s total = %s, want %d", time.Weekday(dow), total, want[dow])
+		}
+	}
+
+	// A habit's own timezone wins over the user's
+	ny := testdb.NewHabit(t, repo, user.ID, func(h *models.Habit) {
+		h.TZOverride = sql.NullString{String: "America/New_York", Valid: true}
+	})
+	testdb.NewLog(t, repo, ny.ID, time.Date(2024, 3, 11, 3, 0, 0, 0, time.UTC), 1) // Sunday evening in New York
+	totals, err = repo.TotalsByDayOfWeek(ctx, ny.ID, from, to)
+	check(t, err)
+	if totals[time.Sunday].IntPart() != 1 {
+		t.Errorf("override totals = %v, want 1 on Sunday", totals)
+	}
+}
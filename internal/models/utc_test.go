@@ -0,0 +1,46 @@
+package models_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/noahjalex/epoch/internal/models"
+	"github.com/noahjalex/epoch/internal/testdb"
+	"github.com/shopspring/decimal"
+)
+
+func TestLogTimesStoredInUTC(t *testing.T) {
+	db := testdb.Open(t)
+	repo := testdb.Repo(t)
+	ctx := context.Background()
+	user := testdb.NewUser(t, repo, "")
+	h := testdb.NewHabit(t, repo, user.ID, nil)
+	ist := time.FixedZone("IST", 5*3600+1800)
+
+	// storedUTC reads the log's occurred_at as UTC wall time, independent of
+	// the session timezone
+	storedUTC := func(id int64) string {
+		t.Helper()
+		var s string
+		check(t, db.GetContext(ctx, &s, `SELECT to_char(occurred_at AT TIME ZONE 'UTC', 'YYYY-MM-DD HH24:MI') FROM habit_log WHERE id = $1`, id))
+		return s
+	}
+
+	created := time.Date(2024, 3, 10, 9, 0, 0, 0, ist)
+	l, err := repo.InsertLog(ctx, &models.HabitLog{HabitID: h.ID, OccurredAt: created, Quantity: decimal.NewFromInt(1)})
+	check(t, err)
+	if got := storedUTC(l.ID); got != "2024-03-10 03:30" {
+		t.Errorf("inserted log stored at %s UTC, want 2024-03-10 03:30", got)
+	}
+
+	updated := time.Date(2024, 3, 11, 1, 0, 0, 0, ist)
+	out, err := repo.UpdateLog(ctx, user.ID, &models.HabitLog{ID: l.ID, HabitID: h.ID, OccurredAt: updated, Quantity: decimal.NewFromInt(2)})
+	check(t, err)
+	if !out.OccurredAt.Equal(updated) {
+		t.Errorf("UpdateLog returned %v, want the instant %v", out.OccurredAt, updated)
+	}
+	if got := storedUTC(l.ID); got != "2024-03-10 19:30" {
+		t.Errorf("updated log stored at %s UTC, want 2024-03-10 19:30", got)
+	}
+}
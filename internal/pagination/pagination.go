@@ -0,0 +1,32 @@
+package pagination
+
+import (
+	"github.com/noahjalex/epoch/internal/utils"
+)
+
+// Limits holds the default page size and the hard ceiling for list endpoints
+type Limits struct {
+	Default int
+	Max     int
+}
+
+// Page is a parsed limit/offset window
+type Page struct {
+	Limit  int
+	Offset int
+}
+
+// Parse reads the limit and offset query parameters from the form.
+// A missing limit falls back to the default; non-integer, negative,
+// zero or over-ceiling limits and negative offsets are errors.
+func Parse(fx *utils.Form, lim Limits) (Page, error) {
+	p := Page{Limit: lim.Default}
+	if fx.Has("limit") {
+		p.Limit = int(fx.Int64("limit", utils.MinInt(1), utils.MaxInt(int64(lim.Max))))
+	}
+	p.Offset = int(fx.Int64("offset", utils.MinInt(0)))
+	if err := fx.Err(); err != nil {
+		return Page{}, err
+	}
+	return p, nil
+}
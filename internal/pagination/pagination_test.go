@@ -0,0 +1,33 @@
+package pagination
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/noahjalex/epoch/internal/utils"
+)
+
+func TestParse(t *testing.T) {
+	lim := Limits{Default: 50, Max: 200}
+	tests := []struct {
+		query   string
+		want    Page
+		wantErr bool
+	}{
+		{"", Page{Limit: 50}, false},
+		{"limit=10&offset=20", Page{Limit: 10, Offset: 20}, false},
+		{"limit=200", Page{Limit: 200}, false},
+		{"limit=201", Page{}, true},
+		{"limit=0", Page{}, true},
+		{"limit=-1", Page{}, true},
+		{"limit=ten", Page{}, true},
+		{"offset=-5", Page{}, true},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/api/logs?"+tt.query, nil)
+		got, err := Parse(utils.New(r), lim)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("Parse(%q) = %+v, %v; want %+v, error %v", tt.query, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package tzcache
+
+import (
+	"sync"
+	"time"
+)
+
+// Cache holds loaded *time.Location values keyed by IANA zone name.
+// It is safe for concurrent use.
+type Cache struct {
+	mu   sync.RWMutex
+	locs map[string]*time.Location
+}
+
+// New creates an empty location cache
+func New() *Cache {
+	return &Cache{locs: make(map[string]*time.Location)}
+}
+
+// Load returns the cached location for name, loading it on first use.
+// Invalid names are not cached and return the time.LoadLocation error.
+func (c *Cache) Load(name string) (*time.Location, error) {
+	c.mu.RLock()
+	loc, ok := c.locs[name]
+	c.mu.RUnlock()
+	if ok {
+		return loc, nil
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	// Another goroutine may have loaded it meanwhile; keep the first pointer
+	if existing, ok := c.locs[name]; ok {
+		loc = existing
+	} else {
+		c.locs[name] = loc
+	}
+	c.mu.Unlock()
+
+	return loc, nil
+}
+
+// Len returns the number of cached locations
+func (c *Cache) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.locs)
+}
+
+var defaultCache = New()
+
+// Load loads a location through the package-level cache
+func Load(name string) (*time.Location, error) {
+	return defaultCache.Load(name)
+}
@@ -0,0 +1,62 @@
+package tzcache
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCacheLoad(t *testing.T) {
+	c := New()
+
+	a, err := c.Load("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := c.Load("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a != b {
+		t.Error("second load returned a different *time.Location")
+	}
+	if a.String() != "Europe/Berlin" {
+		t.Errorf("loaded %q, want Europe/Berlin", a)
+	}
+
+	if _, err := c.Load("Not/AZone"); err == nil {
+		t.Error("invalid zone loaded")
+	}
+	if c.Len() != 1 {
+		t.Errorf("Len = %d, want 1 (invalid names are not cached)", c.Len())
+	}
+}
+
+func TestCacheLoadConcurrent(t *testing.T) {
+	c := New()
+	var wg sync.WaitGroup
+	results := make(chan *time.Location, 16)
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			loc, err := c.Load("America/New_York")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			results <- loc
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	var first *time.Location
+	for loc := range results {
+		if first == nil {
+			first = loc
+		} else if loc != first {
+			t.Fatal("concurrent loads returned different locations")
+		}
+	}
+}
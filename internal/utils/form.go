@@ -5,43 +5,85 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"mime"
 	"net/http"
 	"net/url"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
+	"github.com/noahjalex/epoch/internal/tzcache"
 	"github.com/shopspring/decimal"
 )
 
 type Form struct {
-	r       *http.Request
-	form    url.Values
-	jsonMap map[string]any
-	errs    []string
+	r         *http.Request
+	form      url.Values
+	jsonMap   map[string]any
+	errs      []string
+	fieldErrs map[string]string
+}
+
+// DefaultMaxBodyBytes is the default request body limit for New
+const DefaultMaxBodyBytes int64 = 1 << 20 // 1 MiB
+
+// MaxBodyBytes caps how much of a request body New reads; it also bounds the
+// memory multipart parsing may use. Set it with SetMaxBodyBytes.
+var MaxBodyBytes = DefaultMaxBodyBytes
+
+// SetMaxBodyBytes sets the body limit, falling back to the default when n <= 0
+func SetMaxBodyBytes(n int64) {
+	if n <= 0 {
+		n = DefaultMaxBodyBytes
+	}
+	MaxBodyBytes = n
 }
 
 // New parses the request body once.
-// Supports form-encoded and application/json.
+// Supports form-encoded, multipart and application/json. Bodies over
+// MaxBodyBytes are rejected with an error on the "body" field.
 func New(r *http.Request) *Form {
 	f := &Form{r: r}
+	if r.Body != nil {
+		r.Body = http.MaxBytesReader(nil, r.Body, MaxBodyBytes)
+	}
 
+	var err error
 	ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
 	switch ct {
 	case "application/json":
 		// Read-once body
-		b, _ := io.ReadAll(r.Body)
+		var b []byte
+		b, err = io.ReadAll(r.Body)
 		_ = r.Body.Close()
-		_ = json.Unmarshal(b, &f.jsonMap) // best-effort
+		if err == nil {
+			_ = json.Unmarshal(b, &f.jsonMap) // best-effort
+		}
+	case "multipart/form-data":
+		err = r.ParseMultipartForm(MaxBodyBytes)
+		f.form = r.Form
 	default:
-		// Parse form (also handles multipart)
-		_ = r.ParseForm()
+		err = r.ParseForm()
 		f.form = r.Form
 	}
+
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		f.addErr("body", fmt.Sprintf("exceeds the %d byte limit", tooLarge.Limit))
+	}
 	return f
 }
 
+// FromJSON wraps an already-decoded JSON object, for bodies a handler has to
+// decode itself, such as an array of entries or an optional body. Values are
+// read and validated the same way as in a Form from New.
+func FromJSON(m map[string]any) *Form {
+	return &Form{jsonMap: m}
+}
+
 func (f *Form) Err() error {
 	if len(f.errs) == 0 {
 		return nil
@@ -49,8 +91,20 @@ func (f *Form) Err() error {
 	return errors.New("invalid input: " + strings.Join(f.errs, "; "))
 }
 
+// FieldErrors returns the first validation message for each failed field,
+// keyed by field name. It is nil when there are no errors.
+func (f *Form) FieldErrors() map[string]string {
+	return f.fieldErrs
+}
+
 func (f *Form) addErr(field, msg string) {
 	f.errs = append(f.errs, fmt.Sprintf("%s: %s", field, msg))
+	if f.fieldErrs == nil {
+		f.fieldErrs = make(map[string]string)
+	}
+	if _, ok := f.fieldErrs[field]; !ok {
+		f.fieldErrs[field] = msg
+	}
 }
 
 // ---------- raw value retrieval (stringly) ----------
@@ -88,6 +142,12 @@ func (f *Form) raw(name string) (string, bool) {
 	return v[0], true
 }
 
+// Has reports whether the field was supplied with a non-blank value
+func (f *Form) Has(name string) bool {
+	raw, ok := f.raw(name)
+	return ok && strings.TrimSpace(raw) != ""
+}
+
 // ---------- options (lightweight validators) ----------
 
 type Option func(*opts)
@@ -98,6 +158,15 @@ type opts struct {
 	minF     *float64
 	maxF     *float64
 	enum     []string // for strings
+	tz       bool     // for strings: must name a loadable timezone
+	minLen   *int     // for strings: minimum length in runes
+	maxLen   *int     // for strings: maximum length in runes
+	match    []matcher
+}
+
+type matcher struct {
+	re  *regexp.Regexp
+	msg string
 }
 
 func Required() Option          { return func(o *opts) { o.required = true } }
@@ -109,6 +178,28 @@ func OneOf(values ...string) Option {
 	return func(o *opts) { o.enum = append([]string(nil), values...) }
 }
 
+// MinLen and MaxLen bound a string's length, counted in runes after trimming
+func MinLen(n int) Option { return func(o *opts) { o.minLen = &n } }
+func MaxLen(n int) Option { return func(o *opts) { o.maxLen = &n } }
+
+// Match requires a string to match re, recording msg when it does not
+func Match(re *regexp.Regexp, msg string) Option {
+	return func(o *opts) { o.match = append(o.match, matcher{re: re, msg: msg}) }
+}
+
+// Timezone requires a string to be a valid IANA timezone name
+func Timezone() Option { return func(o *opts) { o.tz = true } }
+
+// ValidTimezone reports whether s is an IANA timezone name time.LoadLocation
+// accepts. "Local" is rejected since it depends on the server.
+func ValidTimezone(s string) bool {
+	if s == "" || s == "Local" {
+		return false
+	}
+	_, err := tzcache.Load(s)
+	return err == nil
+}
+
 func applyOptions(os []Option) *opts {
 	o := &opts{}
 	for _, fn := range os {
@@ -141,6 +232,19 @@ func (f *Form) String(name string, opt ...Option) string {
 			f.addErr(name, "must be one of: "+strings.Join(o.enum, ", "))
 		}
 	}
+	if n := utf8.RuneCountInString(raw); o.minLen != nil && n < *o.minLen {
+		f.addErr(name, fmt.Sprintf("must be at least %d characters", *o.minLen))
+	} else if o.maxLen != nil && n > *o.maxLen {
+		f.addErr(name, fmt.Sprintf("must be at most %d characters", *o.maxLen))
+	}
+	for _, m := range o.match {
+		if !m.re.MatchString(raw) {
+			f.addErr(name, m.msg)
+		}
+	}
+	if o.tz && !ValidTimezone(raw) {
+		f.addErr(name, "must be a valid timezone such as America/Toronto")
+	}
 	return raw
 }
 
@@ -199,7 +303,7 @@ func (f *Form) Float64(name string, opt ...Option) float64 {
 		return 0
 	}
 	x, err := strconv.ParseFloat(raw, 64)
-	if err != nil {
+	if err != nil || math.IsNaN(x) || math.IsInf(x, 0) {
 		f.addErr(name, "must be a number")
 		return 0
 	}
@@ -221,8 +325,9 @@ func (f *Form) Decimal(name string, opt ...Option) decimal.Decimal {
 		}
 		return decimal.Zero
 	}
+	// NaN and Inf parse as floats but are not numbers decimal can hold
 	x, err := strconv.ParseFloat(raw, 64)
-	if err != nil {
+	if err != nil || math.IsNaN(x) || math.IsInf(x, 0) {
 		f.addErr(name, "must be a number")
 		return decimal.Zero
 	}
@@ -274,3 +379,47 @@ func (f *Form) DateTimeLocal(name string, opt ...Option) time.Time {
 	f.addErr(name, "must be datetime-local (e.g., 2006-01-02T15:04[:05])")
 	return time.Time{}
 }
+
+// Time parses a value with layout in loc. A nil loc means UTC.
+func (f *Form) Time(name string, layout string, loc *time.Location, opt ...Option) time.Time {
+	o := applyOptions(opt)
+	raw, ok := f.raw(name)
+	raw = strings.TrimSpace(raw)
+	if !ok || raw == "" {
+		if o.required {
+			f.addErr(name, "is required")
+		}
+		return time.Time{}
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+	t, err := time.ParseInLocation(layout, raw, loc)
+	if err != nil {
+		f.addErr(name, "must be a time formatted as "+layout)
+		return time.Time{}
+	}
+	return t
+}
+
+// Date parses a date-only value (2006-01-02) as midnight in loc.
+func (f *Form) Date(name string, loc *time.Location, opt ...Option) time.Time {
+	o := applyOptions(opt)
+	raw, ok := f.raw(name)
+	raw = strings.TrimSpace(raw)
+	if !ok || raw == "" {
+		if o.required {
+			f.addErr(name, "is required")
+		}
+		return time.Time{}
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+	t, err := time.ParseInLocation(time.DateOnly, raw, loc)
+	if err != nil {
+		f.addErr(name, "must be a date (YYYY-MM-DD)")
+		return time.Time{}
+	}
+	return t
+}
@@ -0,0 +1,188 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func formRequest(values url.Values) *http.Request {
+	r := httptest.NewRequest("POST", "/", strings.NewReader(values.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return r
+}
+
+func jsonRequest(body string) *http.Request {
+	r := httptest.NewRequest("POST", "/", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	return r
+}
+
+func TestFieldErrors(t *testing.T) {
+	fx := New(formRequest(url.Values{"age": {"abc"}, "name": {"  "}}))
+	fx.String("name", Required())
+	fx.Int64("age", Required())
+	fx.Int64("age", MinInt(1)) // a second failure keeps the first message
+	fx.String("nickname")      // optional and absent
+
+	want := map[string]string{"name": "is required", "age": "must be an integer"}
+	got := fx.FieldErrors()
+	if len(got) != len(want) {
+		t.Fatalf("FieldErrors = %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("FieldErrors[%q] = %q, want %q", k, got[k], v)
+		}
+	}
+	if err := fx.Err(); err == nil || !strings.Contains(err.Error(), "name: is required") {
+		t.Errorf("Err = %v, want it to list the field errors", err)
+	}
+
+	ok := New(formRequest(url.Values{"name": {"x"}}))
+	ok.String("name", Required())
+	if ok.FieldErrors() != nil || ok.Err() != nil {
+		t.Errorf("valid form: FieldErrors %v, Err %v", ok.FieldErrors(), ok.Err())
+	}
+}
+
+func TestBodyLimit(t *testing.T) {
+	defer SetMaxBodyBytes(MaxBodyBytes)
+	SetMaxBodyBytes(32)
+
+	big := New(jsonRequest(`{"name":"` + strings.Repeat("x", 64) + `"}`))
+	if got := big.FieldErrors()["body"]; got != "exceeds the 32 byte limit" {
+		t.Errorf("oversized JSON: body error = %q", got)
+	}
+	if big.Err() == nil {
+		t.Error("oversized JSON: Err is nil")
+	}
+
+	form := New(formRequest(url.Values{"note": {strings.Repeat("y", 64)}}))
+	if _, ok := form.FieldErrors()["body"]; !ok {
+		t.Error("oversized form: no body error")
+	}
+
+	small := New(jsonRequest(`{"name":"ok"}`))
+	if small.String("name") != "ok" || small.Err() != nil {
+		t.Errorf("small JSON: name %q, Err %v", small.String("name"), small.Err())
+	}
+
+	SetMaxBodyBytes(0)
+	if MaxBodyBytes != DefaultMaxBodyBytes {
+		t.Errorf("SetMaxBodyBytes(0) left %d, want the default", MaxBodyBytes)
+	}
+}
+
+func TestTimeAndDate(t *testing.T) {
+	ist := time.FixedZone("IST", 5*3600+1800)
+	fx := New(jsonRequest(`{"at":"2024-03-10T09:30","stamp":"2024-03-10T09:30:15Z","day":"2024-03-10","bad":"10/03/2024"}`))
+
+	if got, want := fx.Time("at", "2006-01-02T15:04", ist), time.Date(2024, 3, 10, 9, 30, 0, 0, ist); !got.Equal(want) {
+		t.Errorf("Time(at) = %v, want %v", got, want)
+	}
+	if got, want := fx.Time("stamp", time.RFC3339, ist), time.Date(2024, 3, 10, 9, 30, 15, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("Time(stamp) = %v, want %v", got, want)
+	}
+	if got, want := fx.Date("day", ist), time.Date(2024, 3, 10, 0, 0, 0, 0, ist); !got.Equal(want) {
+		t.Errorf("Date(day) = %v, want %v", got, want)
+	}
+	if fx.Err() != nil {
+		t.Fatalf("valid times: %v", fx.Err())
+	}
+
+	if got := fx.Date("bad", nil); !got.IsZero() {
+		t.Errorf("Date(bad) = %v, want zero", got)
+	}
+	fx.Time("missing", time.RFC3339, nil, Required())
+	fx.Date("absent", nil) // optional
+	want := map[string]string{"bad": "must be a date (YYYY-MM-DD)", "missing": "is required"}
+	if got := fx.FieldErrors(); len(got) != len(want) || got["bad"] != want["bad"] || got["missing"] != want["missing"] {
+		t.Errorf("FieldErrors = %v, want %v", got, want)
+	}
+}
+
+func TestFromJSON(t *testing.T) {
+	fx := FromJSON(map[string]any{"habitId": "12", "qty": 2.5, "date": "2024-03-10T09:30", "bad": "soon"})
+	if got := fx.Int64("habitId", Required()); got != 12 {
+		t.Errorf("Int64(habitId) = %d, want 12", got)
+	}
+	if got := fx.Float64("qty"); got != 2.5 {
+		t.Errorf("Float64(qty) = %v, want 2.5", got)
+	}
+	if got, want := fx.Time("date", "2006-01-02T15:04", nil), time.Date(2024, 3, 10, 9, 30, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("Time(date) = %v, want %v", got, want)
+	}
+	if fx.Err() != nil {
+		t.Fatalf("valid values: %v", fx.Err())
+	}
+
+	fx.Time("bad", "2006-01-02T15:04", nil)
+	if got := fx.FieldErrors()["bad"]; got != "must be a time formatted as 2006-01-02T15:04" {
+		t.Errorf("bad time error = %q", got)
+	}
+
+	// A nil object has no fields
+	empty := FromJSON(nil)
+	empty.Time("date", time.RFC3339, nil, Required())
+	if empty.Err() == nil {
+		t.Error("nil object: missing required field accepted")
+	}
+}
+
+func TestStringLength(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"ab", "must be at least 3 characters"},
+		{"abc", ""},
+		{"  abc  ", ""}, // counted after trimming
+		{"abcde", ""},
+		{"abcdef", "must be at most 5 characters"},
+		{"héllo", ""}, // five runes, six bytes
+		{"日本語", ""},
+		{"日本", "must be at least 3 characters"},
+	}
+	for _, tt := range tests {
+		fx := New(formRequest(url.Values{"name": {tt.value}}))
+		fx.String("name", MinLen(3), MaxLen(5))
+		if got := fx.FieldErrors()["name"]; got != tt.want {
+			t.Errorf("String(%q) error = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	digits := regexp.MustCompile(`^[0-9]+$`)
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"123", ""},
+		{"  123 ", ""}, // matched after trimming
+		{"12a", "must be digits"},
+		{"", ""}, // optional and absent
+	}
+	for _, tt := range tests {
+		fx := New(formRequest(url.Values{"pin": {tt.value}}))
+		fx.String("pin", Match(digits, "must be digits"))
+		if got := fx.FieldErrors()["pin"]; got != tt.want {
+			t.Errorf("String(%q) error = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+
+	// Every failing pattern reports, the first message is kept per field
+	fx := New(formRequest(url.Values{"code": {"ab"}}))
+	fx.String("code", Match(digits, "must be digits"), Match(regexp.MustCompile(`^.{4}$`), "must be 4 characters"))
+	if err := fx.Err(); err == nil || !strings.Contains(err.Error(), "must be 4 characters") {
+		t.Errorf("Err = %v, want both mismatches", err)
+	}
+	if got := fx.FieldErrors()["code"]; got != "must be digits" {
+		t.Errorf("FieldErrors[code] = %q, want the first message", got)
+	}
+}
@@ -0,0 +1,6 @@
+package version
+
+// Version is the build version, set at build time with:
+//
+//	go build -ldflags "-X github.com/noahjalex/epoch/internal/version.Version=v1.2.3" ./cmd/web
+var Version = "dev"
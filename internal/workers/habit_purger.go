@@ -0,0 +1,41 @@
+package workers
+
+import (
+	"context"
+	"time"
+
+	"github.com/noahjalex/epoch/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// StartHabitPurger permanently deletes habits archived more than retention
+// ago, with their logs, once at start and then every interval, in a
+// background goroutine that stops when ctx is cancelled. A non-positive
+// interval or retention disables the purger.
+func StartHabitPurger(ctx context.Context, repo *models.Repo, interval, retention time.Duration, log *logrus.Logger) {
+	if interval <= 0 || retention <= 0 {
+		log.Info("Habit purger disabled")
+		return
+	}
+
+	purge := func() {
+		n, err := repo.PurgeArchivedHabits(ctx, time.Now().Add(-retention))
+		if err != nil {
+			if ctx.Err() == nil {
+				log.WithError(err).Error("Failed to purge archived habits")
+			}
+			return
+		}
+		log.WithFields(logrus.Fields{
+			"component": "habit_purger",
+			"purged":    n,
+		}).Info("Purged archived habits")
+	}
+
+	startTicker(ctx, interval, purge)
+
+	log.WithFields(logrus.Fields{
+		"interval":  interval,
+		"retention": retention,
+	}).Info("Habit purger started")
+}
@@ -0,0 +1,40 @@
+package workers
+
+import (
+	"context"
+	"time"
+
+	"github.com/noahjalex/epoch/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// StartLogPurger permanently removes logs soft-deleted more than retention
+// ago, once at start and then every interval, in a background goroutine that
+// stops when ctx is cancelled. A non-positive interval disables the purger.
+func StartLogPurger(ctx context.Context, repo *models.Repo, interval, retention time.Duration, log *logrus.Logger) {
+	if interval <= 0 {
+		log.Info("Log purger disabled")
+		return
+	}
+
+	purge := func() {
+		n, err := repo.PurgeDeletedLogs(ctx, time.Now().Add(-retention))
+		if err != nil {
+			if ctx.Err() == nil {
+				log.WithError(err).Error("Failed to purge deleted logs")
+			}
+			return
+		}
+		log.WithFields(logrus.Fields{
+			"component": "log_purger",
+			"purged":    n,
+		}).Info("Purged deleted logs")
+	}
+
+	startTicker(ctx, interval, purge)
+
+	log.WithFields(logrus.Fields{
+		"interval":  interval,
+		"retention": retention,
+	}).Info("Log purger started")
+}
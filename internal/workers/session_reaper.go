@@ -0,0 +1,37 @@
+package workers
+
+import (
+	"context"
+	"time"
+
+	"github.com/noahjalex/epoch/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// StartSessionReaper deletes expired sessions once at start and then every
+// interval, in a background goroutine that stops when ctx is cancelled.
+// A non-positive interval disables the reaper.
+func StartSessionReaper(ctx context.Context, repo *models.Repo, interval time.Duration, log *logrus.Logger) {
+	if interval <= 0 {
+		log.Info("Session reaper disabled")
+		return
+	}
+
+	reap := func() {
+		n, err := repo.DeleteExpiredSessions(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.WithError(err).Error("Failed to delete expired sessions")
+			}
+			return
+		}
+		log.WithFields(logrus.Fields{
+			"component": "session_reaper",
+			"deleted":   n,
+		}).Info("Deleted expired sessions")
+	}
+
+	startTicker(ctx, interval, reap)
+
+	log.WithField("interval", interval).Info("Session reaper started")
+}
@@ -0,0 +1,25 @@
+package workers
+
+import (
+	"context"
+	"time"
+)
+
+// startTicker runs fn once right away and then every interval, in a
+// background goroutine that stops when ctx is cancelled
+func startTicker(ctx context.Context, interval time.Duration, fn func()) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		fn()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				fn()
+			}
+		}
+	}()
+}
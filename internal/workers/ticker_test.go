@@ -0,0 +1,52 @@
+package workers
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestStartTicker(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var runs atomic.Int32
+	ran := make(chan struct{}, 16)
+	startTicker(ctx, 5*time.Millisecond, func() {
+		runs.Add(1)
+		ran <- struct{}{}
+	})
+
+	// Once right away, then on each tick
+	for i := 0; i < 3; i++ {
+		select {
+		case <-ran:
+		case <-time.After(time.Second):
+			t.Fatalf("run %d did not happen", i+1)
+		}
+	}
+
+	cancel()
+	time.Sleep(20 * time.Millisecond)
+	stopped := runs.Load()
+	time.Sleep(30 * time.Millisecond)
+	if got := runs.Load(); got != stopped {
+		t.Errorf("ran %d more times after cancel", got-stopped)
+	}
+}
+
+func TestStartTickerRunsImmediately(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ran := make(chan struct{}, 1)
+	startTicker(ctx, time.Hour, func() {
+		select {
+		case ran <- struct{}{}:
+		default:
+		}
+	})
+	select {
+	case <-ran:
+	case <-time.After(time.Second):
+		t.Fatal("fn did not run before the first tick")
+	}
+}
@@ -0,0 +1,12 @@
+-- =========================
+-- Revert: per-period log policy
+-- =========================
+\set ON_ERROR_STOP on
+\echo '==> Dropping habit.log_policy'
+BEGIN;
+
+ALTER TABLE public.habit DROP COLUMN IF EXISTS log_policy;
+
+COMMIT;
+
+\echo '==> Done.'
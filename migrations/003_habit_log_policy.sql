@@ -0,0 +1,14 @@
+-- =========================
+-- Per-period log policy
+-- =========================
+\set ON_ERROR_STOP on
+\echo '==> Adding habit.log_policy'
+BEGIN;
+
+ALTER TABLE public.habit
+  ADD COLUMN IF NOT EXISTS log_policy TEXT NOT NULL DEFAULT 'multiple'
+  CHECK (log_policy IN ('multiple','single','replace'));
+
+COMMIT;
+
+\echo '==> Done.'
@@ -0,0 +1,12 @@
+-- =========================
+-- Revert: per-user preferences
+-- =========================
+\set ON_ERROR_STOP on
+\echo '==> Dropping app_user.preferences'
+BEGIN;
+
+ALTER TABLE public.app_user DROP COLUMN IF EXISTS preferences;
+
+COMMIT;
+
+\echo '==> Done.'
@@ -0,0 +1,13 @@
+-- =========================
+-- Per-user preferences
+-- =========================
+\set ON_ERROR_STOP on
+\echo '==> Adding app_user.preferences'
+BEGIN;
+
+ALTER TABLE public.app_user
+  ADD COLUMN IF NOT EXISTS preferences JSONB NOT NULL DEFAULT '{}'::jsonb;
+
+COMMIT;
+
+\echo '==> Done.'
@@ -0,0 +1,18 @@
+-- =========================
+-- avg/min/max/last aggregation
+-- =========================
+-- This migration cannot be rolled back, so it has no .down.sql: Postgres
+-- cannot drop a value from an enum, and habits may already use these ones.
+-- Rollback refuses to go past it.
+\set ON_ERROR_STOP on
+\echo '==> Extending agg_kind with avg, min, max, last'
+BEGIN;
+
+ALTER TYPE agg_kind ADD VALUE IF NOT EXISTS 'avg';
+ALTER TYPE agg_kind ADD VALUE IF NOT EXISTS 'min';
+ALTER TYPE agg_kind ADD VALUE IF NOT EXISTS 'max';
+ALTER TYPE agg_kind ADD VALUE IF NOT EXISTS 'last';
+
+COMMIT;
+
+\echo '==> Done.'
@@ -0,0 +1,16 @@
+-- =========================
+-- Revert: soft-deleted logs
+-- =========================
+\set ON_ERROR_STOP on
+\echo '==> Dropping habit_log.deleted_at'
+BEGIN;
+
+-- Without the column, soft-deleted logs would read as live again
+DELETE FROM public.habit_log WHERE deleted_at IS NOT NULL;
+
+DROP INDEX IF EXISTS public.habit_log_deleted_at_idx;
+ALTER TABLE public.habit_log DROP COLUMN IF EXISTS deleted_at;
+
+COMMIT;
+
+\echo '==> Done.'
@@ -0,0 +1,16 @@
+-- =========================
+-- Soft-deleted logs
+-- =========================
+\set ON_ERROR_STOP on
+\echo '==> Adding habit_log.deleted_at'
+BEGIN;
+
+ALTER TABLE public.habit_log ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+-- The purge only ever scans soft-deleted rows
+CREATE INDEX IF NOT EXISTS habit_log_deleted_at_idx
+  ON public.habit_log(deleted_at) WHERE deleted_at IS NOT NULL;
+
+COMMIT;
+
+\echo '==> Done.'
@@ -0,0 +1,12 @@
+-- =========================
+-- Revert: per-habit log upsert
+-- =========================
+\set ON_ERROR_STOP on
+\echo '==> Dropping habit.upsert_logs'
+BEGIN;
+
+ALTER TABLE public.habit DROP COLUMN IF EXISTS upsert_logs;
+
+COMMIT;
+
+\echo '==> Done.'
@@ -0,0 +1,16 @@
+-- =========================
+-- Per-habit log upsert
+-- =========================
+\set ON_ERROR_STOP on
+\echo '==> Adding habit.upsert_logs'
+BEGIN;
+
+-- When set, a log at the same instant as an existing one updates it instead
+-- of adding a duplicate. Enforced by the application under the habit row
+-- lock, so habits with historical duplicates can still opt in.
+ALTER TABLE public.habit
+  ADD COLUMN IF NOT EXISTS upsert_logs BOOLEAN NOT NULL DEFAULT FALSE;
+
+COMMIT;
+
+\echo '==> Done.'
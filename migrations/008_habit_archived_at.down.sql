@@ -0,0 +1,13 @@
+-- =========================
+-- Revert: archive timestamps for habits
+-- =========================
+\set ON_ERROR_STOP on
+\echo '==> Dropping habit.archived_at'
+BEGIN;
+
+DROP INDEX IF EXISTS public.habit_archived_at_idx;
+ALTER TABLE public.habit DROP COLUMN IF EXISTS archived_at;
+
+COMMIT;
+
+\echo '==> Done.'
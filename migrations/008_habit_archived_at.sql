@@ -0,0 +1,18 @@
+-- =========================
+-- Archive timestamps for habits
+-- =========================
+\set ON_ERROR_STOP on
+\echo '==> Adding habit.archived_at'
+BEGIN;
+
+-- Set when a habit is archived and cleared on restore; the purge job
+-- hard-deletes habits archived longer than the retention period.
+-- Habits deactivated before this column existed stay NULL and are never purged.
+ALTER TABLE public.habit ADD COLUMN IF NOT EXISTS archived_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS habit_archived_at_idx
+  ON public.habit(archived_at) WHERE archived_at IS NOT NULL;
+
+COMMIT;
+
+\echo '==> Done.'
@@ -0,0 +1,14 @@
+-- =========================
+-- Revert: habit color and icon
+-- =========================
+\set ON_ERROR_STOP on
+\echo '==> Dropping habit.color and habit.icon'
+BEGIN;
+
+ALTER TABLE public.habit
+  DROP COLUMN IF EXISTS color,
+  DROP COLUMN IF EXISTS icon;
+
+COMMIT;
+
+\echo '==> Done.'
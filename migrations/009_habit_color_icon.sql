@@ -0,0 +1,16 @@
+-- =========================
+-- Habit color and icon
+-- =========================
+\set ON_ERROR_STOP on
+\echo '==> Adding habit.color and habit.icon'
+BEGIN;
+
+-- Display metadata only; nothing in period or aggregate calculations reads them.
+-- The application validates color as a #rgb or #rrggbb hex code.
+ALTER TABLE public.habit
+  ADD COLUMN IF NOT EXISTS color TEXT,
+  ADD COLUMN IF NOT EXISTS icon  TEXT;
+
+COMMIT;
+
+\echo '==> Done.'
@@ -0,0 +1,12 @@
+-- =========================
+-- Revert: habit stats snapshot
+-- =========================
+\set ON_ERROR_STOP on
+\echo '==> Dropping habit_stats'
+BEGIN;
+
+DROP TABLE IF EXISTS public.habit_stats;
+
+COMMIT;
+
+\echo '==> Done.'
@@ -0,0 +1,24 @@
+-- =========================
+-- Habit stats snapshot
+-- =========================
+\set ON_ERROR_STOP on
+\echo '==> Creating habit_stats'
+BEGIN;
+
+-- One precomputed row per habit, rewritten by POST /api/habits/{id}/refresh-stats.
+-- Reads serve it while computed_at is recent enough and recompute otherwise.
+CREATE TABLE IF NOT EXISTS public.habit_stats (
+  habit_id           BIGINT PRIMARY KEY REFERENCES public.habit(id) ON DELETE CASCADE,
+  current_streak     INT NOT NULL DEFAULT 0,
+  longest_streak     INT NOT NULL DEFAULT 0,
+  last_met_period    TIMESTAMPTZ,
+  periods_met        INT NOT NULL DEFAULT 0,
+  periods_total      INT NOT NULL DEFAULT 0,
+  best_period_start  TIMESTAMPTZ,
+  best_period_value  NUMERIC NOT NULL DEFAULT 0, -- unbounded; a period total can exceed NUMERIC(12,2)
+  computed_at        TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+COMMIT;
+
+\echo '==> Done.'
@@ -0,0 +1,12 @@
+-- =========================
+-- Revert: remembered sessions
+-- =========================
+\set ON_ERROR_STOP on
+\echo '==> Dropping user_sessions.remember'
+BEGIN;
+
+ALTER TABLE public.user_sessions DROP COLUMN IF EXISTS remember;
+
+COMMIT;
+
+\echo '==> Done.'
@@ -0,0 +1,16 @@
+-- =========================
+-- Remembered sessions
+-- =========================
+\set ON_ERROR_STOP on
+\echo '==> Adding user_sessions.remember'
+BEGIN;
+
+-- FALSE for logins without "remember me": a short server-side expiry and a
+-- cookie that ends with the browser session. Existing sessions were all
+-- long-lived, so they default to TRUE.
+ALTER TABLE public.user_sessions
+  ADD COLUMN IF NOT EXISTS remember BOOLEAN NOT NULL DEFAULT TRUE;
+
+COMMIT;
+
+\echo '==> Done.'
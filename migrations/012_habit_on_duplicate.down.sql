@@ -0,0 +1,18 @@
+-- =========================
+-- Revert: per-habit duplicate log policy
+-- =========================
+\set ON_ERROR_STOP on
+\echo '==> Restoring habit.upsert_logs from habit.on_duplicate'
+BEGIN;
+
+-- reject has no upsert_logs equivalent and reverts to allowing duplicates
+ALTER TABLE public.habit
+  ADD COLUMN IF NOT EXISTS upsert_logs BOOLEAN NOT NULL DEFAULT FALSE;
+
+UPDATE public.habit SET upsert_logs = TRUE WHERE on_duplicate = 'replace';
+
+ALTER TABLE public.habit DROP COLUMN IF EXISTS on_duplicate;
+
+COMMIT;
+
+\echo '==> Done.'
@@ -0,0 +1,21 @@
+-- =========================
+-- Per-habit duplicate log policy
+-- =========================
+\set ON_ERROR_STOP on
+\echo '==> Replacing habit.upsert_logs with habit.on_duplicate'
+BEGIN;
+
+-- What a new log at the same instant as a live one does: allow inserts
+-- another, reject refuses it and replace updates the existing log. Enforced by
+-- the application under the habit row lock, like upsert_logs before it.
+ALTER TABLE public.habit
+  ADD COLUMN IF NOT EXISTS on_duplicate TEXT NOT NULL DEFAULT 'allow'
+  CHECK (on_duplicate IN ('allow','reject','replace'));
+
+UPDATE public.habit SET on_duplicate = 'replace' WHERE upsert_logs;
+
+ALTER TABLE public.habit DROP COLUMN IF EXISTS upsert_logs;
+
+COMMIT;
+
+\echo '==> Done.'
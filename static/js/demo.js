@@ -111,6 +111,15 @@ const errorHandler = {
 };
 
 // ======= Enhanced API Layer =======
+
+// CSRF token rendered into the page; unsafe requests must echo it back
+const csrfToken = document.querySelector('meta[name="csrf-token"]')?.content || '';
+
+// Merge the CSRF header into request headers
+function withCSRF(headers = {}) {
+	return { ...headers, 'X-CSRF-Token': csrfToken };
+}
+
 const api = {
 	// Handle API response and errors
 	async handleResponse(response, options = {}) {
@@ -166,15 +175,27 @@ const api = {
 		}
 	},
 
+	// List endpoints are paginated; keep requesting pages until a short one comes back
+	async getAllPages(url) {
+		const pageSize = 500;
+		const items = [];
+		for (let offset = 0; ; offset += pageSize) {
+			const sep = url.includes('?') ? '&' : '?';
+			const response = await fetch(`${url}${sep}limit=${pageSize}&offset=${offset}`);
+			const page = await this.handleResponse(response);
+			items.push(...page);
+			if (page.length < pageSize) return items;
+		}
+	},
+
 	async getHabits() {
-		const response = await fetch('/api/habits');
-		return await this.handleResponse(response);
+		return await this.getAllPages('/api/habits');
 	},
 
 	async createHabit(habit, options = {}) {
 		const response = await fetch('/api/habits', {
 			method: 'POST',
-			headers: { 'Content-Type': 'application/json' },
+			headers: withCSRF({ 'Content-Type': 'application/json' }),
 			body: JSON.stringify(habit)
 		});
 		return await this.handleResponse(response, options);
@@ -183,26 +204,25 @@ const api = {
 	async updateHabit(habit, options = {}) {
 		const response = await fetch(`/api/habits/${habit.id}`, {
 			method: 'PATCH',
-			headers: { 'Content-Type': 'application/json' },
+			headers: withCSRF({ 'Content-Type': 'application/json' }),
 			body: JSON.stringify(habit)
 		});
 		return await this.handleResponse(response, options);
 	},
 
 	async deleteHabit(id) {
-		const response = await fetch(`/api/habits/${id}`, { method: 'DELETE' });
+		const response = await fetch(`/api/habits/${id}`, { method: 'DELETE', headers: withCSRF() });
 		return await this.handleResponse(response);
 	},
 
 	async getLogs() {
-		const response = await fetch('/api/logs');
-		return await this.handleResponse(response);
+		return await this.getAllPages('/api/logs');
 	},
 
 	async createLog(log, options = {}) {
 		const response = await fetch('/api/logs', {
 			method: 'POST',
-			headers: { 'Content-Type': 'application/json' },
+			headers: withCSRF({ 'Content-Type': 'application/json' }),
 			body: JSON.stringify(log)
 		});
 		return await this.handleResponse(response, options);
@@ -211,14 +231,14 @@ const api = {
 	async updateLog(log, options = {}) {
 		const response = await fetch(`/api/logs/${log.id}`, {
 			method: 'PATCH',
-			headers: { 'Content-Type': 'application/json' },
+			headers: withCSRF({ 'Content-Type': 'application/json' }),
 			body: JSON.stringify(log)
 		});
 		return await this.handleResponse(response, options);
 	},
 
 	async deleteLog(id) {
-		const response = await fetch(`/api/logs/${id}`, { method: 'DELETE' });
+		const response = await fetch(`/api/logs/${id}`, { method: 'DELETE', headers: withCSRF() });
 		return await this.handleResponse(response);
 	},
 
@@ -226,9 +246,9 @@ const api = {
 		const res = await fetch('/logout', {
 			method: 'POST',
 			credentials: 'include',
-			headers: {
+			headers: withCSRF({
 				'Accept': 'application/json'
-			}
+			})
 		});
 
 		if (!res.ok) {